@@ -0,0 +1,172 @@
+// Package authority serves the keys of an attribute authority over HTTP. The
+// authority holds the master key, publishes the public key at /publickey and
+// issues decryption keys to callers at /key, as far as its Authorizer allows.
+package authority
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ABE/bsw07"
+	"ABE/gpsw06"
+)
+
+// maxRequestSize bounds the body of a key request.
+const maxRequestSize = 1 << 20
+
+// Request is a key request. BSW07 keys are issued for Attributes and GPSW06
+// keys for a Policy.
+type Request struct {
+	Attributes []string `json:"attributes,omitempty"`
+	Policy     string   `json:"policy,omitempty"`
+}
+
+// Authorizer decides which key the caller of r may receive. It returns the
+// request to be granted, which may be narrower than req, or an error if the
+// caller is denied. Errors are reported to the caller.
+type Authorizer func(r *http.Request, req *Request) (*Request, error)
+
+// issuer encodes the keys of one scheme.
+type issuer interface {
+	publicKey() ([]byte, error)
+	issue(req *Request) ([]byte, error)
+}
+
+// Authority is an http.Handler issuing the keys of one scheme.
+type Authority struct {
+	issuer      issuer
+	contentType string
+	auth        Authorizer
+	mux         *http.ServeMux
+}
+
+// NewBSW07 returns an authority issuing BSW07 keys of msk, encoded in JSON.
+func NewBSW07(algo *bsw07.BSW07, pk *bsw07.PublicKey, msk *bsw07.MasterKey, auth Authorizer) (*Authority, error) {
+	return newAuthority(&bsw07Issuer{algo, pk, msk}, "application/json", auth)
+}
+
+// NewGPSW06 returns an authority issuing GPSW06 keys of msk, encoded by Marshal.
+func NewGPSW06(algo *gpsw06.GPSW06, pk *gpsw06.PublicKey, msk *gpsw06.MasterKey, auth Authorizer) (*Authority, error) {
+	return newAuthority(&gpsw06Issuer{algo, pk, msk}, "text/plain; charset=utf-8", auth)
+}
+
+func newAuthority(issuer issuer, contentType string, auth Authorizer) (*Authority, error) {
+	if auth == nil {
+		return nil, ErrNilAuthorizer
+	}
+
+	a := &Authority{issuer, contentType, auth, http.NewServeMux()}
+	a.mux.HandleFunc("/publickey", a.servePublicKey)
+	a.mux.HandleFunc("/key", a.serveKey)
+	return a, nil
+}
+
+func (a *Authority) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+func (a *Authority) servePublicKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := a.issuer.publicKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.write(w, data)
+}
+
+func (a *Authority) serveKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := &Request{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(req); err != nil {
+		http.Error(w, ErrBadRequest.Error(), http.StatusBadRequest)
+		return
+	}
+
+	granted, err := a.auth(r, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if granted == nil || (len(granted.Attributes) == 0 && granted.Policy == "") {
+		http.Error(w, ErrDenied.Error(), http.StatusForbidden)
+		return
+	}
+
+	data, err := a.issuer.issue(granted)
+	if err != nil {
+		// Requests the scheme cannot issue a key for are the fault of the caller
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.write(w, data)
+}
+
+func (a *Authority) write(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", a.contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+type bsw07Issuer struct {
+	algo *bsw07.BSW07
+	pk   *bsw07.PublicKey
+	msk  *bsw07.MasterKey
+}
+
+func (i *bsw07Issuer) publicKey() ([]byte, error) {
+	return json.Marshal(i.pk)
+}
+
+func (i *bsw07Issuer) issue(req *Request) ([]byte, error) {
+	if len(req.Attributes) == 0 {
+		return nil, ErrBadRequest
+	}
+
+	attrs := make(map[string]struct{})
+	for _, attr := range req.Attributes {
+		attrs[attr] = struct{}{}
+	}
+
+	dk, err := i.algo.KeyGen(i.msk, attrs)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(dk)
+}
+
+type gpsw06Issuer struct {
+	algo *gpsw06.GPSW06
+	pk   *gpsw06.PublicKey
+	msk  *gpsw06.MasterKey
+}
+
+func (i *gpsw06Issuer) publicKey() ([]byte, error) {
+	return i.pk.Marshal()
+}
+
+func (i *gpsw06Issuer) issue(req *Request) ([]byte, error) {
+	if req.Policy == "" {
+		return nil, ErrBadRequest
+	}
+
+	tree, err := i.algo.ParsePolicy(req.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	dk, err := i.algo.KeyGen(tree, i.msk)
+	if err != nil {
+		return nil, err
+	}
+	return dk.Marshal()
+}
@@ -0,0 +1,313 @@
+package authority
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"ABE/bsw07"
+	"ABE/gpsw06"
+)
+
+// allowed maps the user header of a caller to the attributes it may receive.
+var allowed = map[string]map[string]struct{}{
+	"alice": {"a": {}, "b": {}},
+}
+
+func testAuthorizer(r *http.Request, req *Request) (*Request, error) {
+	attrs, ok := allowed[r.Header.Get("X-User")]
+	if !ok {
+		return nil, ErrDenied
+	}
+
+	// GPSW06 policies are only handed to known callers
+	if req.Policy != "" {
+		return req, nil
+	}
+
+	granted := &Request{}
+	for _, attr := range req.Attributes {
+		if _, ok := attrs[attr]; ok {
+			granted.Attributes = append(granted.Attributes, attr)
+		}
+	}
+	return granted, nil
+}
+
+func do(t *testing.T, method, url, user string, body []byte) (int, []byte) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-User", user)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+func TestNewBSW07(t *testing.T) {
+	algo, err := bsw07.NewBSW07()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, msk, err := algo.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewBSW07(algo, pk, msk, testAuthorizer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a)
+	defer server.Close()
+
+	status, data := do(t, http.MethodGet, server.URL+"/publickey", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("Unexpected status %d fetching public key: %s", status, data)
+	}
+	publicKey := &bsw07.PublicKey{}
+	if err := json.Unmarshal(data, publicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	status, data = do(t, http.MethodPost, server.URL+"/key", "alice", []byte(`{"attributes":["a","b","c"]}`))
+	if status != http.StatusOK {
+		t.Fatalf("Unexpected status %d fetching key: %s", status, data)
+	}
+	dk := &bsw07.DecryptKey{}
+	if err := json.Unmarshal(data, dk); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dk.S["c"]; ok || len(dk.S) != 2 {
+		t.Errorf("Key issued for attributes beyond those allowed: %v", dk.S)
+	}
+
+	tree, err := bsw07.ParsePolicy("a AND b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := algo.NewMessage().Rand()
+	ct, err := algo.Encrypt(publicKey, msg, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err := algo.Decrypt(ct, dk); err != nil {
+		t.Errorf("Error (%v) during decryption with issued key.", err)
+	} else if !decrypted.M.E.Equals(msg.M.E) {
+		t.Errorf("Decrypted message does not match.")
+	}
+
+	var tests = []struct {
+		method string
+		path   string
+		user   string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/publickey", "", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/key", "alice", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/key", "alice", "{", http.StatusBadRequest},
+		{http.MethodPost, "/key", "mallory", `{"attributes":["a"]}`, http.StatusForbidden},
+		{http.MethodPost, "/key", "alice", `{"attributes":["c"]}`, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		if status, data := do(t, test.method, server.URL+test.path, test.user, []byte(test.body)); status != test.status {
+			t.Errorf("%s %s %s: status %d, expected %d (%s)", test.method, test.path, test.body, status, test.status, data)
+		}
+	}
+}
+
+func TestNewGPSW06(t *testing.T) {
+	algo, err := gpsw06.NewLargeUniverseGPSW06()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, msk, err := algo.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewGPSW06(algo, pk, msk, testAuthorizer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a)
+	defer server.Close()
+
+	status, data := do(t, http.MethodGet, server.URL+"/publickey", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("Unexpected status %d fetching public key: %s", status, data)
+	}
+	publicKey := &gpsw06.PublicKey{}
+	if _, err := publicKey.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+
+	status, data = do(t, http.MethodPost, server.URL+"/key", "alice", []byte(`{"policy":"a AND b"}`))
+	if status != http.StatusOK {
+		t.Fatalf("Unexpected status %d fetching key: %s", status, data)
+	}
+	dk := &gpsw06.DecryptKey{}
+	if _, err := dk.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := algo.NewMessage().Rand()
+	ct, err := algo.EncryptLabels(msg, map[string]struct{}{"a": {}, "b": {}}, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err := algo.Decrypt(ct, dk); err != nil {
+		t.Errorf("Error (%v) during decryption with issued key.", err)
+	} else if !bytes.Equal(decrypted.Marshal(), msg.Marshal()) {
+		t.Errorf("Decrypted message does not match.")
+	}
+
+	if status, data := do(t, http.MethodPost, server.URL+"/key", "mallory", []byte(`{"policy":"a"}`)); status != http.StatusForbidden {
+		t.Errorf("Denied request answered with status %d (%s)", status, data)
+	}
+
+	if status, data := do(t, http.MethodPost, server.URL+"/key", "alice", []byte(`{"policy":"a AND"}`)); status != http.StatusBadRequest {
+		t.Errorf("Malformed policy answered with status %d (%s)", status, data)
+	}
+}
+
+func TestNewAuthority_NilAuthorizer(t *testing.T) {
+	if _, err := NewBSW07(nil, nil, nil, nil); err != ErrNilAuthorizer {
+		t.Errorf("Nil authorizer accepted: %v", err)
+	}
+}
+
+// reload reads params back from their JSON encoding into into, which then carries its own
+// pairing and so differs from the parameters that elements are decoded with by default.
+func reload(t *testing.T, params json.Marshaler, into json.Unmarshaler) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := into.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// barrier returns an authorizer which holds requests back until n of them have
+// arrived, so that all of them call KeyGen at once.
+func barrier(n int) Authorizer {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	return func(r *http.Request, req *Request) (*Request, error) {
+		wg.Done()
+		wg.Wait()
+		return testAuthorizer(r, req)
+	}
+}
+
+// parallel sends n key requests with body at once, failing unless all of them succeed.
+func parallel(t *testing.T, url string, n int, body string) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// do cannot be used, as it calls Fatal
+			req, err := http.NewRequest(http.MethodPost, url+"/key", strings.NewReader(body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			req.Header.Set("X-User", "alice")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				data, _ := ioutil.ReadAll(resp.Body)
+				t.Errorf("Unexpected status %d fetching key: %s", resp.StatusCode, data)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestAuthority_Parallel(t *testing.T) {
+	// the master keys are read back, so that every request binds them to the
+	// parameters of the algorithm at the same time
+	const requests = 8
+	defaults, err := bsw07.DefaultParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := &bsw07.Params{}
+	reload(t, defaults, params)
+	algo, err := bsw07.NewBSW07(bsw07.WithParams(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, msk, err := algo.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(msk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msk = &bsw07.MasterKey{}
+	if err := json.Unmarshal(data, msk); err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewBSW07(algo, pk, msk, barrier(requests))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a)
+	defer server.Close()
+	parallel(t, server.URL, requests, `{"attributes":["a","b"]}`)
+
+	gDefaults, err := gpsw06.DefaultParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gParams := &gpsw06.Params{}
+	reload(t, gDefaults, gParams)
+	gAlgo, err := gpsw06.NewLargeUniverseGPSW06(gpsw06.WithParams(gParams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gPK, gMSK, err := gAlgo.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = gMSK.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gMSK = &gpsw06.MasterKey{}
+	if _, err := gMSK.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGPSW06(gAlgo, gPK, gMSK, barrier(requests))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gServer := httptest.NewServer(g)
+	defer gServer.Close()
+	parallel(t, gServer.URL, requests, `{"policy":"a AND b"}`)
+}
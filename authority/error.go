@@ -0,0 +1,9 @@
+package authority
+
+import "errors"
+
+var (
+	ErrBadRequest    = errors.New("malformed key request")
+	ErrDenied        = errors.New("key request denied")
+	ErrNilAuthorizer = errors.New("authorizer is nil")
+)
@@ -0,0 +1,595 @@
+package bsw07
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// BinaryVersion is the version of encodings written by the MarshalBinary
+// methods. Version 1 lacks the parameter fingerprints of keys and ciphertexts,
+// and is still read.
+const BinaryVersion = 2
+
+// Type tags following the version byte of binary encodings.
+const (
+	binaryPublicKey byte = iota + 1
+	binaryMasterKey
+	binaryDecryptKey
+	binaryCiphertext
+	binaryEnvelope
+	binaryPolicy
+	binaryTransformKey
+	binaryRetrievalKey
+	binaryPartialCiphertext
+)
+
+// Node tags of binary policies.
+const (
+	binaryLeaf byte = iota
+	binaryGate
+)
+
+// maxNodeDepth bounds the nesting of gates in binary policies and of parentheses
+// in policy strings, far beyond that of expanded policies, so that decoding
+// crafted input cannot exhaust the stack.
+const maxNodeDepth = 1024
+
+// encoder appends the fields of a binary encoding to buf. Variable-length
+// fields are prefixed by their length as a uvarint, and points of G are
+// compressed.
+type encoder struct {
+	buf []byte
+}
+
+func newEncoder(tag byte) *encoder {
+	return &encoder{[]byte{BinaryVersion, tag}}
+}
+
+func (e *encoder) uvarint(x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, b[:binary.PutUvarint(b[:], x)]...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+// element writes el, or an empty field if el is nil.
+func (e *encoder) element(el *Element) {
+	switch {
+	case el == nil:
+		e.bytes(nil)
+	case el.E == nil:
+		// Element not decoded, which is written as it was read
+		e.bytes(el.raw)
+	case el.Field == "G":
+		e.bytes(el.E.CompressedBytes())
+	default:
+		e.bytes(el.E.Bytes())
+	}
+}
+
+// elements writes the elements of m in ascending order of keys.
+func (e *encoder) elements(m map[string]*G) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	e.uvarint(uint64(len(keys)))
+	for _, k := range keys {
+		e.string(k)
+		e.element(m[k])
+	}
+}
+
+// positions writes the elements of m in ascending order of leaf positions.
+func (e *encoder) positions(m map[int]*G) {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	e.uvarint(uint64(len(keys)))
+	for _, k := range keys {
+		e.uvarint(uint64(k))
+		e.element(m[k])
+	}
+}
+
+// set writes the attributes of s in ascending order.
+func (e *encoder) set(s map[string]struct{}) {
+	e.uvarint(uint64(len(s)))
+	for _, attr := range sortedAttributes(s) {
+		e.string(attr)
+	}
+}
+
+func (e *encoder) node(x Node) error {
+	switch node := x.(type) {
+	case *leafNode:
+		e.buf = append(e.buf, binaryLeaf)
+		e.string(string(node.Attr))
+	case *nonLeafNode:
+		e.buf = append(e.buf, binaryGate, byte(node.Gate))
+		e.uvarint(uint64(node.K))
+		e.uvarint(uint64(len(node.Children)))
+		for _, child := range node.Children {
+			if err := e.node(child); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrUnknownNodeType
+	}
+	return nil
+}
+
+// tree writes the access tree encoded in JSON by data.
+func (e *encoder) tree(data []byte) error {
+	tree, err := NodeFromJSON(data)
+	if err != nil {
+		return err
+	}
+	return e.node(tree)
+}
+
+// decoder reads the fields written by encoder. The first error is kept and
+// reported by finish, and later reads return zero values.
+type decoder struct {
+	buf     []byte
+	err     error
+	version byte
+}
+
+func newDecoder(data []byte, tag byte) *decoder {
+	d := &decoder{buf: data}
+	switch {
+	case len(data) < 2:
+		d.err = ErrBadBinary
+	case data[0] < 1 || data[0] > BinaryVersion:
+		d.err = ErrBinaryVersion
+	case data[1] != tag:
+		d.err = ErrBinaryType
+	default:
+		d.buf, d.version = data[2:], data[0]
+	}
+	return d
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	} else if len(d.buf) == 0 {
+		d.err = ErrBadBinary
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = ErrBadBinary
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return x
+}
+
+// int reads a non-negative int.
+func (d *decoder) int() int {
+	x := d.uvarint()
+	if x > uint64(^uint(0)>>1) {
+		d.err = ErrBadBinary
+		return 0
+	}
+	return int(x)
+}
+
+// count reads the number of items of a list, each of which takes at least one
+// byte, so that corrupt counts cannot cause huge allocations.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.err = ErrBadBinary
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	if d.err != nil {
+		return nil
+	}
+	b := d.buf[:n:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+// fingerprint reads a parameter fingerprint, which is required since version 2
+// and absent before.
+func (d *decoder) fingerprint() []byte {
+	if d.version < 2 {
+		// Legacy encodings are trusted to be of the parameters of the scheme
+		return nil
+	}
+
+	fp := d.bytes()
+	if len(fp) == 0 && d.err == nil {
+		d.err = ErrMissingFingerprint
+	}
+	return fp
+}
+
+// element reads an element of field, which is nil if the field is empty. Like
+// UnmarshalJSON, the element is decoded with the default parameters or other
+// parameters created by NewParams.
+func (d *decoder) element(field string) *Element {
+	raw := d.bytes()
+	if len(raw) == 0 {
+		return nil
+	}
+
+	el := &Element{Field: field, raw: raw}
+	if err := el.decodeLoaded(); err != nil && d.err == nil {
+		d.err = err
+	}
+	return el
+}
+
+func (d *decoder) elements() map[string]*G {
+	n := d.count()
+	m := make(map[string]*G, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		k := d.string()
+		m[k] = d.element("G")
+	}
+	return m
+}
+
+func (d *decoder) positions() map[int]*G {
+	n := d.count()
+	m := make(map[int]*G, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		k := d.int()
+		m[k] = d.element("G")
+	}
+	return m
+}
+
+func (d *decoder) set() map[string]struct{} {
+	n := d.count()
+	s := make(map[string]struct{}, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		s[d.string()] = struct{}{}
+	}
+	return s
+}
+
+// node reads a node nested in depth gates.
+func (d *decoder) node(depth int) Node {
+	switch d.byte() {
+	case binaryLeaf:
+		attr := d.string()
+		if d.err == nil && attr == "" {
+			d.err = ErrEmptyAttribute
+		}
+		return &leafNode{Attribute(attr), nil}
+	case binaryGate:
+		if d.err == nil && depth >= maxNodeDepth {
+			d.err = ErrBadBinary
+		}
+		gate, k, n := operator(d.byte()), d.int(), d.count()
+		if d.err != nil {
+			return nil
+		}
+
+		switch {
+		case n == 0:
+			d.err = ErrEmptyGate
+			return nil
+		case gate == or || gate == and:
+			k = 0
+		case gate == threshold:
+			if k < 1 || k > n {
+				d.err = ErrInvalidThreshold
+				return nil
+			}
+		default:
+			d.err = ErrUnknownNodeType
+			return nil
+		}
+
+		children := make([]Node, 0, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			children = append(children, d.node(depth+1))
+		}
+		if d.err != nil {
+			return nil
+		}
+
+		x := newGate(gate, children)
+		x.K = k
+		return x
+	default:
+		if d.err == nil {
+			d.err = ErrUnknownNodeType
+		}
+		return nil
+	}
+}
+
+// tree reads an access tree and returns its JSON encoding, as held by
+// ciphertexts.
+func (d *decoder) tree() []byte {
+	x := d.node(0)
+	if d.err != nil {
+		return nil
+	}
+
+	data, err := x.MarshalJSON()
+	if err != nil {
+		d.err = err
+	}
+	return data
+}
+
+// finish returns the first error of d, or ErrBadBinary if bytes are left over.
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.buf) != 0 {
+		return ErrBadBinary
+	}
+	return d.err
+}
+
+// MarshalBinary encodes the tree rooted at l in the binary format of policies.
+func (l *leafNode) MarshalBinary() ([]byte, error) {
+	return marshalNode(l)
+}
+
+// MarshalBinary encodes the tree rooted at n in the binary format of policies.
+func (n *nonLeafNode) MarshalBinary() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func marshalNode(x Node) ([]byte, error) {
+	e := newEncoder(binaryPolicy)
+	if err := e.node(x); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// NodeFromBinary decodes a policy encoded by Node.MarshalBinary.
+func NodeFromBinary(data []byte) (Node, error) {
+	d := newDecoder(data, binaryPolicy)
+	x := d.node(0)
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// PublicKey implements encoding.BinaryMarshaler.
+func (pk *PublicKey) MarshalBinary() ([]byte, error) {
+	e := newEncoder(binaryPublicKey)
+	e.bytes(pk.Fingerprint)
+	e.element(pk.H)
+	e.element(pk.E)
+	return e.buf, nil
+}
+
+// PublicKey implements encoding.BinaryUnmarshaler.
+func (pk *PublicKey) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryPublicKey)
+	fp := d.fingerprint()
+	h, e := d.element("G"), d.element("GT")
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*pk = *NewPublicKey(h, e)
+	pk.Fingerprint = fp
+	return nil
+}
+
+// MasterKey implements encoding.BinaryMarshaler.
+func (msk *MasterKey) MarshalBinary() ([]byte, error) {
+	e := newEncoder(binaryMasterKey)
+	e.bytes(msk.Fingerprint)
+	e.element(msk.A)
+	e.element(msk.B)
+	return e.buf, nil
+}
+
+// MasterKey implements encoding.BinaryUnmarshaler.
+func (msk *MasterKey) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryMasterKey)
+	fp := d.fingerprint()
+	a, b := d.element("G"), d.element("Zr")
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*msk = *NewMasterKey(a, b)
+	msk.Fingerprint = fp
+	return nil
+}
+
+// DecryptKey implements encoding.BinaryMarshaler.
+func (dk *DecryptKey) MarshalBinary() ([]byte, error) {
+	e := newEncoder(binaryDecryptKey)
+	e.bytes(dk.Fingerprint)
+	e.set(dk.S)
+	e.element(dk.D)
+	e.element(dk.F)
+	e.elements(dk.D1)
+	e.elements(dk.D2)
+	return e.buf, nil
+}
+
+// DecryptKey implements encoding.BinaryUnmarshaler.
+func (dk *DecryptKey) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryDecryptKey)
+	fp := d.fingerprint()
+	s := d.set()
+	dd, f := d.element("G"), d.element("G")
+	d1, d2 := d.elements(), d.elements()
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*dk = *NewDecryptKey(s, dd, f, d1, d2)
+	dk.Fingerprint = fp
+	return nil
+}
+
+func (ct *Ciphertext) encodeBinary(e *encoder) error {
+	e.bytes(ct.Fingerprint)
+	if err := e.tree(ct.Tree); err != nil {
+		return err
+	}
+	e.element(ct.Msg)
+	e.element(ct.C)
+	e.positions(ct.C1)
+	e.positions(ct.C2)
+	return nil
+}
+
+func (ct *Ciphertext) decodeBinary(d *decoder) {
+	fp := d.fingerprint()
+	tree := d.tree()
+	msg, c := d.element("GT"), d.element("G")
+	c1, c2 := d.positions(), d.positions()
+	if d.err == nil {
+		*ct = *NewCiphertext(tree, msg, c, c1, c2)
+		ct.Fingerprint = fp
+	}
+}
+
+// Ciphertext implements encoding.BinaryMarshaler. The access tree is written
+// in the binary format of policies.
+func (ct *Ciphertext) MarshalBinary() ([]byte, error) {
+	e := newEncoder(binaryCiphertext)
+	if err := ct.encodeBinary(e); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Ciphertext implements encoding.BinaryUnmarshaler.
+func (ct *Ciphertext) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryCiphertext)
+	ct.decodeBinary(d)
+	return d.finish()
+}
+
+// Envelope implements encoding.BinaryMarshaler.
+func (env *Envelope) MarshalBinary() ([]byte, error) {
+	if env.Key == nil {
+		return nil, ErrBadEnvelope
+	}
+
+	e := newEncoder(binaryEnvelope)
+	if err := env.Key.encodeBinary(e); err != nil {
+		return nil, err
+	}
+	e.bytes(env.Nonce)
+	e.bytes(env.Data)
+	return e.buf, nil
+}
+
+// Envelope implements encoding.BinaryUnmarshaler.
+func (env *Envelope) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryEnvelope)
+	key := &Ciphertext{}
+	key.decodeBinary(d)
+	nonce, payload := d.bytes(), d.bytes()
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*env = *NewEnvelope(key, nonce, payload)
+	return nil
+}
+
+// TransformKey implements encoding.BinaryMarshaler.
+func (tk *TransformKey) MarshalBinary() ([]byte, error) {
+	e := newEncoder(binaryTransformKey)
+	e.set(tk.S)
+	e.element(tk.D)
+	e.elements(tk.D1)
+	e.elements(tk.D2)
+	return e.buf, nil
+}
+
+// TransformKey implements encoding.BinaryUnmarshaler.
+func (tk *TransformKey) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryTransformKey)
+	s := d.set()
+	dd := d.element("G")
+	d1, d2 := d.elements(), d.elements()
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*tk = *NewTransformKey(s, dd, d1, d2)
+	return nil
+}
+
+// RetrievalKey implements encoding.BinaryMarshaler.
+func (rk *RetrievalKey) MarshalBinary() ([]byte, error) {
+	e := newEncoder(binaryRetrievalKey)
+	e.element(rk.Z)
+	return e.buf, nil
+}
+
+// RetrievalKey implements encoding.BinaryUnmarshaler.
+func (rk *RetrievalKey) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryRetrievalKey)
+	z := d.element("Zr")
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*rk = *NewRetrievalKey(z)
+	return nil
+}
+
+// PartialCiphertext implements encoding.BinaryMarshaler.
+func (pct *PartialCiphertext) MarshalBinary() ([]byte, error) {
+	e := newEncoder(binaryPartialCiphertext)
+	e.element(pct.Msg)
+	e.element(pct.T)
+	return e.buf, nil
+}
+
+// PartialCiphertext implements encoding.BinaryUnmarshaler.
+func (pct *PartialCiphertext) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryPartialCiphertext)
+	msg, t := d.element("GT"), d.element("GT")
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*pct = *NewPartialCiphertext(msg, t)
+	return nil
+}
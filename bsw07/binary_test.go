@@ -0,0 +1,177 @@
+package bsw07
+
+import (
+	"encoding"
+	"encoding/json"
+	"testing"
+)
+
+func TestNodeFromBinary(t *testing.T) {
+	for _, policy := range []string{"a", "a AND b", "a OR (b AND c)", "2 of (a, b AND c, d)"} {
+		tree, err := ParsePolicy(policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := tree.MarshalBinary()
+		if err != nil {
+			t.Errorf("Error (%v) during marshaling %q.", err, policy)
+			continue
+		}
+
+		tree2, err := NodeFromBinary(data)
+		if err != nil {
+			t.Errorf("Error (%v) during unmarshaling %q.", err, policy)
+		} else if !tree.Equal(tree2) {
+			t.Errorf("Policy %q unmarshaled wrongly: %v", policy, tree2)
+		}
+	}
+
+	for _, data := range [][]byte{
+		{},
+		{BinaryVersion},
+		{BinaryVersion + 1, binaryPolicy, binaryLeaf, 1, 'a'},
+		{BinaryVersion, binaryPublicKey, binaryLeaf, 1, 'a'},
+		{BinaryVersion, binaryPolicy, binaryLeaf, 2, 'a'},
+		{BinaryVersion, binaryPolicy, binaryLeaf, 1, 'a', 0},
+		{BinaryVersion, binaryPolicy, binaryLeaf, 0},
+		{BinaryVersion, binaryPolicy, binaryGate, byte(or), 0, 0},
+		{BinaryVersion, binaryPolicy, binaryGate, byte(threshold), 2, 1, binaryLeaf, 1, 'a'},
+		{BinaryVersion, binaryPolicy, 7},
+	} {
+		if _, err := NodeFromBinary(data); err == nil {
+			t.Errorf("Malformed policy %v accepted", data)
+		}
+	}
+
+	// Gates nested beyond maxNodeDepth are rejected before exhausting the stack
+	nested := func(depth int) []byte {
+		data := []byte{BinaryVersion, binaryPolicy}
+		for i := 0; i < depth; i++ {
+			data = append(data, binaryGate, byte(or), 0, 1)
+		}
+		return append(data, binaryLeaf, 1, 'a')
+	}
+	if _, err := NodeFromBinary(nested(maxNodeDepth)); err != nil {
+		t.Errorf("Error (%v) during unmarshaling policy nested %d deep.", err, maxNodeDepth)
+	}
+	if _, err := NodeFromBinary(nested(maxNodeDepth + 1)); err != ErrBadBinary {
+		t.Errorf("Policy nested too deep unmarshaled with %v, expecting %v", err, ErrBadBinary)
+	}
+}
+
+func TestBinary(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+	attrs := map[string]struct{}{"a": {}, "b": {}}
+
+	dk, err := algo.KeyGen(msk, attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, _ := ParsePolicy("a AND (b OR c)")
+	msg := algo.NewMessage().Rand()
+	ct, err := algo.Encrypt(pk, msg, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := algo.EncryptBytes(pk, []byte("plaintext"), tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tk, rk, err := algo.Blind(dk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pct, err := algo.Transform(ct, tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoded values must encode to the same JSON
+	for _, v := range []struct {
+		in  encoding.BinaryMarshaler
+		out encoding.BinaryUnmarshaler
+	}{
+		{pk, &PublicKey{}},
+		{msk, &MasterKey{}},
+		{dk, &DecryptKey{}},
+		{ct, &Ciphertext{}},
+		{env, &Envelope{}},
+		{tk, &TransformKey{}},
+		{rk, &RetrievalKey{}},
+		{pct, &PartialCiphertext{}},
+	} {
+		data, err := v.in.MarshalBinary()
+		if err != nil {
+			t.Errorf("Error (%v) during marshaling %T.", err, v.in)
+			continue
+		}
+
+		if err := v.out.UnmarshalBinary(data); err != nil {
+			t.Errorf("Error (%v) during unmarshaling %T.", err, v.in)
+			continue
+		}
+
+		expected, _ := json.Marshal(v.in)
+		actual, _ := json.Marshal(v.out)
+		if !jsonEqual(expected, actual) {
+			t.Errorf("%T unmarshaled wrongly", v.in)
+		}
+
+		if len(data) >= len(expected) {
+			t.Errorf("Binary encoding of %T (%d bytes) is not smaller than JSON (%d bytes)", v.in, len(data), len(expected))
+		}
+
+		if err := v.out.UnmarshalBinary(data[:len(data)-1]); err == nil {
+			t.Errorf("Truncated %T accepted", v.in)
+		}
+	}
+
+	data, _ := pk.MarshalBinary()
+	if err := msk.UnmarshalBinary(data); err != ErrBinaryType {
+		t.Errorf("Public key accepted as master key: %v", err)
+	}
+
+	identity := &Element{Field: "G", E: algo.params.pairing.P.NewG1().Set1()}
+	pk2 := NewPublicKey(identity, pk.E)
+	pk2.Fingerprint = pk.Fingerprint
+	data, _ = pk2.MarshalBinary()
+	if err := (&PublicKey{}).UnmarshalBinary(data); err != ErrIdentityElement {
+		t.Errorf("Public key with identity element unmarshaled with %v, expecting %v", err, ErrIdentityElement)
+	}
+
+	// Decoded keys and ciphertexts still work together
+	data, _ = ct.MarshalBinary()
+	ct2 := &Ciphertext{}
+	if err := ct2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = dk.MarshalBinary()
+	dk2 := &DecryptKey{}
+	if err := dk2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if plain, err := algo.Decrypt(ct2, dk2); err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+	} else if !plain.M.E.Equals(msg.M.E) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+
+	data, _ = env.MarshalBinary()
+	env2 := &Envelope{}
+	if err := env2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext, err := algo.DecryptBytes(env2, dk2); err != nil {
+		t.Errorf("Error (%v) during decryption of envelope.", err)
+	} else if string(plaintext) != "plaintext" {
+		t.Errorf("Envelope decrypted wrongly: %q", plaintext)
+	}
+}
@@ -1,7 +1,10 @@
 package bsw07
 
 import (
+	cryptorand "crypto/rand"
 	"crypto/sha256"
+	"io"
+	"sync"
 
 	"github.com/Nik-U/pbc"
 )
@@ -12,15 +15,51 @@ func hash(data []byte) []byte {
 	return h.Sum(nil)
 }
 
-// NewBSW07 instantiates a BSW07 from a set of attributes
-func NewBSW07() (*BSW07, error) {
+// Option configures a BSW07 instantiated by NewBSW07.
+type Option func(*BSW07) error
+
+// NewBSW07 instantiates a BSW07 configured by opts
+func NewBSW07(opts ...Option) (*BSW07, error) {
 	pbc.SetCryptoRandom()
 
-	return &BSW07{}, nil
+	algo := &BSW07{
+		numericBits: DefaultNumericBits,
+		workers:     1,
+		random:      cryptorand.Reader,
+	}
+	for _, opt := range opts {
+		if err := opt(algo); err != nil {
+			return nil, err
+		}
+	}
+
+	if algo.params == nil {
+		if defaultParamsErr != nil {
+			return nil, defaultParamsErr
+		}
+		algo.params = defaultParams
+	}
+
+	return algo, nil
+}
+
+// WithWorkers sets the number of goroutines computing the leaf components of
+// ciphertexts in Encrypt to n. The components are computed sequentially by
+// default.
+func WithWorkers(n int) Option {
+	return func(algo *BSW07) error {
+		if n < 1 {
+			return ErrInvalidWorkers
+		}
+		algo.workers = n
+		return nil
+	}
 }
 
 // Setup outputs a public key and a master key.
-func (algo *BSW07) Setup() (*PublicKey, *MasterKey) {
+func (algo *BSW07) Setup() (*PublicKey, *MasterKey, error) {
+	pairing, gPower := algo.params.pairing, algo.params.gPower
+
 	var (
 		a  *Zr = pairing.NewZr()
 		b  *Zr = pairing.NewZr()
@@ -30,46 +69,90 @@ func (algo *BSW07) Setup() (*PublicKey, *MasterKey) {
 	)
 
 	// Choose a random number a, b from Zr as secret key
-	a.E.Rand()
-	b.E.Rand()
+	if err := algo.randZr(a); err != nil {
+		return nil, nil, err
+	} else if err := algo.randZr(b); err != nil {
+		return nil, nil, err
+	}
 
 	// Calculate g^a, h = g^b, e = e(g,g)^a as public key relative to secret key
-	ga.E.PowZn(g.E, a.E)
-	h.E.PowZn(g.E, b.E)
-	eg.E.PowZn(e, a.E)
+	ga.E.PowerZn(gPower, a.E)
+	h.E.PowerZn(gPower, b.E)
+	eg.E.PowerZn(algo.params.ePower, a.E)
 
-	return NewPublicKey(h, eg), NewMasterKey(ga, b)
+	pk, msk := NewPublicKey(h, eg), NewMasterKey(ga, b)
+	pk.Fingerprint, msk.Fingerprint = algo.params.Fingerprint(), algo.params.Fingerprint()
+
+	return pk, msk, nil
+}
+
+// publicPowers returns the fixed-base tables of key, which are prepared once for
+// as long as Encrypt keeps being called with the same key.
+func (algo *BSW07) publicPowers(key *PublicKey) *publicPowers {
+	algo.mu.Lock()
+	defer algo.mu.Unlock()
+
+	if algo.powers == nil || !algo.powers.h.Source().Equals(key.H.E) || !algo.powers.e.Source().Equals(key.E.E) {
+		algo.powers = &publicPowers{key.H.E.PreparePower(), key.E.E.PreparePower()}
+	}
+	return algo.powers
 }
 
 // Encrypt takes as input the public key, message and the access structure tree, and output
-// the ciphertext.
+// the ciphertext. Numeric comparisons in tree are expanded into their bit attributes.
 func (algo *BSW07) Encrypt(key *PublicKey, msg *Message, tree Node) (*Ciphertext, error) {
+	return algo.encrypt(key, msg, tree, algo.random)
+}
+
+// encrypt is Encrypt reading its randomness from random.
+func (algo *BSW07) encrypt(key *PublicKey, msg *Message, tree Node, random io.Reader) (*Ciphertext, error) {
+	pairing := algo.params.pairing
+
+	if err := algo.params.checkFingerprint(key.Fingerprint); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("G", key.H); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("GT", key.E, msg.M); err != nil {
+		return nil, err
+	}
+	powers := algo.publicPowers(key)
+
+	tree, err := expandTree(tree, algo.numericBits)
+	if err != nil {
+		return nil, err
+	}
+
 	// polynomials holds a mapping of Node to slice of coefficients for
 	// the polynomial of corresponding node.
 	// Length of each slice equals to Threshold of node
 	polynomials := make(map[Node]*polynomial)
 
-	// c1, c2 store the computed ciphertext of each of leaf attribute
-	c1 := make(map[string]*G)
-	c2 := make(map[string]*G)
+	// leaves and shares hold each leaf and q_y(0) in traversal order
+	var (
+		leaves []*leafNode
+		shares []*Zr
+	)
+	positions := leafPositions(tree)
 
 	// queue holds the children nodes which will be processed later.
 	queue := []Node{tree}
 
 	// randomly choose s
 	s := pairing.NewZr()
-	s.E.Rand()
+	if err := algo.randZrFrom(random, s); err != nil {
+		return nil, err
+	}
 
 	// Compute msg = M * e(g,g)^(a*s)
 	encMsg := pairing.NewGT()
 	// (e(g,g)^a)^s = e(g,g)^(a*s)
-	encMsg.E.PowZn(key.E.E, s.E)
+	encMsg.E.PowerZn(powers.e, s.E)
 	// M * e(g,g)^(a*s)
 	encMsg.E.Mul(msg.M.E, encMsg.E)
 
 	// Compute c = h^s
 	c := pairing.NewG()
-	c.E.PowZn(key.H.E, s.E)
+	c.E.PowerZn(powers.h, s.E)
 
 	// Breadth first traversal of tree
 	var current Node
@@ -96,22 +179,16 @@ func (algo *BSW07) Encrypt(key *PublicKey, msg *Message, tree Node) (*Ciphertext
 		// Randomly choose the rest of the coefficients to completely define q_x
 		for i := 1; i < len(polynomials[current].c); i++ {
 			polynomials[current].c[i] = pairing.NewZr()
-			polynomials[current].c[i].E.Rand()
+			if err := algo.randZrFrom(random, polynomials[current].c[i]); err != nil {
+				return nil, err
+			}
 		}
 
 		switch node := current.(type) {
 		case *leafNode:
-			// Compute c1 = g^q_y(0)
-			cY := pairing.NewG()
-			cY.E.PowZn(g.E, polynomials[current].c[0].E)
-
-			// Compute c2 = H(y)^q_y(0)
-			cY2 := pairing.NewG()
-			cY2.E.SetFromHash(hash([]byte(node.Attr)))
-			cY2.E.PowZn(cY2.E, polynomials[current].c[0].E)
-
-			c1[string(node.Attr)] = cY
-			c2[string(node.Attr)] = cY2
+			// Leaf components are computed once all shares are derived
+			leaves = append(leaves, node)
+			shares = append(shares, polynomials[current].c[0])
 		case *nonLeafNode:
 			// Enqueue the current node's children
 			queue = append(queue, node.Children...)
@@ -120,25 +197,105 @@ func (algo *BSW07) Encrypt(key *PublicKey, msg *Message, tree Node) (*Ciphertext
 		}
 	}
 
+	// c1, c2 store the computed ciphertext of each leaf, keyed by its position
+	// so that an attribute may appear in more than one leaf
+	c1 := make(map[int]*G)
+	c2 := make(map[int]*G)
+	cY, cY2 := algo.leafComponents(leaves, shares)
+	for i, leaf := range leaves {
+		c1[positions[leaf]] = cY[i]
+		c2[positions[leaf]] = cY2[i]
+	}
+
 	n, err := tree.MarshalJSON()
 	if err != nil {
 		return nil, err
 	}
 
-	return NewCiphertext(n, encMsg, c, c1, c2), nil
+	ct := NewCiphertext(n, encMsg, c, c1, c2)
+	ct.Fingerprint = algo.params.Fingerprint()
+
+	return ct, nil
+}
+
+// leafComponents computes c1 = g^q_y(0) and c2 = H(y)^q_y(0) for each leaf y
+// and its share q_y(0). The leaves are split among the workers of algo, and the
+// result does not depend on their number.
+func (algo *BSW07) leafComponents(leaves []*leafNode, shares []*Zr) ([]*G, []*G) {
+	pairing, gPower := algo.params.pairing, algo.params.gPower
+
+	c1 := make([]*G, len(leaves))
+	c2 := make([]*G, len(leaves))
+	compute := func(i int) {
+		// Compute c1 = g^q_y(0)
+		cY := pairing.NewG()
+		cY.E.PowerZn(gPower, shares[i].E)
+
+		// Compute c2 = H(y)^q_y(0)
+		cY2 := pairing.NewG()
+		cY2.E.SetFromHash(hash([]byte(leaves[i].Attr)))
+		cY2.E.PowZn(cY2.E, shares[i].E)
+
+		c1[i], c2[i] = cY, cY2
+	}
+
+	workers := algo.workers
+	if workers > len(leaves) {
+		workers = len(leaves)
+	}
+	if workers <= 1 {
+		for i := range leaves {
+			compute(i)
+		}
+		return c1, c2
+	}
+
+	// Worker w computes leaves w, w + workers, ...
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(leaves); i += workers {
+				compute(i)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	return c1, c2
 }
 
 // KeyGen takes as input a set of attributes and the master key, and generate
-// the corresponding decryption key
+// the corresponding decryption key. Numeric attributes such as "level = 5" are
+// expanded into their bit attributes, so attributes may not contain '#', the
+// separator of bit attributes, and no numeric attribute may have two values.
 func (algo *BSW07) KeyGen(msk *MasterKey, attrs map[string]struct{}) (*DecryptKey, error) {
+	pairing, gPower := algo.params.pairing, algo.params.gPower
+
+	if err := algo.params.checkFingerprint(msk.Fingerprint); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("G", msk.A); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("Zr", msk.B); err != nil {
+		return nil, err
+	}
+
+	attrs, err := expandAttributes(attrs, algo.numericBits)
+	if err != nil {
+		return nil, err
+	}
+
 	// randomly choose r
 	r := pairing.NewZr()
-	r.E.Rand()
+	if err := algo.randZr(r); err != nil {
+		return nil, err
+	}
 
 	// Compute d = g^((a+r)/b)
 	d := pairing.NewG()
 	// d = g^r
-	d.E.PowZn(g.E, r.E)
+	d.E.PowerZn(gPower, r.E)
 	// d = g^a * g^r = g^(a+r)
 	d.E.Mul(msk.A.E, d.E)
 	bReciprocal := pairing.NewZr()
@@ -148,18 +305,20 @@ func (algo *BSW07) KeyGen(msk *MasterKey, attrs map[string]struct{}) (*DecryptKe
 
 	// Compute f = g^(1/b)
 	f := pairing.NewG()
-	f.E.PowZn(g.E, bReciprocal.E)
+	f.E.PowerZn(gPower, bReciprocal.E)
 
 	d1 := make(map[string]*G)
 	d2 := make(map[string]*G)
 	// For each attribute
-	for attr := range attrs {
+	for _, attr := range sortedAttributes(attrs) {
 		// randomly choose rJ
 		rJ := pairing.NewZr()
-		rJ.E.Rand()
+		if err := algo.randZr(rJ); err != nil {
+			return nil, err
+		}
 		// Compute dJ = g^r * H(j)^rJ
 		dJ := pairing.NewG()
-		dJ.E.PowZn(g.E, r.E)
+		dJ.E.PowerZn(gPower, r.E)
 		h := pairing.NewG()
 		h.E.SetFromHash(hash([]byte(attr)))
 		h.E.PowZn(h.E, rJ.E)
@@ -167,21 +326,39 @@ func (algo *BSW07) KeyGen(msk *MasterKey, attrs map[string]struct{}) (*DecryptKe
 
 		// Compute dJ' = g^rJ
 		dJ2 := pairing.NewG()
-		dJ2.E.PowZn(g.E, rJ.E)
+		dJ2.E.PowerZn(gPower, rJ.E)
 
 		d1[string(attr)] = dJ
 		d2[string(attr)] = dJ2
 	}
 
-	return NewDecryptKey(attrs, d, f, d1, d2), nil
+	dk := NewDecryptKey(attrs, d, f, d1, d2)
+	dk.Fingerprint = algo.params.Fingerprint()
+
+	return dk, nil
 }
 
 // Delegate takes in a secret key and a set of attribute subset to the one in secret key,
 // and generate the corresponding delegated secret key
 func (algo *BSW07) Delegate(dk *DecryptKey, attrs map[string]struct{}) (*DecryptKey, error) {
+	pairing, gPower := algo.params.pairing, algo.params.gPower
+
+	if err := algo.params.checkFingerprint(dk.Fingerprint); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("G", dk.elements()...); err != nil {
+		return nil, err
+	}
+
+	attrs, err := expandAttributes(attrs, algo.numericBits)
+	if err != nil {
+		return nil, err
+	}
+
 	// randomly pick r
 	r := pairing.NewZr()
-	r.E.Rand()
+	if err := algo.randZr(r); err != nil {
+		return nil, err
+	}
 
 	// Compute d_1 = d_0*f^r
 	d := pairing.NewG()
@@ -190,19 +367,21 @@ func (algo *BSW07) Delegate(dk *DecryptKey, attrs map[string]struct{}) (*Decrypt
 
 	d1 := make(map[string]*G)
 	d2 := make(map[string]*G)
-	for attr := range attrs {
+	for _, attr := range sortedAttributes(attrs) {
 		if _, ok := dk.D1[string(attr)]; !ok {
 			return nil, ErrSubsetAttrNotExist
 		}
 
 		// Randomly choose rK
 		rK := pairing.NewZr()
-		rK.E.Rand()
+		if err := algo.randZr(rK); err != nil {
+			return nil, err
+		}
 
 		// Compute dK = dJ * g^r * H(k) ^rK
 		dK := pairing.NewG()
 		// g^r
-		dK.E.PowZn(g.E, r.E)
+		dK.E.PowerZn(gPower, r.E)
 		h := pairing.NewG()
 		h.E.SetFromHash(hash([]byte(attr)))
 		// H(k)^rK
@@ -215,7 +394,7 @@ func (algo *BSW07) Delegate(dk *DecryptKey, attrs map[string]struct{}) (*Decrypt
 		// Compute dK' = dJ' * g^rK
 		dK2 := pairing.NewG()
 		// g^rK
-		dK2.E.PowZn(g.E, rK.E)
+		dK2.E.PowerZn(gPower, rK.E)
 		// dJ' * g^rK
 		dK2.E.Mul(dk.D2[string(attr)].E, dK2.E)
 
@@ -223,100 +402,79 @@ func (algo *BSW07) Delegate(dk *DecryptKey, attrs map[string]struct{}) (*Decrypt
 		d2[string(attr)] = dK2
 	}
 
-	return NewDecryptKey(attrs, d, dk.F, d1, d2), nil
+	delegated := NewDecryptKey(attrs, d, dk.F, d1, d2)
+	delegated.Fingerprint = algo.params.Fingerprint()
+
+	return delegated, nil
 }
 
 // Decrypt takes ciphertext c and decryption key dk as input and returns the
 // decrypted message if attributes in dk Satisfy policy in ct.
 func (algo *BSW07) Decrypt(ct *Ciphertext, key *DecryptKey) (*Message, error) {
-	tree, err := NodeFromJSON(ct.Tree)
-	if err != nil {
+	if err := algo.params.checkFingerprint(ct.Fingerprint, key.Fingerprint); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("GT", ct.Msg); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("G", append(ct.elements(), key.elements()...)...); err != nil {
 		return nil, err
 	}
 
-	if !tree.Satisfy(key.S) {
-		return nil, ErrTreeNotSatisfied
-	}
-
-	a, err := algo.decryptNode(ct, key, tree)
+	m, err := algo.transform(ct, key)
 	if err != nil {
 		return nil, err
 	}
 
-	// Compute encMsg / (e(C, D)/A)
-	m := pairing.NewGT()
-	// e(C, D)
-	m.E.Pair(ct.C.E, key.D.E)
-	// e(C, D) / A
-	m.E.Div(m.E, a.E)
 	// encMsg / (e(C, D) / A)
 	m.E.Div(ct.Msg.E, m.E)
 
 	return &Message{m}, nil
 }
 
-func (algo *BSW07) decryptNode(ct *Ciphertext, key *DecryptKey, x Node) (*GT, error) {
-	switch node := x.(type) {
-	case *leafNode:
-		if _, ok := key.S[string(node.Attr)]; ok {
-			// Compute e(D_i, C_x)/e(D'_i, C'_x)
-			numerator := pairing.NewGT()
-			denominator := pairing.NewGT()
-			// e(D_i, C_x)
-			numerator.E.Pair(key.D1[string(node.Attr)].E, ct.C1[string(node.Attr)].E)
-			// e(D'_i, C'_x)
-			denominator.E.Pair(key.D2[string(node.Attr)].E, ct.C2[string(node.Attr)].E)
-			// e(D_i, C_x)/e(D'_i, C'_x)
-			numerator.E.Div(numerator.E, denominator.E)
-
-			return numerator, nil
-		}
-		return nil, ErrEncAttrNotExist
-	case *nonLeafNode:
-		type element struct {
-			index *Zr
-			fx    *GT
-		}
-		var sx []element
-		for _, child := range node.Children {
-			fz, _ := algo.decryptNode(ct, key, child)
-			if fz != nil {
-				index := pairing.NewZr()
-				index.E.SetInt32(int32(child.Index()))
-				sx = append(sx, element{
-					index,
-					fz,
-				})
-			}
-		}
-		if len(sx) < node.Threshold() {
-			return nil, ErrTreeNotSatisfied
-		}
+// transform computes e(C, D)/A = e(g,g)^(as) for key, where A = e(g,g)^(rs) is
+// recovered from the leaves of the tree of ct. Rather than interpolating in GT
+// at every gate, the Lagrange coefficients are flattened onto the leaves and
+// the whole quotient is evaluated as one product of pairings:
+//
+//	e(C, D) * prod_y e(D_j, C_y^(-c_y)) * e(D'_j, C'_y^(c_y))
+func (algo *BSW07) transform(ct *Ciphertext, key *DecryptKey) (*GT, error) {
+	pairing := algo.params.pairing
 
-		sx = sx[:node.Threshold()]
-
-		fx := pairing.NewGT()
-		fx.E.Set1()
-		for i, fz := range sx {
-			// Compute lagrange coefficient
-			coefficient := pairing.NewZr()
-			coefficient.E.Set1()
-			for j := range sx {
-				if i != j {
-					// polynomial interpolation
-					numerator := pairing.NewZr()
-					numerator.E.Sub(zero, sx[j].index.E)
-					denominator := pairing.NewZr()
-					denominator.E.Sub(fz.index.E, sx[j].index.E)
-					coefficient.E.Mul(coefficient.E, numerator.E.Div(numerator.E, denominator.E))
-				}
-			}
-			temp := pairing.NewGT()
-			temp.E.PowZn(fz.fx.E, coefficient.E)
-			fx.E.Mul(fx.E, temp.E)
+	tree, err := NodeFromJSON(ct.Tree)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the cheapest subtree satisfied by key is evaluated
+	costs := make(map[Node]int)
+	if _, ok := plan(tree, key.S, costs); !ok {
+		return nil, ErrTreeNotSatisfied
+	}
+
+	coefficients := make(map[*leafNode]*pbc.Element)
+	if err := lagrange(tree, costs, pairing.NewZr().E.Set1(), coefficients); err != nil {
+		return nil, err
+	}
+
+	positions := leafPositions(tree)
+	x := []*pbc.Element{ct.C.E}
+	y := []*pbc.Element{key.D.E}
+	for leaf, c := range coefficients {
+		cY, ok1 := ct.C1[positions[leaf]]
+		cY2, ok2 := ct.C2[positions[leaf]]
+		dJ, ok3 := key.D1[string(leaf.Attr)]
+		dJ2, ok4 := key.D2[string(leaf.Attr)]
+		if !ok1 || !ok2 || !ok3 || !ok4 {
+			return nil, ErrEncAttrNotExist
 		}
-		return fx, nil
-	default:
-		return nil, ErrUnknownNodeType
+
+		// e(D_j, C_y)^(-c) = e(D_j, C_y^(-c))
+		negative := c.NewFieldElement().Neg(c)
+		x = append(x, dJ.E, dJ2.E)
+		y = append(y, cY.E.NewFieldElement().PowZn(cY.E, negative), cY2.E.NewFieldElement().PowZn(cY2.E, c))
 	}
+
+	m := pairing.NewGT()
+	m.E.ProdPairSlice(x, y)
+
+	return m, nil
 }
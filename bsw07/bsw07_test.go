@@ -3,6 +3,7 @@ package bsw07
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"testing"
 )
 
@@ -24,7 +25,7 @@ func TestNewGPSW06(t *testing.T) {
 
 func TestGPSW06_Encrypt(t *testing.T) {
 	algo, _ = NewBSW07()
-	pk, msk = algo.Setup()
+	pk, msk, _ = algo.Setup()
 
 	tree := &leafNode{
 		"a",
@@ -81,9 +82,32 @@ func TestMsg_Marshal(t *testing.T) {
 	if !msg.M.E.Equals(msg2.M.E) {
 		t.Errorf("Message before marshal and after unmarshal differs.")
 	}
+
+	overflow := make([]byte, len(data))
+	for i := range overflow {
+		overflow[i] = 0xff
+	}
+
+	for _, v := range []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"short", data[1:], ErrElementLength},
+		{"long", append(append([]byte(nil), data...), 0), ErrElementLength},
+		{"empty", nil, ErrElementLength},
+		{"overflowing", overflow, ErrNonCanonical},
+	} {
+		if err := msg2.Unmarshal(v.data); err != v.err {
+			t.Errorf("Message %s unmarshaled with %v, expecting %v", v.name, err, v.err)
+		}
+	}
+	if !msg.M.E.Equals(msg2.M.E) {
+		t.Errorf("Malformed message changed the message.")
+	}
 }
 
-func eq(a, b map[string]*G) bool {
+func eq(a, b map[int]*G) bool {
 	if len(a) != len(b) {
 		return false
 	}
@@ -129,3 +153,119 @@ func TestCiphertext_Marshal(t *testing.T) {
 
 	t.Logf("%v", cipher)
 }
+
+func TestBSW07_Threshold(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+
+	tree, err := ParsePolicy("2 of (a, b, c)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	msg := NewMessage().Rand()
+	ct, err := algo.Encrypt(pk, msg, tree)
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting. Msg: %v.", err, msg)
+		return
+	}
+
+	dk, _ := algo.KeyGen(msk, map[string]struct{}{"a": {}, "c": {}})
+	plain, err := algo.Decrypt(ct, dk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+	} else if !plain.M.E.Equals(msg.M.E) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+
+	dk, _ = algo.KeyGen(msk, map[string]struct{}{"b": {}, "d": {}})
+	if _, err := algo.Decrypt(ct, dk); err != ErrTreeNotSatisfied {
+		t.Errorf("Ciphertext decrypted by key with 1 of 3 attributes: %v", err)
+	}
+}
+
+func TestBSW07_RepeatedAttribute(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+
+	tree, err := ParsePolicy("(a AND b) OR (a AND c)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	msg := NewMessage().Rand()
+	ct, err := algo.Encrypt(pk, msg, tree)
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting. Msg: %v.", err, msg)
+		return
+	}
+
+	for _, attrs := range []map[string]struct{}{
+		{"a": {}, "b": {}},
+		{"a": {}, "c": {}},
+	} {
+		dk, _ := algo.KeyGen(msk, attrs)
+		plain, err := algo.Decrypt(ct, dk)
+		if err != nil {
+			t.Errorf("Error (%v) during decryption with %v.", err, attrs)
+		} else if !plain.M.E.Equals(msg.M.E) {
+			t.Errorf("Message before encryption and after decryption with %v differs.", attrs)
+		}
+	}
+}
+
+func TestWithWorkers(t *testing.T) {
+	if _, err := NewBSW07(WithWorkers(0)); err != ErrInvalidWorkers {
+		t.Errorf("Zero workers accepted: %v", err)
+	}
+
+	sequential, _ := NewBSW07()
+	parallel, err := NewBSW07(WithWorkers(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Leaf components must not depend on the number of workers
+	var (
+		leaves []*leafNode
+		shares []*Zr
+	)
+	for i := 0; i < 30; i++ {
+		leaves = append(leaves, &leafNode{Attribute(fmt.Sprintf("a%d", i)), nil})
+		share := defaultParams.pairing.NewZr()
+		share.E.Rand()
+		shares = append(shares, share)
+	}
+
+	c1, c2 := sequential.leafComponents(leaves, shares)
+	p1, p2 := parallel.leafComponents(leaves, shares)
+	for i := range leaves {
+		if !c1[i].E.Equals(p1[i].E) || !c2[i].E.Equals(p2[i].E) {
+			t.Errorf("Components of leaf %d differ between sequential and parallel computation.", i)
+		}
+	}
+
+	pk, msk, _ := parallel.Setup()
+	tree, err := wideTree(60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs := make(map[string]struct{})
+	for i := 0; i < 60; i++ {
+		attrs[fmt.Sprintf("a%d", i)] = struct{}{}
+	}
+	dk, _ := parallel.KeyGen(msk, attrs)
+
+	msg := parallel.NewMessage().Rand()
+	ct, err := parallel.Encrypt(pk, msg, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := parallel.Decrypt(ct, dk); err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+	} else if !plain.M.E.Equals(msg.M.E) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+}
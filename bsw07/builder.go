@@ -0,0 +1,96 @@
+package bsw07
+
+// Leaf creates a leaf node holding attribute attr.
+func Leaf(attr string) Node {
+	return &leafNode{Attribute(attr), nil}
+}
+
+// And creates a gate which is satisfied only when all of its children are.
+func And(children ...Node) (Node, error) {
+	return buildGate(and, 0, children)
+}
+
+// Or creates a gate which is satisfied when any of its children is.
+func Or(children ...Node) (Node, error) {
+	return buildGate(or, 0, children)
+}
+
+// Threshold creates a gate which is satisfied when at least k of its children are.
+func Threshold(k int, children ...Node) (Node, error) {
+	return buildGate(threshold, k, children)
+}
+
+// buildGate validates children and attaches them to a new gate. Children must
+// be well-formed trees which are not attached to any other gate yet.
+func buildGate(gate operator, k int, children []Node) (Node, error) {
+	if len(children) == 0 {
+		return nil, ErrEmptyGate
+	}
+	if gate == threshold && (k < 1 || k > len(children)) {
+		return nil, ErrInvalidThreshold
+	}
+
+	seen := make(map[Node]struct{})
+	for _, child := range children {
+		if child == nil {
+			return nil, ErrNilNode
+		}
+		if err := validateNode(child, seen, make(map[Node]struct{})); err != nil {
+			return nil, err
+		}
+		if child.Parent() != nil {
+			return nil, ErrNodeReused
+		}
+	}
+
+	n := newGate(gate, append([]Node(nil), children...))
+	n.K = k
+	return n, nil
+}
+
+// validateNode walks the tree rooted at x and checks that no node appears twice,
+// either as its own ancestor or in two places of the tree.
+func validateNode(x Node, seen, ancestors map[Node]struct{}) error {
+	if _, ok := ancestors[x]; ok {
+		return ErrCyclicTree
+	}
+	if _, ok := seen[x]; ok {
+		return ErrNodeReused
+	}
+	seen[x] = struct{}{}
+
+	switch node := x.(type) {
+	case *leafNode:
+		if node == nil {
+			return ErrNilNode
+		}
+		if node.Attr == "" {
+			return ErrEmptyAttribute
+		}
+	case *nonLeafNode:
+		if node == nil {
+			return ErrNilNode
+		}
+		if len(node.Children) == 0 {
+			return ErrEmptyGate
+		}
+
+		ancestors[x] = struct{}{}
+		for _, child := range node.Children {
+			if child == nil {
+				return ErrNilNode
+			}
+			if err := validateNode(child, seen, ancestors); err != nil {
+				return err
+			}
+			if child.Parent() != x {
+				return ErrNodeReused
+			}
+		}
+		delete(ancestors, x)
+	default:
+		return ErrUnknownNodeType
+	}
+
+	return nil
+}
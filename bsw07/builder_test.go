@@ -0,0 +1,77 @@
+package bsw07
+
+import "testing"
+
+func TestBuilder(t *testing.T) {
+	sub, err := And(Leaf("c"), Leaf("d"))
+	if err != nil {
+		t.Errorf("Error (%v) during building and gate.", err)
+		return
+	}
+
+	tree, err := Or(Leaf("a"), Leaf("b"), sub)
+	if err != nil {
+		t.Errorf("Error (%v) during building or gate.", err)
+		return
+	}
+
+	parsed, err := ParsePolicy("a OR b OR (c AND d)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	if !tree.Equal(parsed) {
+		t.Errorf("Built tree does not match with parsed policy: %s", tree)
+	}
+
+	if sub.Parent() != tree || sub.Index() != 3 {
+		t.Errorf("Built tree has wrong parent or index")
+	}
+
+	th, err := Threshold(2, Leaf("a"), Leaf("b"), Leaf("c"))
+	if err != nil {
+		t.Errorf("Error (%v) during building threshold gate.", err)
+		return
+	}
+
+	if th.Threshold() != 2 {
+		t.Errorf("Built threshold gate has threshold %d", th.Threshold())
+	}
+}
+
+func TestBuilder_Error(t *testing.T) {
+	leaf := Leaf("a")
+	if _, err := And(leaf, leaf); err != ErrNodeReused {
+		t.Errorf("Reused leaf accepted: %v", err)
+	}
+
+	tree, _ := Or(leaf, Leaf("b"))
+	if _, err := And(leaf, Leaf("c")); err != ErrNodeReused {
+		t.Errorf("Attached leaf accepted: %v", err)
+	}
+
+	// Close a loop by hand, which the builders never produce themselves
+	gate := tree.(*nonLeafNode)
+	gate.Children = append(gate.Children, gate)
+	gate.parent = gate
+	if _, err := And(Leaf("c"), &nonLeafNode{or, 0, nil, []Node{gate}}); err != ErrCyclicTree {
+		t.Errorf("Cyclic tree accepted: %v", err)
+	}
+
+	if _, err := Or(); err != ErrEmptyGate {
+		t.Errorf("Empty gate accepted: %v", err)
+	}
+
+	if _, err := And(Leaf("a"), nil); err != ErrNilNode {
+		t.Errorf("Nil child accepted: %v", err)
+	}
+
+	if _, err := Threshold(3, Leaf("a"), Leaf("b")); err != ErrInvalidThreshold {
+		t.Errorf("Threshold out of range accepted: %v", err)
+	}
+
+	if _, err := Or(Leaf("a"), Leaf("")); err != ErrEmptyAttribute {
+		t.Errorf("Invalid attribute accepted: %v", err)
+	}
+}
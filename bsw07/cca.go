@@ -0,0 +1,91 @@
+package bsw07
+
+// EncryptCCA is like Encrypt, but secure against chosen-ciphertext attacks by the
+// transform of Fujisaki and Okamoto. All randomness of the ciphertext is derived
+// by hashing msg together with the public key and the tree, so that DecryptCCA
+// can encrypt the message it recovers once more and reject every ciphertext
+// which was not produced this way. As the ciphertext of a message under a policy
+// is always the same, msg must be unpredictable, such as a random message whose
+// DeriveKey seals the actual payload.
+func (algo *BSW07) EncryptCCA(key *PublicKey, msg *Message, tree Node) (*Ciphertext, error) {
+	if err := algo.params.checkFingerprint(key.Fingerprint); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("G", key.H); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("GT", key.E, msg.M); err != nil {
+		return nil, err
+	}
+
+	tree, err := expandTree(tree, algo.numericBits)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := tree.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return algo.encrypt(key, msg, tree, ccaRandom(key, msg, n))
+}
+
+// DecryptCCA takes the public key, a ciphertext ct produced by EncryptCCA and
+// decryption key dk as input and returns the decrypted message if attributes in
+// dk Satisfy policy in ct. ErrCiphertextRejected is returned if ct does not
+// re-encrypt to itself, which is the case for every modified ciphertext.
+func (algo *BSW07) DecryptCCA(key *PublicKey, ct *Ciphertext, dk *DecryptKey) (*Message, error) {
+	msg, err := algo.Decrypt(ct, dk)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := NodeFromJSON(ct.Tree)
+	if err != nil {
+		return nil, err
+	}
+
+	expected, err := algo.encrypt(key, msg, tree, ccaRandom(key, msg, ct.Tree))
+	if err != nil {
+		return nil, err
+	}
+
+	if !sameCiphertext(ct, expected) {
+		return nil, ErrCiphertextRejected
+	}
+
+	return msg, nil
+}
+
+// ccaRandom returns the source of randomness of EncryptCCA, seeded by the hash of
+// the public key, the message and the encoded tree.
+func ccaRandom(key *PublicKey, msg *Message, tree []byte) *hashReader {
+	var seed []byte
+	seed = append(seed, "BSW07 Fujisaki-Okamoto"...)
+	for _, part := range [][]byte{key.H.E.Bytes(), key.E.E.Bytes(), msg.Marshal(), tree} {
+		seed = append(seed, hash(part)...)
+	}
+	return newHashReader(hash(seed))
+}
+
+// sameCiphertext reports whether a and b consist of the same tree and elements.
+func sameCiphertext(a, b *Ciphertext) bool {
+	if string(a.Tree) != string(b.Tree) || !a.Msg.E.Equals(b.Msg.E) || !a.C.E.Equals(b.C.E) {
+		return false
+	}
+
+	if len(a.C1) != len(b.C1) || len(a.C2) != len(b.C2) {
+		return false
+	}
+	for i, el := range a.C1 {
+		if other, ok := b.C1[i]; !ok || !el.E.Equals(other.E) {
+			return false
+		}
+	}
+	for i, el := range a.C2 {
+		if other, ok := b.C2[i]; !ok || !el.E.Equals(other.E) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,71 @@
+package bsw07
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBSW07_EncryptCCA(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+
+	attrs := make(map[string]struct{})
+	attrs["3"] = struct{}{}
+	attrs["4"] = struct{}{}
+
+	dk, err := algo.KeyGen(msk, attrs)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption key generation.", err)
+		return
+	}
+
+	msg := algo.NewMessage().Rand()
+	ct, err := algo.EncryptCCA(pk, msg, buildTree())
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting.", err)
+		return
+	}
+
+	data, err := json.Marshal(ct)
+	if err != nil {
+		t.Errorf("Error (%v) during marshaling", err)
+		return
+	}
+
+	ct2 := &Ciphertext{}
+	if err := json.Unmarshal(data, ct2); err != nil {
+		t.Errorf("Error (%v) during unmarshaling", err)
+		return
+	}
+
+	plain, err := algo.DecryptCCA(pk, ct2, dk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+		return
+	}
+
+	if !plain.M.E.Equals(msg.M.E) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+
+	// Multiplying the encrypted message is noticed, unlike with Decrypt
+	factor := algo.NewMessage().Rand()
+	ct2.Msg.E.Mul(ct2.Msg.E, factor.M.E)
+	if _, err := algo.DecryptCCA(pk, ct2, dk); err != ErrCiphertextRejected {
+		t.Errorf("Malleated ciphertext was not rejected: %v", err)
+	}
+
+	// So is replacing any leaf component
+	ct3 := &Ciphertext{}
+	json.Unmarshal(data, ct3)
+	ct3.C1[0].E.Mul(ct3.C1[0].E, ct3.C1[0].E)
+	if _, err := algo.DecryptCCA(pk, ct3, dk); err != ErrCiphertextRejected {
+		t.Errorf("Ciphertext with modified leaf component was not rejected: %v", err)
+	}
+
+	// Ciphertexts of Encrypt do not pass the check
+	ct4, _ := algo.Encrypt(pk, msg, buildTree())
+	if _, err := algo.DecryptCCA(pk, ct4, dk); err != ErrCiphertextRejected {
+		t.Errorf("Ciphertext of Encrypt was not rejected: %v", err)
+	}
+}
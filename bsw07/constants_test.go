@@ -5,7 +5,7 @@ import (
 )
 
 func TestConstants(t *testing.T) {
-	if _, _, err := loadParams(_paramString, _g); err != nil {
+	if _, err := NewParams(_paramString, _g); err != nil {
 		t.Error(err)
 	}
 }
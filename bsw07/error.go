@@ -1,12 +1,68 @@
 package bsw07
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
+	ErrAsymmetricParams   = errors.New("pairing parameters are not symmetric")
+	ErrBadBinary          = errors.New("malformed binary encoding")
+	ErrBadEnvelope        = errors.New("malformed envelope")
+	ErrBadPEM             = errors.New("malformed PEM block")
+	ErrBinaryType         = errors.New("binary encoding is of another type")
+	ErrBinaryVersion      = errors.New("unsupported version of binary encoding")
 	ErrBadNodeJSON        = errors.New("bad structured json for node")
+	ErrCiphertextRejected = errors.New("ciphertext does not re-encrypt to itself")
+	ErrCyclicTree         = errors.New("access tree contains a cycle")
+	ErrEmptyAttribute     = errors.New("attribute of leaf node is empty")
+	ErrEmptyGate          = errors.New("gate has no children")
+	ErrElementLength      = errors.New("encoding of element has the wrong length")
 	ErrEncAttrNotExist    = errors.New("encrypted key not exist for such attribute")
-	ErrInvalidG           = errors.New("could not find well-formed string describing g")
+	ErrEnvelopeAuth       = errors.New("envelope payload failed authentication")
+	ErrIdentityElement    = errors.New("element is the identity")
+	ErrInvalidG           = errors.New("could not find well-formed string describing a generator g")
+	ErrInvalidNumericBits = errors.New("bit width of numeric attributes must be between 1 and 64")
+	ErrInvalidWorkers     = errors.New("number of workers must be positive")
+	ErrInvalidThreshold   = errors.New("threshold of gate must be between 1 and number of children")
+	ErrMissingElement     = errors.New("element is missing")
+	ErrMissingFingerprint = errors.New("encoding carries no fingerprint of its pairing parameters")
+	ErrNilNode            = errors.New("node is nil")
+	ErrNilRandom          = errors.New("source of randomness is nil")
+	ErrNilParams          = errors.New("params is nil")
+	ErrNodeReused         = errors.New("node is already part of an access tree")
+	ErrNonCanonical       = errors.New("encoding of element is not canonical")
+	ErrNotInSubgroup      = errors.New("element is not in the subgroup of order r")
+	ErrNumericComparison  = errors.New("numeric attributes of keys must be of the form name = value")
+	ErrNumericConflict    = errors.New("numeric attribute is given more than one value")
+	ErrNumericOutOfRange  = errors.New("numeric attribute out of range of bit width")
+	ErrParamsMismatch     = errors.New("element does not belong to the pairing parameters of the scheme")
+	ErrParamsVersion      = errors.New("unsupported version of parameter file")
+	ErrReservedAttribute  = errors.New("attribute contains '#', which is reserved for bits of numeric attributes")
+	ErrUnknownField       = errors.New("unknown field of element")
 	ErrUnknownNodeType    = errors.New("unknown node type")
+	ErrWrongField         = errors.New("element is not of the field expected in its place")
 	ErrTreeNotSatisfied   = errors.New("ciphertext does not Satisfy decryption key policy")
 	ErrSubsetAttrNotExist = errors.New("specified attribute does not exist in superset")
+
+	ErrExpectingCiphertext   = errors.New("data provided is not a ciphertext")
+	ErrExpectingEnvelope     = errors.New("data provided is not an envelope")
+	ErrExpectingMasterKey    = errors.New("key provided is not a master key")
+	ErrExpectingPrivateKey   = errors.New("key provided is not a private key")
+	ErrExpectingPublicKey    = errors.New("key provided is not a public key")
+	ErrExpectingRetrievalKey = errors.New("key provided is not a retrieval key")
+	ErrExpectingTransformKey = errors.New("key provided is not a transform key")
+
+	ErrExpectingPartialCiphertext = errors.New("data provided is not a partial ciphertext")
 )
+
+// PolicySyntaxError reports a malformed policy string and the byte offset at
+// which the problem was found.
+type PolicySyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *PolicySyntaxError) Error() string {
+	return fmt.Sprintf("policy syntax error at offset %d: %s", e.Offset, e.Msg)
+}
@@ -0,0 +1,95 @@
+package bsw07
+
+import (
+	"crypto/aes"
+	cryptocipher "crypto/cipher"
+	"io"
+)
+
+// Envelope holds a byte payload sealed under a fresh symmetric key, together
+// with the ciphertext policy encryption of that key.
+type Envelope struct {
+	Key   *Ciphertext `json:"key"`
+	Nonce []byte      `json:"nonce"`
+	Data  []byte      `json:"data"`
+}
+
+func NewEnvelope(key *Ciphertext, nonce, data []byte) *Envelope {
+	return &Envelope{
+		Key:   key,
+		Nonce: nonce,
+		Data:  data,
+	}
+}
+
+// DeriveKey derives a 256-bit symmetric key from msg.
+func (msg *Message) DeriveKey() []byte {
+	return hash(msg.Marshal())
+}
+
+func newAEAD(key []byte) (cryptocipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cryptocipher.NewGCM(block)
+}
+
+// EncryptBytes takes as input the public key, an arbitrary plaintext and the access
+// structure tree, and output an envelope which only keys satisfying tree can open.
+func (algo *BSW07) EncryptBytes(key *PublicKey, plaintext []byte, tree Node) (*Envelope, error) {
+	// Encapsulate a random message, which the symmetric key is derived from
+	msg, err := algo.randMessage()
+	if err != nil {
+		return nil, err
+	}
+	ct, err := algo.Encrypt(key, msg, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(msg.DeriveKey())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(algo.random, nonce); err != nil {
+		return nil, err
+	}
+
+	// Bind the policy to the payload
+	data := aead.Seal(nil, nonce, plaintext, ct.Tree)
+
+	return NewEnvelope(ct, nonce, data), nil
+}
+
+// DecryptBytes takes envelope env and decryption key dk as input and returns the
+// original plaintext if attributes in dk Satisfy policy in env.
+func (algo *BSW07) DecryptBytes(env *Envelope, key *DecryptKey) ([]byte, error) {
+	if env.Key == nil {
+		return nil, ErrBadEnvelope
+	}
+
+	msg, err := algo.Decrypt(env.Key, key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(msg.DeriveKey())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, ErrBadEnvelope
+	}
+
+	plaintext, err := aead.Open(nil, env.Nonce, env.Data, env.Key.Tree)
+	if err != nil {
+		return nil, ErrEnvelopeAuth
+	}
+
+	return plaintext, nil
+}
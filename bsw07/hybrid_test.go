@@ -0,0 +1,80 @@
+package bsw07
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestBSW07_EncryptBytes(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+
+	attrs := make(map[string]struct{})
+	attrs["3"] = struct{}{}
+	attrs["4"] = struct{}{}
+
+	dk, err := algo.KeyGen(msk, attrs)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption key generation.", err)
+		return
+	}
+
+	plaintext := []byte("attribute-based hybrid encryption")
+	env, err := algo.EncryptBytes(pk, plaintext, buildTree())
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting.", err)
+		return
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Errorf("Error (%v) during marshaling", err)
+		return
+	}
+
+	env2 := Envelope{}
+	if err := json.Unmarshal(data, &env2); err != nil {
+		t.Errorf("Error (%v) during unmarshaling", err)
+		return
+	}
+
+	plain, err := algo.DecryptBytes(&env2, dk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+		return
+	}
+
+	if !bytes.Equal(plain, plaintext) {
+		t.Errorf("Plaintext before encryption and after decryption differs.")
+	}
+
+	env2.Data[0] ^= 1
+	if _, err := algo.DecryptBytes(&env2, dk); err != ErrEnvelopeAuth {
+		t.Errorf("Tampered envelope was not rejected: %v", err)
+	}
+}
+
+func TestBSW07_DecryptBytes(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+
+	attrs := make(map[string]struct{})
+	attrs["3"] = struct{}{}
+
+	dk, err := algo.KeyGen(msk, attrs)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption key generation.", err)
+		return
+	}
+
+	env, err := algo.EncryptBytes(pk, []byte("secret"), buildTree())
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting.", err)
+		return
+	}
+
+	if _, err := algo.DecryptBytes(env, dk); err != ErrTreeNotSatisfied {
+		t.Errorf("Envelope opened by key not satisfying policy: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package bsw07
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Nik-U/pbc"
+)
+
+// wideTree returns a policy over n attributes a0, a1, ... in gates of five. More
+// than half of the gates and of the children of each gate are required.
+func wideTree(n int) (Node, error) {
+	var gates []string
+	for i := 0; i < n; i += 5 {
+		var leaves []string
+		for j := i; j < i+5 && j < n; j++ {
+			leaves = append(leaves, fmt.Sprintf("a%d", j))
+		}
+		gates = append(gates, fmt.Sprintf("%d of (%s)", (len(leaves)+1)/2, strings.Join(leaves, ", ")))
+	}
+	return ParsePolicy(fmt.Sprintf("%d of (%s)", len(gates)/2+1, strings.Join(gates, ", ")))
+}
+
+// decryptRecursive is Decrypt interpolating in GT at every gate, as done before
+// the Lagrange coefficients were flattened.
+func decryptRecursive(algo *BSW07, ct *Ciphertext, key *DecryptKey) (*Message, error) {
+	pairing := algo.params.pairing
+
+	tree, err := NodeFromJSON(ct.Tree)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := leafPositions(tree)
+	var decryptNode func(x Node) *GT
+	decryptNode = func(x Node) *GT {
+		switch node := x.(type) {
+		case *leafNode:
+			if _, ok := key.S[string(node.Attr)]; !ok {
+				return nil
+			}
+			numerator := pairing.NewGT()
+			denominator := pairing.NewGT()
+			numerator.E.Pair(key.D1[string(node.Attr)].E, ct.C1[positions[node]].E)
+			denominator.E.Pair(key.D2[string(node.Attr)].E, ct.C2[positions[node]].E)
+			numerator.E.Div(numerator.E, denominator.E)
+			return numerator
+		case *nonLeafNode:
+			var indices []int
+			var fxs []*GT
+			for _, child := range node.Children {
+				if fz := decryptNode(child); fz != nil && len(fxs) < node.Threshold() {
+					indices = append(indices, child.Index())
+					fxs = append(fxs, fz)
+				}
+			}
+			if len(fxs) < node.Threshold() {
+				return nil
+			}
+
+			fx := pairing.NewGT()
+			fx.E.Set1()
+			for i := range fxs {
+				coefficient := pairing.NewZr()
+				coefficient.E.Set1()
+				for j := range fxs {
+					if i != j {
+						numerator := pairing.NewZr()
+						numerator.E.SetInt32(int32(-indices[j]))
+						denominator := pairing.NewZr()
+						denominator.E.SetInt32(int32(indices[i] - indices[j]))
+						coefficient.E.Mul(coefficient.E, numerator.E.Div(numerator.E, denominator.E))
+					}
+				}
+				temp := pairing.NewGT()
+				temp.E.PowZn(fxs[i].E, coefficient.E)
+				fx.E.Mul(fx.E, temp.E)
+			}
+			return fx
+		}
+		return nil
+	}
+
+	a := decryptNode(tree)
+	if a == nil {
+		return nil, ErrTreeNotSatisfied
+	}
+
+	m := pairing.NewGT()
+	m.E.Pair(ct.C.E, key.D.E)
+	m.E.Div(m.E, a.E)
+	m.E.Div(ct.Msg.E, m.E)
+	return &Message{m}, nil
+}
+
+func TestLagrange(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+
+	tree, err := wideTree(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := algo.NewMessage().Rand()
+	ct, err := algo.Encrypt(pk, msg, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		attrs    []string
+		expected bool
+	}{
+		{[]string{"a0", "a1", "a2", "a5", "a6", "a7", "a10", "a11", "a12"}, true},
+		{[]string{"a1", "a2", "a4", "a6", "a8", "a9", "a15", "a17", "a19"}, true},
+		{[]string{"a0", "a1", "a5", "a6", "a10", "a11", "a15"}, false},
+	}
+
+	for _, test := range tests {
+		attrs := make(map[string]struct{})
+		for _, attr := range test.attrs {
+			attrs[attr] = struct{}{}
+		}
+		dk, err := algo.KeyGen(msk, attrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		plain, err := algo.Decrypt(ct, dk)
+		if !test.expected {
+			if err != ErrTreeNotSatisfied {
+				t.Errorf("%v: unsatisfying key accepted: %v", test.attrs, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: error (%v) during decryption.", test.attrs, err)
+		} else if !plain.M.E.Equals(msg.M.E) {
+			t.Errorf("%v: message before encryption and after decryption differs.", test.attrs)
+		}
+
+		if reference, err := decryptRecursive(algo, ct, dk); err != nil || !reference.M.E.Equals(plain.M.E) {
+			t.Errorf("%v: flattened and recursive decryption differ: %v", test.attrs, err)
+		}
+	}
+}
+
+func BenchmarkBSW07_Decrypt(b *testing.B) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+
+	for _, n := range []int{10, 50, 200} {
+		tree, err := wideTree(n)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		attrs := make(map[string]struct{})
+		for i := 0; i < n; i++ {
+			attrs[fmt.Sprintf("a%d", i)] = struct{}{}
+		}
+		dk, err := algo.KeyGen(msk, attrs)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ct, err := algo.Encrypt(pk, algo.NewMessage().Rand(), tree)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("leaves=%d/flattened", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := algo.Decrypt(ct, dk); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("leaves=%d/recursive", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := decryptRecursive(algo, ct, dk); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	var tests = []struct {
+		policy string
+		attrs  []string
+		leaves []string // leaves of the cheapest satisfied subtree
+	}{
+		{"(b AND c) OR a", []string{"a", "b", "c"}, []string{"a"}},
+		{"a OR (b AND c)", []string{"b", "c"}, []string{"b", "c"}},
+		{"2 of ((a AND b AND c), d, (e OR f))", []string{"a", "b", "c", "e", "f"}, []string{"e", "a", "b", "c"}},
+		{"2 of ((a AND b AND c), d, (e OR f))", []string{"a", "b", "c", "d", "f"}, []string{"d", "f"}},
+		{"(a AND b) OR (c AND d)", []string{"a", "c"}, nil},
+	}
+
+	for _, test := range tests {
+		tree, err := ParsePolicy(test.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		attrs := make(map[string]struct{})
+		for _, attr := range test.attrs {
+			attrs[attr] = struct{}{}
+		}
+
+		costs := make(map[Node]int)
+		cost, ok := plan(tree, attrs, costs)
+		if ok != (test.leaves != nil) {
+			t.Errorf("%s with %v: satisfied %v, expected %v", test.policy, test.attrs, ok, test.leaves != nil)
+			continue
+		} else if !ok {
+			continue
+		}
+
+		coefficients := make(map[*leafNode]*pbc.Element)
+		if err := lagrange(tree, costs, defaultParams.pairing.P.NewZr().Set1(), coefficients); err != nil {
+			t.Errorf("%s with %v: error (%v) during computing coefficients", test.policy, test.attrs, err)
+			continue
+		}
+
+		var leaves []string
+		for leaf := range coefficients {
+			leaves = append(leaves, string(leaf.Attr))
+		}
+		sort.Strings(leaves)
+		expected := append([]string(nil), test.leaves...)
+		sort.Strings(expected)
+
+		if cost != len(expected) || strings.Join(leaves, ",") != strings.Join(expected, ",") {
+			t.Errorf("%s with %v: evaluated leaves %v at cost %d, expected %v", test.policy, test.attrs, leaves, cost, expected)
+		}
+	}
+}
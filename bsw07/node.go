@@ -2,6 +2,8 @@ package bsw07
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 )
 
 type operator uint
@@ -9,6 +11,7 @@ type operator uint
 const (
 	or operator = iota
 	and
+	threshold
 )
 
 type Node interface {
@@ -17,8 +20,10 @@ type Node interface {
 	Satisfy(map[string]struct{}) bool
 	Threshold() int
 	Equal(Node) bool
+	String() string
 	MarshalJSON() ([]byte, error)
 	UnmarshalJSON([]byte) error
+	MarshalBinary() ([]byte, error)
 }
 
 type leafNode struct {
@@ -28,6 +33,7 @@ type leafNode struct {
 
 type nonLeafNode struct {
 	Gate     operator
+	K        int // number of children required by a threshold gate
 	parent   Node
 	Children []Node
 }
@@ -38,11 +44,13 @@ type trimLeaf struct {
 
 type trimNonLeaf struct {
 	Gate     operator `json:"gate"`
+	K        int      `json:"k,omitempty"`
 	Children []Node   `json:"children"`
 }
 
 type rawNonLeaf struct {
 	Gate     operator          `json:"gate"`
+	K        int               `json:"k,omitempty"`
 	Children []json.RawMessage `json:"children"`
 }
 
@@ -82,6 +90,11 @@ func (l *leafNode) Equal(node Node) bool {
 	}
 }
 
+// String formats l in the policy language accepted by ParsePolicy.
+func (l *leafNode) String() string {
+	return formatAttr(string(l.Attr))
+}
+
 func (l *leafNode) MarshalJSON() ([]byte, error) {
 	return json.Marshal(trimLeaf{l.Attr})
 }
@@ -116,23 +129,13 @@ func (n *nonLeafNode) Parent() Node {
 }
 
 func (n *nonLeafNode) Satisfy(attrs map[string]struct{}) bool {
-	result := false
-
-	switch n.Gate {
-	case or:
-		for i := 0; i < len(n.Children); i++ {
-			if n.Children[i].Satisfy(attrs) {
-				return true
-			}
+	satisfied := 0
+	for i := 0; i < len(n.Children) && satisfied < n.Threshold(); i++ {
+		if n.Children[i].Satisfy(attrs) {
+			satisfied++
 		}
-		return false
-	case and:
-		for i := 0; i < len(n.Children) && n.Children[i].Satisfy(attrs); i, result = i+1, true {
-		}
-		return result
-	default:
-		return false
 	}
+	return len(n.Children) > 0 && satisfied >= n.Threshold()
 }
 
 func (n *nonLeafNode) Threshold() int {
@@ -141,17 +144,39 @@ func (n *nonLeafNode) Threshold() int {
 		return 1
 	case and:
 		return len(n.Children)
+	case threshold:
+		return n.K
 	default:
 		return 1
 	}
 }
 
+// String formats n in the policy language accepted by ParsePolicy.
+func (n *nonLeafNode) String() string {
+	children := make([]string, len(n.Children))
+	for i, child := range n.Children {
+		children[i] = child.String()
+		if _, ok := child.(*nonLeafNode); ok {
+			children[i] = "(" + children[i] + ")"
+		}
+	}
+
+	switch {
+	case len(children) > 1 && n.Gate == or:
+		return strings.Join(children, " OR ")
+	case len(children) > 1 && n.Gate == and:
+		return strings.Join(children, " AND ")
+	default:
+		return fmt.Sprintf("%d of (%s)", n.Threshold(), strings.Join(children, ", "))
+	}
+}
+
 func (n *nonLeafNode) MarshalJSON() ([]byte, error) {
-	return json.Marshal(trimNonLeaf{n.Gate, n.Children})
+	return json.Marshal(trimNonLeaf{n.Gate, n.K, n.Children})
 }
 
 func (n *nonLeafNode) UnmarshalJSON(data []byte) error {
-	var tnl = rawNonLeaf{or, make([]json.RawMessage, 0)}
+	var tnl = rawNonLeaf{or, 0, make([]json.RawMessage, 0)}
 	if err := json.Unmarshal(data, &tnl); err != nil {
 		return err
 	}
@@ -160,6 +185,17 @@ func (n *nonLeafNode) UnmarshalJSON(data []byte) error {
 		return ErrBadNodeJSON
 	}
 
+	switch tnl.Gate {
+	case or, and:
+		tnl.K = 0
+	case threshold:
+		if tnl.K < 1 || tnl.K > len(tnl.Children) {
+			return ErrInvalidThreshold
+		}
+	default:
+		return ErrBadNodeJSON
+	}
+
 	children := make([]Node, 0)
 
 	for i := range tnl.Children {
@@ -177,6 +213,7 @@ func (n *nonLeafNode) UnmarshalJSON(data []byte) error {
 	}
 
 	n.Gate = tnl.Gate
+	n.K = tnl.K
 	n.Children = children
 
 	return nil
@@ -187,7 +224,7 @@ func (n *nonLeafNode) Equal(node Node) bool {
 	case *leafNode:
 		return false
 	case *nonLeafNode:
-		if n.Gate != n2.Gate || len(n.Children) != len(n2.Children) {
+		if n.Gate != n2.Gate || n.K != n2.K || len(n.Children) != len(n2.Children) {
 			return false
 		}
 		for i := range n.Children {
@@ -201,7 +238,47 @@ func (n *nonLeafNode) Equal(node Node) bool {
 	}
 }
 
+// AttributeOf returns the attribute of n and whether n is a leaf node.
+func AttributeOf(n Node) (Attribute, bool) {
+	if l, ok := n.(*leafNode); ok {
+		return l.Attr, true
+	}
+	return "", false
+}
+
+// ChildrenOf returns the children of n, which are empty if n is a leaf node.
+func ChildrenOf(n Node) []Node {
+	if n, ok := n.(*nonLeafNode); ok {
+		return n.Children
+	}
+	return nil
+}
+
+// leafPositions numbers the leaves of tree from left to right, starting at 0.
+func leafPositions(tree Node) map[*leafNode]int {
+	positions := make(map[*leafNode]int)
+
+	var walk func(Node)
+	walk = func(x Node) {
+		switch node := x.(type) {
+		case *leafNode:
+			positions[node] = len(positions)
+		case *nonLeafNode:
+			for _, child := range node.Children {
+				walk(child)
+			}
+		}
+	}
+	walk(tree)
+
+	return positions
+}
+
 func NodeFromJSON(data []byte) (Node, error) {
+	if len(data) < 3 {
+		return nil, ErrBadNodeJSON
+	}
+
 	switch data[2] {
 	case 'a':
 		// Try leaf node
@@ -212,7 +289,7 @@ func NodeFromJSON(data []byte) (Node, error) {
 		return l, nil
 	case 'g':
 		// Try non leaf node
-		n1 := &nonLeafNode{or, nil, make([]Node, 0)}
+		n1 := &nonLeafNode{or, 0, nil, make([]Node, 0)}
 		if err := n1.UnmarshalJSON(data); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,182 @@
+package bsw07
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultNumericBits is the bit width of numeric attributes unless configured
+// with WithNumericBits.
+const DefaultNumericBits = 32
+
+// numericAttr matches numeric attributes such as "level = 5" in keys, and
+// comparisons such as "level >= 3" in policies.
+var numericAttr = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_.]*)\s*(<=|>=|<|>|=)\s*([0-9]+)\s*$`)
+
+// WithNumericBits sets the bit width of numeric attributes to bits.
+func WithNumericBits(bits int) Option {
+	return func(algo *BSW07) error {
+		if bits < 1 || bits > 64 {
+			return ErrInvalidNumericBits
+		}
+		algo.numericBits = bits
+		return nil
+	}
+}
+
+func parseNumeric(attr string) (name, op string, value uint64, ok bool, err error) {
+	m := numericAttr.FindStringSubmatch(attr)
+	if m == nil {
+		return "", "", 0, false, nil
+	}
+
+	value, err = strconv.ParseUint(m[3], 10, 64)
+	if err != nil {
+		return "", "", 0, false, ErrNumericOutOfRange
+	}
+
+	return m[1], m[2], value, true, nil
+}
+
+// bitAttr names the attribute stating that bit i of numeric attribute name is b.
+// Other attributes may not contain '#', so that they cannot pose as such bits.
+func bitAttr(name string, i int, b uint64) string {
+	return fmt.Sprintf("%s#%d=%d", name, i, b)
+}
+
+// expandAttributes replaces each numeric attribute "name = value" in attrs by
+// one attribute per bit of value. Attributes containing '#' are rejected with
+// ErrReservedAttribute, and numeric attributes given two values with
+// ErrNumericConflict, as the bits of both would satisfy comparisons neither does.
+func expandAttributes(attrs map[string]struct{}, bits int) (map[string]struct{}, error) {
+	expanded := make(map[string]struct{})
+	values := make(map[string]uint64)
+	for attr := range attrs {
+		if strings.Contains(attr, "#") {
+			return nil, ErrReservedAttribute
+		}
+
+		name, op, value, ok, err := parseNumeric(attr)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			expanded[attr] = struct{}{}
+			continue
+		} else if op != "=" {
+			return nil, ErrNumericComparison
+		} else if bits < 64 && value>>uint(bits) != 0 {
+			return nil, ErrNumericOutOfRange
+		} else if v, seen := values[name]; seen && v != value {
+			return nil, ErrNumericConflict
+		}
+		values[name] = value
+
+		for i := 0; i < bits; i++ {
+			expanded[bitAttr(name, i, value>>uint(i)&1)] = struct{}{}
+		}
+	}
+
+	return expanded, nil
+}
+
+// expandTree returns a copy of tree in which every numeric comparison leaf is
+// replaced by a subtree over the bit attributes produced by expandAttributes.
+func expandTree(tree Node, bits int) (Node, error) {
+	switch node := tree.(type) {
+	case *leafNode:
+		name, op, value, ok, err := parseNumeric(string(node.Attr))
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			return &leafNode{node.Attr, nil}, nil
+		}
+		return compareTree(name, op, value, bits)
+	case *nonLeafNode:
+		children := make([]Node, len(node.Children))
+		for i, child := range node.Children {
+			c, err := expandTree(child, bits)
+			if err != nil {
+				return nil, err
+			}
+			children[i] = c
+		}
+
+		n := newGate(node.Gate, children)
+		n.K = node.K
+		return n, nil
+	default:
+		return nil, ErrUnknownNodeType
+	}
+}
+
+// compareTree builds the subtree satisfied by keys holding "name = x" exactly
+// when x op value holds, following the bag-of-bits construction of BSW07.
+func compareTree(name, op string, value uint64, bits int) (Node, error) {
+	max := ^uint64(0)
+	if bits < 64 {
+		max = 1<<uint(bits) - 1
+	}
+	if value > max {
+		return nil, ErrNumericOutOfRange
+	}
+
+	// Strict comparisons are turned into inclusive ones
+	switch {
+	case op == ">" && value == max, op == "<" && value == 0:
+		return nil, ErrNumericOutOfRange
+	case op == ">":
+		op, value = ">=", value+1
+	case op == "<":
+		op, value = "<=", value-1
+	}
+
+	// From the least significant bit upwards, rest is the subtree comparing the
+	// lower bits, and nil when the lower bits are irrelevant.
+	var rest Node
+	for i := 0; i < bits; i++ {
+		b := value >> uint(i) & 1
+		switch op {
+		case "=":
+			rest = joinGate(and, &leafNode{Attribute(bitAttr(name, i, b)), nil}, rest)
+		case ">=":
+			leaf := &leafNode{Attribute(bitAttr(name, i, 1)), nil}
+			if b == 1 {
+				rest = joinGate(and, leaf, rest)
+			} else if rest != nil {
+				rest = joinGate(or, leaf, rest)
+			}
+		case "<=":
+			leaf := &leafNode{Attribute(bitAttr(name, i, 0)), nil}
+			if b == 0 {
+				rest = joinGate(and, leaf, rest)
+			} else if rest != nil {
+				rest = joinGate(or, leaf, rest)
+			}
+		}
+	}
+
+	if rest == nil {
+		// Any key holding the numeric attribute satisfies the comparison
+		rest = joinGate(or, &leafNode{Attribute(bitAttr(name, 0, 0)), nil}, &leafNode{Attribute(bitAttr(name, 0, 1)), nil})
+	}
+
+	return rest, nil
+}
+
+// joinGate puts leaf in front of rest under a gate, reusing rest when it is
+// already such a gate to keep the tree shallow.
+func joinGate(gate operator, leaf *leafNode, rest Node) Node {
+	if rest == nil {
+		return leaf
+	}
+
+	if n, ok := rest.(*nonLeafNode); ok && n.Gate == gate {
+		leaf.parent = n
+		n.Children = append([]Node{leaf}, n.Children...)
+		return n
+	}
+
+	return newGate(gate, []Node{leaf, rest})
+}
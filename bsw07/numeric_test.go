@@ -0,0 +1,166 @@
+package bsw07
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompareTree(t *testing.T) {
+	const bits = 4
+
+	for _, op := range []string{"<", "<=", ">", ">=", "="} {
+		for value := uint64(0); value < 1<<bits; value++ {
+			tree, err := compareTree("level", op, value, bits)
+			if (op == "<" && value == 0) || (op == ">" && value == 1<<bits-1) {
+				if err != ErrNumericOutOfRange {
+					t.Errorf("level %s %d accepted: %v", op, value, err)
+				}
+				continue
+			} else if err != nil {
+				t.Errorf("Error (%v) during expanding level %s %d.", err, op, value)
+				continue
+			}
+
+			for x := uint64(0); x < 1<<bits; x++ {
+				attrs, err := expandAttributes(map[string]struct{}{fmt.Sprintf("level = %d", x): {}}, bits)
+				if err != nil {
+					t.Errorf("Error (%v) during expanding level = %d.", err, x)
+					continue
+				}
+
+				var expected bool
+				switch op {
+				case "<":
+					expected = x < value
+				case "<=":
+					expected = x <= value
+				case ">":
+					expected = x > value
+				case ">=":
+					expected = x >= value
+				case "=":
+					expected = x == value
+				}
+
+				if tree.Satisfy(attrs) != expected {
+					t.Errorf("level %s %d evaluated wrongly for level = %d", op, value, x)
+				}
+			}
+		}
+	}
+}
+
+func TestExpandAttributes(t *testing.T) {
+	attrs, err := expandAttributes(map[string]struct{}{"level = 5": {}, "admin": {}}, 4)
+	if err != nil {
+		t.Errorf("Error (%v) during expanding attributes.", err)
+		return
+	}
+
+	for _, attr := range []string{"admin", "level#0=1", "level#1=0", "level#2=1", "level#3=0"} {
+		if _, ok := attrs[attr]; !ok {
+			t.Errorf("Expanded attributes lack %q: %v", attr, attrs)
+		}
+	}
+
+	if len(attrs) != 5 {
+		t.Errorf("Expanded attributes has wrong size: %v", attrs)
+	}
+
+	if _, err := expandAttributes(map[string]struct{}{"level = 16": {}}, 4); err != ErrNumericOutOfRange {
+		t.Errorf("Value out of range accepted: %v", err)
+	}
+
+	if _, err := expandAttributes(map[string]struct{}{"level >= 3": {}}, 4); err != ErrNumericComparison {
+		t.Errorf("Comparison in key attributes accepted: %v", err)
+	}
+
+	for _, attr := range []string{"level#3=1", "a#b"} {
+		if _, err := expandAttributes(map[string]struct{}{attr: {}}, 4); err != ErrReservedAttribute {
+			t.Errorf("Attribute %q with reserved '#' accepted: %v", attr, err)
+		}
+	}
+
+	if _, err := expandAttributes(map[string]struct{}{"level = 3": {}, "level = 9": {}}, 4); err != ErrNumericConflict {
+		t.Errorf("Numeric attribute with two values accepted: %v", err)
+	}
+
+	// The same value in another spelling is no conflict
+	if _, err := expandAttributes(map[string]struct{}{"level = 3": {}, "level=3": {}}, 4); err != nil {
+		t.Errorf("Error (%v) during expanding the same value twice.", err)
+	}
+}
+
+func TestBSW07_Numeric(t *testing.T) {
+	algo, err := NewBSW07(WithNumericBits(8))
+	if err != nil {
+		t.Errorf("Error (%v) during initializing BSW07.", err)
+		return
+	}
+	pk, msk, _ := algo.Setup()
+
+	tree, err := ParsePolicy("admin OR (level >= 3 AND hired < 2024)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	if _, err := algo.Encrypt(pk, NewMessage().Rand(), tree); err != ErrNumericOutOfRange {
+		t.Errorf("Comparison out of range of 8 bits accepted: %v", err)
+	}
+
+	algo, _ = NewBSW07(WithNumericBits(16))
+	msg := NewMessage().Rand()
+	ct, err := algo.Encrypt(pk, msg, tree)
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting. Msg: %v.", err, msg)
+		return
+	}
+
+	dk, _ := algo.KeyGen(msk, map[string]struct{}{"level = 5": {}, "hired = 2019": {}})
+	plain, err := algo.Decrypt(ct, dk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+	} else if !plain.M.E.Equals(msg.M.E) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+
+	dk, _ = algo.KeyGen(msk, map[string]struct{}{"level = 2": {}, "hired = 2019": {}})
+	if _, err := algo.Decrypt(ct, dk); err != ErrTreeNotSatisfied {
+		t.Errorf("Ciphertext decrypted by key with level = 2: %v", err)
+	}
+
+	// Keys may hold neither bits of numeric attributes nor two values of one
+	dk, _ = algo.KeyGen(msk, map[string]struct{}{"level = 5": {}})
+	for _, attrs := range []map[string]struct{}{
+		{"level#1=1": {}},
+		{"level = 5": {}, "level = 9": {}},
+	} {
+		if _, err := algo.KeyGen(msk, attrs); err == nil {
+			t.Errorf("Key generated for %v.", attrs)
+		}
+		if _, err := algo.Delegate(dk, attrs); err == nil {
+			t.Errorf("Key delegated for %v.", attrs)
+		}
+	}
+
+	if _, err := NewBSW07(WithNumericBits(65)); err != ErrInvalidNumericBits {
+		t.Errorf("Bit width of 65 accepted: %v", err)
+	}
+}
+
+func TestParsePolicy_Numeric(t *testing.T) {
+	tree, err := ParsePolicy("level>=3 AND (x < 10 OR x = 20)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	if tree.String() != "level >= 3 AND (x < 10 OR x = 20)" {
+		t.Errorf("Numeric comparisons formatted wrongly: %s", tree)
+	}
+
+	if _, err := ParsePolicy("a AND level >= high"); err == nil {
+		t.Errorf("Comparison against non-number accepted")
+	}
+}
@@ -0,0 +1,87 @@
+package bsw07
+
+// Outsourced decryption after Green, Hohenberger and Waters. A decryption key is
+// blinded by a random z into a transformation key, which lets an untrusted server
+// run the expensive part of Decrypt without learning anything about the message.
+// The holder of the retrieval key z finishes with a single exponentiation in GT.
+
+// Blind splits dk into a transformation key, which may be handed to an untrusted
+// server, and the retrieval key to be kept by the holder of dk.
+func (algo *BSW07) Blind(dk *DecryptKey) (*TransformKey, *RetrievalKey, error) {
+	pairing := algo.params.pairing
+
+	if err := algo.params.checkFingerprint(dk.Fingerprint); err != nil {
+		return nil, nil, err
+	} else if err := algo.params.BindAs("G", dk.elements()...); err != nil {
+		return nil, nil, err
+	}
+
+	// randomly choose z
+	z := pairing.NewZr()
+	if err := algo.randZr(z); err != nil {
+		return nil, nil, err
+	}
+	zReciprocal := pairing.NewZr()
+	zReciprocal.E.Invert(z.E)
+
+	// Raise every component to 1/z
+	blind := func(x *G) *G {
+		y := pairing.NewG()
+		y.E.PowZn(x.E, zReciprocal.E)
+		return y
+	}
+
+	d1 := make(map[string]*G)
+	d2 := make(map[string]*G)
+	for attr := range dk.S {
+		dJ, ok1 := dk.D1[attr]
+		dJ2, ok2 := dk.D2[attr]
+		if !ok1 || !ok2 {
+			return nil, nil, ErrEncAttrNotExist
+		}
+		d1[attr] = blind(dJ)
+		d2[attr] = blind(dJ2)
+	}
+
+	return NewTransformKey(dk.S, blind(dk.D), d1, d2), NewRetrievalKey(z), nil
+}
+
+// Transform takes ciphertext ct and transformation key tk as input and returns
+// the partially decrypted ciphertext if attributes in tk Satisfy policy in ct.
+// It does all the pairings of Decrypt, and is meant to run on an untrusted server.
+func (algo *BSW07) Transform(ct *Ciphertext, tk *TransformKey) (*PartialCiphertext, error) {
+	if err := algo.params.checkFingerprint(ct.Fingerprint); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("GT", ct.Msg); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("G", append(ct.elements(), tk.elements()...)...); err != nil {
+		return nil, err
+	}
+
+	// transform only needs the components tk shares with a decryption key
+	t, err := algo.transform(ct, &DecryptKey{S: tk.S, D: tk.D, D1: tk.D1, D2: tk.D2})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPartialCiphertext(ct.Msg, t), nil
+}
+
+// DecryptPartial takes partially decrypted ciphertext pct and retrieval key rk as
+// input and returns the decrypted message.
+func (algo *BSW07) DecryptPartial(pct *PartialCiphertext, rk *RetrievalKey) (*Message, error) {
+	pairing := algo.params.pairing
+
+	if err := algo.params.BindAs("GT", pct.Msg, pct.T); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("Zr", rk.Z); err != nil {
+		return nil, err
+	}
+
+	// T = e(g,g)^(as/z), so encMsg / T^z = M
+	m := pairing.NewGT()
+	m.E.PowZn(pct.T.E, rk.Z.E)
+	m.E.Div(pct.Msg.E, m.E)
+
+	return &Message{m}, nil
+}
@@ -0,0 +1,84 @@
+package bsw07
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBSW07_Transform(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+
+	attrs := make(map[string]struct{})
+	attrs["3"] = struct{}{}
+	attrs["4"] = struct{}{}
+
+	dk, err := algo.KeyGen(msk, attrs)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption key generation.", err)
+		return
+	}
+
+	msg := algo.NewMessage().Rand()
+	ct, err := algo.Encrypt(pk, msg, buildTree())
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting.", err)
+		return
+	}
+
+	tk, rk, err := algo.Blind(dk)
+	if err != nil {
+		t.Errorf("Error (%v) during blinding.", err)
+		return
+	}
+
+	// The transformation key travels to the server and back
+	data, err := json.Marshal(tk)
+	if err != nil {
+		t.Errorf("Error (%v) during marshaling", err)
+		return
+	}
+	tk2 := &TransformKey{}
+	if err := json.Unmarshal(data, tk2); err != nil {
+		t.Errorf("Error (%v) during unmarshaling", err)
+		return
+	}
+
+	pct, err := algo.Transform(ct, tk2)
+	if err != nil {
+		t.Errorf("Error (%v) during transformation.", err)
+		return
+	}
+
+	if pct.T.E.Equals(msg.M.E) || pct.Msg.E.Equals(msg.M.E) {
+		t.Errorf("Partially decrypted ciphertext reveals the message.")
+	}
+
+	plain, err := algo.DecryptPartial(pct, rk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+		return
+	}
+
+	if !plain.M.E.Equals(msg.M.E) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+
+	// A transformation key of other attributes does not satisfy the tree
+	attrs2 := make(map[string]struct{})
+	attrs2["5"] = struct{}{}
+	dk2, _ := algo.KeyGen(msk, attrs2)
+	tk3, _, _ := algo.Blind(dk2)
+	if _, err := algo.Transform(ct, tk3); err != ErrTreeNotSatisfied {
+		t.Errorf("Transformation with unsatisfying key: %v", err)
+	}
+
+	// The retrieval key of another blinding does not recover the message
+	_, rk2, _ := algo.Blind(dk)
+	plain2, err := algo.DecryptPartial(pct, rk2)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+	} else if plain2.M.E.Equals(msg.M.E) {
+		t.Errorf("Message decrypted with unrelated retrieval key.")
+	}
+}
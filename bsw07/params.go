@@ -0,0 +1,275 @@
+package bsw07
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"sync"
+
+	"github.com/Nik-U/pbc"
+)
+
+// ParamsVersion is the version of parameter files written by Params.MarshalJSON.
+const ParamsVersion = 1
+
+// FingerprintSize is the length of fingerprints returned by Params.Fingerprint.
+const FingerprintSize = 8
+
+// paramsFile is the format of parameter files, shared with gpsw06, in which g1
+// holds the generator g.
+type paramsFile struct {
+	Version int    `json:"version"`
+	Pairing string `json:"pairing"`
+	G1      string `json:"g1"`
+	G2      string `json:"g2,omitempty"`
+}
+
+// Params is a set of pairing parameters together with the generator g, which
+// determines the group all keys and ciphertexts of a BSW07 live in.
+type Params struct {
+	param   string
+	pairing *Pairing
+	g       *G
+	zero    *pbc.Element
+	e       *pbc.Element // e(g, g) to reduce redundant calculation
+	order   *big.Int     // order r of the groups
+
+	fingerprint []byte // digest of param and g embedded in encodings
+
+	// Fixed-base tables for powers of g and e(g, g)
+	gPower *pbc.Power
+	ePower *pbc.Power
+}
+
+// loaded holds the parameters created by NewParams keyed by fingerprint, so that
+// encodings of parameters other than the default ones are checked as they are
+// decoded.
+var loaded sync.Map
+
+// NewParams loads pairing parameters in the format of the PBC library and the
+// generator g in the format of pbc.Element.SetString. A random generator is
+// chosen if g is empty, which has to be saved with Generator for later use. A g
+// which is not a generator of the subgroup of order r is rejected with
+// ErrInvalidG.
+func NewParams(param, g string) (*Params, error) {
+	pairing, err := pbc.NewPairingFromString(param)
+	if err != nil {
+		return nil, err
+	}
+	if !pairing.IsSymmetric() {
+		return nil, ErrAsymmetricParams
+	}
+
+	r := order(pairing)
+
+	var gen *pbc.Element
+	if g == "" {
+		gen = pairing.NewG1().Rand()
+	} else {
+		var ok bool
+		if gen, ok = pairing.NewG1().SetString(g, 10); !ok || !isGenerator(gen, r) {
+			return nil, ErrInvalidG
+		}
+	}
+
+	p := &Pairing{pairing}
+	e := pairing.NewGT().Pair(gen, gen)
+	params := &Params{
+		param,
+		p,
+		&Element{"G", gen, nil, p},
+		pairing.NewZr().Set0(),
+		e,
+		r,
+		fingerprint(param, gen, gen),
+		gen.PreparePower(),
+		e.PreparePower(),
+	}
+	loaded.LoadOrStore(string(params.fingerprint), params)
+
+	return params, nil
+}
+
+// order returns the order r of the groups of pairing, as -1 is r - 1 in Zr.
+func order(pairing *pbc.Pairing) *big.Int {
+	r := pairing.NewZr().Set1()
+	return new(big.Int).Add(r.Neg(r).BigInt(), big.NewInt(1))
+}
+
+// isGenerator reports whether g generates the subgroup of order r, which holds
+// for each of its elements but the identity as r is prime.
+func isGenerator(g *pbc.Element, r *big.Int) bool {
+	return !g.Is1() && g.NewFieldElement().PowBig(g, r).Is1()
+}
+
+// fingerprint digests pairing parameters param and generators g1, g2 in the
+// same way as gpsw06, so that both packages agree on a parameter file.
+func fingerprint(param string, g1, g2 *pbc.Element) []byte {
+	h := sha256.New()
+	for _, b := range [][]byte{[]byte(param), g1.Bytes(), g2.Bytes()} {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+	return h.Sum(nil)[:FingerprintSize]
+}
+
+// LoadParams is like NewParams, but reads the pairing parameters from the PBC
+// params file at path.
+func LoadParams(path, g string) (*Params, error) {
+	param, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewParams(string(param), g)
+}
+
+// ReadParamsFile reads parameters from the parameter file at path, as written
+// by Params.MarshalJSON.
+func ReadParamsFile(path string) (*Params, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &Params{}
+	if err := json.Unmarshal(data, params); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+// DefaultParams returns the parameters used unless configured with WithParams.
+func DefaultParams() (*Params, error) {
+	return defaultParams, defaultParamsErr
+}
+
+// WithParams sets the pairing parameters of BSW07 to params.
+func WithParams(params *Params) Option {
+	return func(algo *BSW07) error {
+		if params == nil {
+			return ErrNilParams
+		}
+		algo.params = params
+		return nil
+	}
+}
+
+// String returns the pairing parameters of p in the format of the PBC library.
+func (p *Params) String() string {
+	return p.param
+}
+
+// Pairing returns the pairing of p.
+func (p *Params) Pairing() *Pairing {
+	return p.pairing
+}
+
+// Generator returns the generator g of p.
+func (p *Params) Generator() *G {
+	return p.g
+}
+
+// Order returns the order r of the groups of p.
+func (p *Params) Order() *big.Int {
+	return new(big.Int).Set(p.order)
+}
+
+// Fingerprint returns a short digest of the pairing parameters and generator of
+// p, which is embedded in encodings of keys and ciphertexts.
+func (p *Params) Fingerprint() []byte {
+	return append([]byte(nil), p.fingerprint...)
+}
+
+// checkFingerprint checks that each of fps is the fingerprint of p, and returns
+// ErrParamsMismatch otherwise. Empty ones are accepted on purpose for keys and
+// ciphertexts constructed by hand and those of version 1 of the binary format,
+// the only encoding without fingerprints, as the JSON and binary decoders reject
+// other encodings without one.
+func (p *Params) checkFingerprint(fps ...[]byte) error {
+	for _, fp := range fps {
+		if len(fp) != 0 && !bytes.Equal(fp, p.fingerprint) {
+			return ErrParamsMismatch
+		}
+	}
+	return nil
+}
+
+// bindMu serializes Bind, which decodes elements shared between goroutines, such
+// as the master key of an authority, again. As binding to the same parameters
+// again does not write, an element may be read once bound.
+var bindMu sync.Mutex
+
+// Bind makes els elements of the pairing of p. Elements decoded from JSON are
+// decoded again if necessary, while elements created from another pairing are
+// rejected with ErrParamsMismatch. Missing elements are rejected with
+// ErrMissingElement. Bind is safe to call concurrently on the same elements,
+// but not while they are bound to other parameters.
+func (p *Params) Bind(els ...*Element) error {
+	bindMu.Lock()
+	defer bindMu.Unlock()
+
+	for _, el := range els {
+		if el == nil || el.E == nil && el.raw == nil {
+			return ErrMissingElement
+		} else if el.pairing == p.pairing {
+			continue
+		}
+
+		if el.raw == nil {
+			if el.pairing != nil {
+				return ErrParamsMismatch
+			}
+			// Element constructed by hand, which cannot be checked
+			continue
+		}
+
+		if err := el.decode(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BindAs is like Bind, but also rejects elements which are not of field with
+// ErrWrongField, as the field of a decoded element is the one its encoding names.
+func (p *Params) BindAs(field string, els ...*Element) error {
+	for _, el := range els {
+		if el != nil && el.Field != field {
+			return ErrWrongField
+		}
+	}
+	return p.Bind(els...)
+}
+
+// Params implements encoding/json.Marshaler, producing a parameter file.
+func (p *Params) MarshalJSON() ([]byte, error) {
+	return json.Marshal(paramsFile{ParamsVersion, p.param, p.g.E.String(), ""})
+}
+
+// Params implements encoding/json.Unmarshaler, reading a parameter file.
+func (p *Params) UnmarshalJSON(b []byte) error {
+	var file paramsFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return err
+	} else if file.Version != ParamsVersion {
+		return ErrParamsVersion
+	} else if file.G1 == "" {
+		return ErrInvalidG
+	}
+
+	params, err := NewParams(file.Pairing, file.G1)
+	if err != nil {
+		return err
+	}
+
+	*p = *params
+	return nil
+}
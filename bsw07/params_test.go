@@ -0,0 +1,298 @@
+package bsw07
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNewParams(t *testing.T) {
+	if _, err := NewParams("type a\nq 1", _g); err == nil {
+		t.Errorf("Malformed pairing parameters accepted")
+	}
+
+	if _, err := NewParams(_paramString, "[1, 2"); err != ErrInvalidG {
+		t.Errorf("Malformed generator accepted: %v", err)
+	}
+
+	if _, err := NewParams(_paramString, defaultParams.pairing.P.NewG1().Set1().String()); err != ErrInvalidG {
+		t.Errorf("Identity accepted as generator: %v", err)
+	}
+
+	params, err := NewParams(_paramString, "")
+	if err != nil {
+		t.Errorf("Error (%v) during loading parameters with random generator.", err)
+		return
+	}
+
+	params2, err := NewParams(_paramString, params.Generator().E.String())
+	if err != nil {
+		t.Errorf("Error (%v) during loading parameters with saved generator.", err)
+	} else if params2.Generator().E.String() != params.Generator().E.String() {
+		t.Errorf("Saved generator loaded wrongly")
+	}
+}
+
+func TestLoadParams(t *testing.T) {
+	f, err := ioutil.TempFile("", "bsw07")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(_paramString)
+	f.Close()
+
+	if _, err := LoadParams(f.Name(), _g); err != nil {
+		t.Errorf("Error (%v) during loading parameters from file.", err)
+	}
+
+	if _, err := LoadParams(f.Name()+".missing", _g); err == nil {
+		t.Errorf("Missing parameters file accepted")
+	}
+}
+
+func TestBSW07_WithParams(t *testing.T) {
+	if _, err := NewBSW07(WithParams(nil)); err != ErrNilParams {
+		t.Errorf("Nil parameters accepted: %v", err)
+	}
+
+	params, _ := NewParams(_paramString, "")
+	algo, err := NewBSW07(WithParams(params))
+	if err != nil {
+		t.Errorf("Error (%v) during initializing BSW07.", err)
+		return
+	}
+	pk, msk, _ := algo.Setup()
+
+	msg := algo.NewMessage().Rand()
+	ct, err := algo.Encrypt(pk, msg, &leafNode{"a", nil})
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting. Msg: %v.", err, msg)
+		return
+	}
+	dk, _ := algo.KeyGen(msk, map[string]struct{}{"a": {}})
+
+	// Elements decoded from JSON are bound to the parameters of the scheme
+	data, _ := json.Marshal(ct)
+	ct2 := &Ciphertext{}
+	if err := json.Unmarshal(data, ct2); err != nil {
+		t.Errorf("Error (%v) during unmarshaling.", err)
+		return
+	}
+	data, _ = json.Marshal(dk)
+	dk2 := &DecryptKey{}
+	if err := json.Unmarshal(data, dk2); err != nil {
+		t.Errorf("Error (%v) during unmarshaling.", err)
+		return
+	}
+
+	plain, err := algo.Decrypt(ct2, dk2)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+	} else if !plain.M.E.Equals(msg.M.E) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+
+	other, _ := NewBSW07()
+	if _, err := other.Decrypt(ct, dk); err != ErrParamsMismatch {
+		t.Errorf("Ciphertext of other parameters accepted: %v", err)
+	}
+	if _, err := other.Encrypt(pk, NewMessage().Rand(), &leafNode{"a", nil}); err != ErrParamsMismatch {
+		t.Errorf("Public key of other parameters accepted: %v", err)
+	}
+}
+
+func TestParams_JSON(t *testing.T) {
+	params, _ := NewParams(_paramString, "")
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Errorf("Error (%v) during marshaling.", err)
+		return
+	}
+
+	params2 := &Params{}
+	if err := json.Unmarshal(data, params2); err != nil {
+		t.Errorf("Error (%v) during unmarshaling.", err)
+	} else if params2.String() != params.String() || params2.Generator().E.String() != params.Generator().E.String() {
+		t.Errorf("Parameters unmarshaled wrongly")
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":2,"pairing":"","g1":""}`), params2); err != ErrParamsVersion {
+		t.Errorf("Unsupported version accepted: %v", err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":1,"pairing":"type f\nr 11","g1":"[1, 2]"}`), params2); err != ErrAsymmetricParams {
+		t.Errorf("Asymmetric pairing accepted: %v", err)
+	}
+}
+
+func TestParams_Power(t *testing.T) {
+	params := defaultParams
+	x := params.pairing.P.NewZr().Rand()
+
+	if !params.pairing.P.NewG1().PowerZn(params.gPower, x).Equals(params.pairing.P.NewG1().PowZn(params.g.E, x)) {
+		t.Errorf("Power table of g does not match g")
+	}
+
+	if !params.pairing.P.NewGT().PowerZn(params.ePower, x).Equals(params.pairing.P.NewGT().PowZn(params.e, x)) {
+		t.Errorf("Power table of e(g, g) does not match e(g, g)")
+	}
+}
+
+func TestBSW07_publicPowers(t *testing.T) {
+	algo, _ := NewBSW07()
+
+	// Tables must follow the public key when it changes between calls
+	for i := 0; i < 2; i++ {
+		pk, msk, _ := algo.Setup()
+		dk, _ := algo.KeyGen(msk, map[string]struct{}{"a": {}})
+
+		msg := algo.NewMessage().Rand()
+		ct, err := algo.Encrypt(pk, msg, &leafNode{"a", nil})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if plain, err := algo.Decrypt(ct, dk); err != nil {
+			t.Errorf("Error (%v) during decryption with public key %d.", err, i)
+		} else if !plain.M.E.Equals(msg.M.E) {
+			t.Errorf("Message encrypted with public key %d and decrypted differs.", i)
+		}
+	}
+}
+
+func BenchmarkParams_Power(b *testing.B) {
+	params := defaultParams
+	x := params.pairing.P.NewZr().Rand()
+	y := params.pairing.P.NewG1()
+
+	b.Run("PowZn", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			y.PowZn(params.g.E, x)
+		}
+	})
+
+	b.Run("PowerZn", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			y.PowerZn(params.gPower, x)
+		}
+	})
+}
+
+func BenchmarkBSW07_Encrypt(b *testing.B) {
+	for _, workers := range []int{1, 4} {
+		algo, _ := NewBSW07(WithWorkers(workers))
+		pk, _, _ := algo.Setup()
+		msg := algo.NewMessage().Rand()
+
+		for _, n := range []int{10, 50, 500} {
+			tree, err := wideTree(n)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.Run(fmt.Sprintf("workers=%d/leaves=%d", workers, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := algo.Encrypt(pk, msg, tree); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestParams_Fingerprint(t *testing.T) {
+	params, _ := NewParams(_paramString, "")
+	params2, _ := NewParams(_paramString, params.Generator().E.String())
+	other, _ := NewParams(_paramString, "")
+
+	if len(params.Fingerprint()) != FingerprintSize {
+		t.Errorf("Fingerprint of %d bytes", len(params.Fingerprint()))
+	} else if !bytes.Equal(params.Fingerprint(), params2.Fingerprint()) {
+		t.Errorf("Fingerprints of the same parameters differ")
+	} else if bytes.Equal(params.Fingerprint(), other.Fingerprint()) {
+		t.Errorf("Fingerprints of other generators equal")
+	}
+
+	algo, _ := NewBSW07(WithParams(params))
+	pk, msk, _ := algo.Setup()
+	dk, _ := algo.KeyGen(msk, map[string]struct{}{"a": {}})
+	ct, err := algo.Encrypt(pk, algo.NewMessage().Rand(), &leafNode{"a", nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		in, out interface{}
+	}{
+		{pk, &PublicKey{}},
+		{msk, &MasterKey{}},
+		{dk, &DecryptKey{}},
+		{ct, &Ciphertext{}},
+	} {
+		data, _ := json.Marshal(v.in)
+		if !bytes.Contains(data, []byte(`"fingerprint"`)) {
+			t.Errorf("Fingerprint missing from JSON of %T", v.in)
+		}
+
+		// Deleting the fingerprint does not skip the check of parameters
+		var fields map[string]json.RawMessage
+		json.Unmarshal(data, &fields)
+		delete(fields, "fingerprint")
+		data, _ = json.Marshal(fields)
+		if err := json.Unmarshal(data, v.out); err != ErrMissingFingerprint {
+			t.Errorf("%T without fingerprint unmarshaled with %v, expecting %v", v.in, err, ErrMissingFingerprint)
+		}
+	}
+
+	// Elements of the same lengths would decode to garbage under other
+	// generators, so the fingerprint is all there is to reject them
+	algo2, _ := NewBSW07(WithParams(other))
+	data, _ := json.Marshal(ct)
+	ct2 := &Ciphertext{}
+	if err := json.Unmarshal(data, ct2); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(dk)
+	dk2 := &DecryptKey{}
+	if err := json.Unmarshal(data, dk2); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := algo2.Decrypt(ct2, dk2); err != ErrParamsMismatch {
+		t.Errorf("Ciphertext of other generator accepted: %v", err)
+	}
+	if _, err := algo2.KeyGen(msk, map[string]struct{}{"a": {}}); err != ErrParamsMismatch {
+		t.Errorf("Master key of other generator accepted: %v", err)
+	}
+
+	data, _ = ct.MarshalBinary()
+	ct3 := &Ciphertext{}
+	if err := ct3.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(ct3.Fingerprint, params.Fingerprint()) {
+		t.Errorf("Fingerprint of binary ciphertext decoded wrongly")
+	} else if _, err := algo2.Decrypt(ct3, dk2); err != ErrParamsMismatch {
+		t.Errorf("Binary ciphertext of other generator accepted: %v", err)
+	}
+
+	// Encodings of version 1 have no fingerprint
+	data, _ = pk.MarshalBinary()
+	v1 := append([]byte{1, binaryPublicKey}, data[3+FingerprintSize:]...)
+	pk2 := &PublicKey{}
+	if err := pk2.UnmarshalBinary(v1); err != nil {
+		t.Errorf("Error (%v) during unmarshaling version 1.", err)
+	} else if pk2.Fingerprint != nil {
+		t.Errorf("Fingerprint in version 1")
+	}
+
+	// Later versions require a fingerprint
+	v2 := append([]byte{BinaryVersion, binaryPublicKey, 0}, data[3+FingerprintSize:]...)
+	if err := (&PublicKey{}).UnmarshalBinary(v2); err != ErrMissingFingerprint {
+		t.Errorf("Public key without fingerprint unmarshaled with %v, expecting %v", err, ErrMissingFingerprint)
+	}
+}
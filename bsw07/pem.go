@@ -0,0 +1,263 @@
+package bsw07
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/pem"
+	"strconv"
+)
+
+// PEM block types of keys and ciphertexts, whose bytes are the binary encoding.
+const (
+	PEMPublicKey         = "ABE BSW07 PUBLIC KEY"
+	PEMMasterKey         = "ABE BSW07 MASTER KEY"
+	PEMDecryptKey        = "ABE BSW07 DECRYPT KEY"
+	PEMTransformKey      = "ABE BSW07 TRANSFORM KEY"
+	PEMRetrievalKey      = "ABE BSW07 RETRIEVAL KEY"
+	PEMCiphertext        = "ABE BSW07 CIPHERTEXT"
+	PEMEnvelope          = "ABE BSW07 ENVELOPE"
+	PEMPartialCiphertext = "ABE BSW07 PARTIAL CIPHERTEXT"
+)
+
+// encodePEM armors the binary encoding data in a block of type typ, with
+// headers naming the scheme, the version of the encoding and the parameter
+// fingerprint fp, if any.
+func encodePEM(typ string, data, fp []byte) []byte {
+	headers := map[string]string{
+		"Scheme":  "bsw07",
+		"Version": strconv.Itoa(int(data[0])),
+	}
+	if len(fp) != 0 {
+		headers["Fingerprint"] = hex.EncodeToString(fp)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Headers: headers, Bytes: data})
+}
+
+// decodePEM returns the block armored in data, which fails with wrongType
+// unless the block is of type typ. Headers, which are optional, must agree with
+// the binary encoding.
+func decodePEM(data []byte, typ string, wrongType error) (*pem.Block, error) {
+	block, rest := pem.Decode(data)
+	if block == nil || len(bytes.TrimSpace(rest)) != 0 {
+		return nil, ErrBadPEM
+	} else if block.Type != typ {
+		return nil, wrongType
+	}
+
+	if scheme, ok := block.Headers["Scheme"]; ok && scheme != "bsw07" {
+		return nil, ErrBadPEM
+	}
+	if version, ok := block.Headers["Version"]; ok && (len(block.Bytes) == 0 || version != strconv.Itoa(int(block.Bytes[0]))) {
+		return nil, ErrBadPEM
+	}
+
+	return block, nil
+}
+
+// checkFingerprint checks the fingerprint header of block, if any, against the
+// fingerprint fp of the binary encoding.
+func checkFingerprint(block *pem.Block, fp []byte) error {
+	if header, ok := block.Headers["Fingerprint"]; ok && header != hex.EncodeToString(fp) {
+		return ErrBadPEM
+	}
+	return nil
+}
+
+// MarshalPEM encodes pk in a PEM block of type PEMPublicKey.
+func (pk *PublicKey) MarshalPEM() ([]byte, error) {
+	data, err := pk.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMPublicKey, data, pk.Fingerprint), nil
+}
+
+// UnmarshalPEM sets pk to the key encoded by MarshalPEM. Blocks of other types
+// are rejected with ErrExpectingPublicKey.
+func (pk *PublicKey) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMPublicKey, ErrExpectingPublicKey)
+	if err != nil {
+		return err
+	}
+
+	key := &PublicKey{}
+	if err := key.UnmarshalBinary(block.Bytes); err != nil {
+		return err
+	} else if err := checkFingerprint(block, key.Fingerprint); err != nil {
+		return err
+	}
+
+	*pk = *key
+	return nil
+}
+
+// MarshalPEM encodes msk in a PEM block of type PEMMasterKey.
+func (msk *MasterKey) MarshalPEM() ([]byte, error) {
+	data, err := msk.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMMasterKey, data, msk.Fingerprint), nil
+}
+
+// UnmarshalPEM sets msk to the key encoded by MarshalPEM. Blocks of other types
+// are rejected with ErrExpectingMasterKey.
+func (msk *MasterKey) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMMasterKey, ErrExpectingMasterKey)
+	if err != nil {
+		return err
+	}
+
+	key := &MasterKey{}
+	if err := key.UnmarshalBinary(block.Bytes); err != nil {
+		return err
+	} else if err := checkFingerprint(block, key.Fingerprint); err != nil {
+		return err
+	}
+
+	*msk = *key
+	return nil
+}
+
+// MarshalPEM encodes dk in a PEM block of type PEMDecryptKey.
+func (dk *DecryptKey) MarshalPEM() ([]byte, error) {
+	data, err := dk.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMDecryptKey, data, dk.Fingerprint), nil
+}
+
+// UnmarshalPEM sets dk to the key encoded by MarshalPEM. Blocks of other types
+// are rejected with ErrExpectingPrivateKey.
+func (dk *DecryptKey) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMDecryptKey, ErrExpectingPrivateKey)
+	if err != nil {
+		return err
+	}
+
+	key := &DecryptKey{}
+	if err := key.UnmarshalBinary(block.Bytes); err != nil {
+		return err
+	} else if err := checkFingerprint(block, key.Fingerprint); err != nil {
+		return err
+	}
+
+	*dk = *key
+	return nil
+}
+
+// MarshalPEM encodes tk in a PEM block of type PEMTransformKey.
+func (tk *TransformKey) MarshalPEM() ([]byte, error) {
+	data, err := tk.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMTransformKey, data, nil), nil
+}
+
+// UnmarshalPEM sets tk to the key encoded by MarshalPEM. Blocks of other types
+// are rejected with ErrExpectingTransformKey.
+func (tk *TransformKey) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMTransformKey, ErrExpectingTransformKey)
+	if err != nil {
+		return err
+	}
+	return tk.UnmarshalBinary(block.Bytes)
+}
+
+// MarshalPEM encodes rk in a PEM block of type PEMRetrievalKey.
+func (rk *RetrievalKey) MarshalPEM() ([]byte, error) {
+	data, err := rk.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMRetrievalKey, data, nil), nil
+}
+
+// UnmarshalPEM sets rk to the key encoded by MarshalPEM. Blocks of other types
+// are rejected with ErrExpectingRetrievalKey.
+func (rk *RetrievalKey) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMRetrievalKey, ErrExpectingRetrievalKey)
+	if err != nil {
+		return err
+	}
+	return rk.UnmarshalBinary(block.Bytes)
+}
+
+// MarshalPEM encodes ct in a PEM block of type PEMCiphertext.
+func (ct *Ciphertext) MarshalPEM() ([]byte, error) {
+	data, err := ct.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMCiphertext, data, ct.Fingerprint), nil
+}
+
+// UnmarshalPEM sets ct to the ciphertext encoded by MarshalPEM. Blocks of other
+// types are rejected with ErrExpectingCiphertext.
+func (ct *Ciphertext) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMCiphertext, ErrExpectingCiphertext)
+	if err != nil {
+		return err
+	}
+
+	c := &Ciphertext{}
+	if err := c.UnmarshalBinary(block.Bytes); err != nil {
+		return err
+	} else if err := checkFingerprint(block, c.Fingerprint); err != nil {
+		return err
+	}
+
+	*ct = *c
+	return nil
+}
+
+// MarshalPEM encodes env in a PEM block of type PEMEnvelope, with the
+// fingerprint of its key.
+func (env *Envelope) MarshalPEM() ([]byte, error) {
+	data, err := env.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMEnvelope, data, env.Key.Fingerprint), nil
+}
+
+// UnmarshalPEM sets env to the envelope encoded by MarshalPEM. Blocks of other
+// types are rejected with ErrExpectingEnvelope.
+func (env *Envelope) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMEnvelope, ErrExpectingEnvelope)
+	if err != nil {
+		return err
+	}
+
+	e := &Envelope{}
+	if err := e.UnmarshalBinary(block.Bytes); err != nil {
+		return err
+	} else if err := checkFingerprint(block, e.Key.Fingerprint); err != nil {
+		return err
+	}
+
+	*env = *e
+	return nil
+}
+
+// MarshalPEM encodes pct in a PEM block of type PEMPartialCiphertext.
+func (pct *PartialCiphertext) MarshalPEM() ([]byte, error) {
+	data, err := pct.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMPartialCiphertext, data, nil), nil
+}
+
+// UnmarshalPEM sets pct to the ciphertext encoded by MarshalPEM. Blocks of
+// other types are rejected with ErrExpectingPartialCiphertext.
+func (pct *PartialCiphertext) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMPartialCiphertext, ErrExpectingPartialCiphertext)
+	if err != nil {
+		return err
+	}
+	return pct.UnmarshalBinary(block.Bytes)
+}
@@ -0,0 +1,111 @@
+package bsw07
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// pemCodec is implemented by the keys and ciphertexts of the package.
+type pemCodec interface {
+	MarshalPEM() ([]byte, error)
+	UnmarshalPEM([]byte) error
+}
+
+func TestPEM(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+	attrs := map[string]struct{}{"a": {}, "b": {}}
+
+	dk, err := algo.KeyGen(msk, attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, _ := ParsePolicy("a AND (b OR c)")
+	ct, err := algo.Encrypt(pk, algo.NewMessage().Rand(), tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := algo.EncryptBytes(pk, []byte("plaintext"), tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tk, rk, err := algo.Blind(dk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pct, err := algo.Transform(ct, tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoded values must encode to the same JSON
+	for _, v := range []struct {
+		in, out pemCodec
+		typ     string
+	}{
+		{pk, &PublicKey{}, PEMPublicKey},
+		{msk, &MasterKey{}, PEMMasterKey},
+		{dk, &DecryptKey{}, PEMDecryptKey},
+		{ct, &Ciphertext{}, PEMCiphertext},
+		{env, &Envelope{}, PEMEnvelope},
+		{tk, &TransformKey{}, PEMTransformKey},
+		{rk, &RetrievalKey{}, PEMRetrievalKey},
+		{pct, &PartialCiphertext{}, PEMPartialCiphertext},
+	} {
+		data, err := v.in.MarshalPEM()
+		if err != nil {
+			t.Errorf("Error (%v) during marshaling %T.", err, v.in)
+			continue
+		}
+
+		if !bytes.HasPrefix(data, []byte("-----BEGIN "+v.typ+"-----\n")) || !bytes.Contains(data, []byte("Scheme: bsw07\n")) {
+			t.Errorf("%T armored wrongly:\n%s", v.in, data)
+		}
+
+		if err := v.out.UnmarshalPEM(data); err != nil {
+			t.Errorf("Error (%v) during unmarshaling %T.", err, v.in)
+			continue
+		}
+
+		expected, _ := json.Marshal(v.in)
+		actual, _ := json.Marshal(v.out)
+		if !jsonEqual(expected, actual) {
+			t.Errorf("%T unmarshaled wrongly", v.in)
+		}
+	}
+
+	data, _ := msk.MarshalPEM()
+	if err := (&PublicKey{}).UnmarshalPEM(data); err != ErrExpectingPublicKey {
+		t.Errorf("Master key accepted as public key: %v", err)
+	}
+
+	data, _ = ct.MarshalPEM()
+	if err := (&PartialCiphertext{}).UnmarshalPEM(data); err != ErrExpectingPartialCiphertext {
+		t.Errorf("Ciphertext accepted as partial ciphertext: %v", err)
+	}
+
+	data, _ = pk.MarshalPEM()
+	if !bytes.Contains(data, []byte("Fingerprint: ")) {
+		t.Errorf("Public key armored without fingerprint:\n%s", data)
+	}
+
+	for _, v := range []struct {
+		name string
+		data []byte
+	}{
+		{"garbage", []byte("not a PEM block")},
+		{"trailing data", append(append([]byte{}, data...), "trailing"...)},
+		{"wrong scheme", bytes.Replace(data, []byte("Scheme: bsw07"), []byte("Scheme: gpsw06"), 1)},
+		{"wrong version", bytes.Replace(data, []byte("Version: "), []byte("Version: 9"), 1)},
+		{"wrong fingerprint", bytes.Replace(data, []byte("Fingerprint: "), []byte("Fingerprint: 00"), 1)},
+	} {
+		if err := (&PublicKey{}).UnmarshalPEM(v.data); err != ErrBadPEM {
+			t.Errorf("Public key with %s unmarshaled with %v, expecting %v", v.name, err, ErrBadPEM)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package bsw07
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tree, err := ParsePolicy("1 OR 2 OR (3 AND 4)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	if !tree.Equal(buildTree()) {
+		t.Errorf("Parsed policy does not match with tree: %s", tree)
+	}
+
+	for _, policy := range []string{
+		"admin",
+		"(admin AND finance) OR (2 of (audit, legal, hr))",
+		"a OR (b AND c) OR (d AND (e OR f))",
+		"3 of (a, b, c)",
+		`"with space" AND "AND" AND "quote\""`,
+	} {
+		tree, err := ParsePolicy(policy)
+		if err != nil {
+			t.Errorf("Error (%v) during parsing policy %q.", err, policy)
+			continue
+		}
+
+		tree2, err := ParsePolicy(tree.String())
+		if err != nil {
+			t.Errorf("Error (%v) during parsing formatted policy %q.", err, tree.String())
+			continue
+		}
+
+		if !tree.Equal(tree2) {
+			t.Errorf("Policy %q does not match with formatted policy %q", policy, tree.String())
+		}
+	}
+}
+
+func TestParsePolicy_Index(t *testing.T) {
+	tree, err := ParsePolicy("a AND (b OR c)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	n := tree.(*nonLeafNode)
+	if n.Index() != 0 || n.Children[0].Index() != 1 || n.Children[1].Index() != 2 {
+		t.Errorf("Parsed policy has wrong node indices")
+	}
+	if c := n.Children[1].(*nonLeafNode).Children[1]; c.Index() != 2 || c.Parent() != n.Children[1] {
+		t.Errorf("Parsed policy has wrong parent of nested node")
+	}
+}
+
+func TestParsePolicy_Error(t *testing.T) {
+	for policy, offset := range map[string]int{
+		"":                  0,
+		"a AND":             5,
+		"a b":               2,
+		"(a OR b":           7,
+		"a OR )":            5,
+		"4 of (a, b, c)":    0,
+		"0 of (a)":          0,
+		"x of (a, b)":       0,
+		"a AND 2 of (b c)":  14,
+		`a AND "unfinished`: 6,
+		"a OR level#3":      5,
+		`a OR "level#3=1"`:  5,
+		strings.Repeat("(", maxNodeDepth+1) + "a" + strings.Repeat(")", maxNodeDepth+1): maxNodeDepth,
+	} {
+		_, err := ParsePolicy(policy)
+		serr, ok := err.(*PolicySyntaxError)
+		if !ok {
+			t.Errorf("Policy %q parsed without syntax error: %v", policy, err)
+			continue
+		}
+
+		if serr.Offset != offset {
+			t.Errorf("Policy %q reported error at offset %d, expected %d: %v", policy, serr.Offset, offset, serr)
+		}
+	}
+
+	// Parentheses nested up to maxNodeDepth are accepted
+	policy := strings.Repeat("(", maxNodeDepth) + "a" + strings.Repeat(")", maxNodeDepth)
+	if _, err := ParsePolicy(policy); err != nil {
+		t.Errorf("Error (%v) during parsing policy nested %d deep.", err, maxNodeDepth)
+	}
+}
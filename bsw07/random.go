@@ -0,0 +1,91 @@
+package bsw07
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// WithRandom sets the source of randomness of BSW07 to r, which defaults to
+// crypto/rand.Reader. Every secret of Setup, KeyGen, Delegate, Blind, Encrypt
+// and EncryptBytes is read from r, so that a deterministic r reproduces keys and
+// ciphertexts, which is only meant for tests.
+func WithRandom(r io.Reader) Option {
+	return func(algo *BSW07) error {
+		if r == nil {
+			return ErrNilRandom
+		}
+		algo.random = r
+		return nil
+	}
+}
+
+// randZr sets z to a uniformly random element of Zr read from the source of
+// randomness of algo.
+func (algo *BSW07) randZr(z *Zr) error {
+	return algo.randZrFrom(algo.random, z)
+}
+
+// randZrFrom is like randZr, but reads from random.
+func (algo *BSW07) randZrFrom(random io.Reader, z *Zr) error {
+	x, err := cryptorand.Int(random, algo.params.order)
+	if err != nil {
+		return err
+	}
+	z.E.SetBig(x)
+	return nil
+}
+
+// randMessage returns a random message e(g,g)^x read from the source of
+// randomness of algo.
+func (algo *BSW07) randMessage() (*Message, error) {
+	x := algo.params.pairing.NewZr()
+	if err := algo.randZr(x); err != nil {
+		return nil, err
+	}
+	m := algo.params.pairing.NewGT()
+	m.E.PowerZn(algo.params.ePower, x.E)
+	return &Message{m}, nil
+}
+
+// sortedAttributes returns the attributes of attrs in ascending order, so that
+// randomness is drawn for them in the same order on every call.
+func sortedAttributes(attrs map[string]struct{}) []string {
+	sorted := make([]string, 0, len(attrs))
+	for attr := range attrs {
+		sorted = append(sorted, attr)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// hashReader is a deterministic stream of the SHA-256 hashes of a seed followed
+// by a counter.
+type hashReader struct {
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+func newHashReader(seed []byte) *hashReader {
+	return &hashReader{seed: seed}
+}
+
+func (r *hashReader) Read(p []byte) (int, error) {
+	for n := 0; n < len(p); {
+		if len(r.buf) == 0 {
+			block := make([]byte, len(r.seed)+8)
+			copy(block, r.seed)
+			binary.BigEndian.PutUint64(block[len(r.seed):], r.counter)
+			r.counter++
+			sum := sha256.Sum256(block)
+			r.buf = sum[:]
+		}
+		c := copy(p[n:], r.buf)
+		r.buf = r.buf[c:]
+		n += c
+	}
+	return len(p), nil
+}
@@ -0,0 +1,193 @@
+package bsw07
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+var update = flag.Bool("update", false, "rewrite the known-answer vectors in testdata")
+
+// knownAnswers holds the keys and ciphertext derived from a seed.
+type knownAnswers struct {
+	Seed       string          `json:"seed"`
+	Attributes []string        `json:"attributes"`
+	Policy     string          `json:"policy"`
+	PublicKey  json.RawMessage `json:"pk"`
+	MasterKey  json.RawMessage `json:"msk"`
+	DecryptKey json.RawMessage `json:"dk"`
+	Message    []byte          `json:"msg"`
+	Ciphertext json.RawMessage `json:"ct"`
+}
+
+func deriveKnownAnswers(t *testing.T, seed string) *knownAnswers {
+	algo, err := NewBSW07(WithRandom(newHashReader([]byte(seed))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kat := &knownAnswers{
+		Seed:       seed,
+		Attributes: []string{"a", "b", "level = 5"},
+		Policy:     "(a AND level > 3) OR 2 of (b, c, d)",
+	}
+
+	pk, msk, err := algo.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs := make(map[string]struct{})
+	for _, attr := range kat.Attributes {
+		attrs[attr] = struct{}{}
+	}
+	dk, err := algo.KeyGen(msk, attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := ParsePolicy(kat.Policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := algo.randMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := algo.Encrypt(pk, msg, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plain, err := algo.Decrypt(ct, dk); err != nil || !plain.M.E.Equals(msg.M.E) {
+		t.Fatalf("Known-answer ciphertext does not decrypt: %v", err)
+	}
+
+	kat.Message = msg.Marshal()
+	for _, field := range []struct {
+		dst *json.RawMessage
+		v   interface{}
+	}{
+		{&kat.PublicKey, pk},
+		{&kat.MasterKey, msk},
+		{&kat.DecryptKey, dk},
+		{&kat.Ciphertext, ct},
+	} {
+		if *field.dst, err = json.Marshal(field.v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return kat
+}
+
+func TestWithRandom(t *testing.T) {
+	if _, err := NewBSW07(WithRandom(nil)); err != ErrNilRandom {
+		t.Errorf("Nil source of randomness accepted: %v", err)
+	}
+
+	a, _ := json.Marshal(deriveKnownAnswers(t, "seed"))
+	b, _ := json.Marshal(deriveKnownAnswers(t, "seed"))
+	c, _ := json.Marshal(deriveKnownAnswers(t, "other seed"))
+
+	if string(a) != string(b) {
+		t.Errorf("Same seed derived different keys or ciphertexts.")
+	}
+	if string(a) == string(c) {
+		t.Errorf("Different seeds derived the same keys and ciphertexts.")
+	}
+}
+
+func TestWithRandom_Failing(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+	dk, _ := algo.KeyGen(msk, map[string]struct{}{"a": {}})
+	tree, _ := ParsePolicy("a")
+
+	// Failures of the source of randomness are returned rather than panicking
+	errRead := errors.New("read failed")
+	failing, _ := NewBSW07(WithRandom(iotest.ErrReader(errRead)))
+
+	if _, _, err := failing.Setup(); err != errRead {
+		t.Errorf("Setup returned %v, expecting %v", err, errRead)
+	}
+	if _, err := failing.KeyGen(msk, map[string]struct{}{"a": {}}); err != errRead {
+		t.Errorf("KeyGen returned %v, expecting %v", err, errRead)
+	}
+	if _, err := failing.Delegate(dk, map[string]struct{}{"a": {}}); err != errRead {
+		t.Errorf("Delegate returned %v, expecting %v", err, errRead)
+	}
+	if _, _, err := failing.Blind(dk); err != errRead {
+		t.Errorf("Blind returned %v, expecting %v", err, errRead)
+	}
+	if _, err := failing.Encrypt(pk, algo.NewMessage().Rand(), tree); err != errRead {
+		t.Errorf("Encrypt returned %v, expecting %v", err, errRead)
+	}
+	if _, err := failing.EncryptBytes(pk, []byte("plaintext"), tree); err != errRead {
+		t.Errorf("EncryptBytes returned %v, expecting %v", err, errRead)
+	}
+}
+
+// TestKnownAnswers compares against the vectors in testdata, which depend on the
+// curve arithmetic of the PBC library and are written by running the test with
+// -update.
+func TestKnownAnswers(t *testing.T) {
+	path := filepath.Join("testdata", "kat.json")
+	kat := deriveKnownAnswers(t, "bsw07 known answers")
+
+	if *update {
+		data, err := json.MarshalIndent(kat, "", "\t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("No known-answer vectors in %s, write them with -update against an installed PBC.", path)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &knownAnswers{}
+	if err := json.Unmarshal(data, expected); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []struct {
+		name     string
+		got, exp []byte
+	}{
+		{"public key", kat.PublicKey, expected.PublicKey},
+		{"master key", kat.MasterKey, expected.MasterKey},
+		{"decryption key", kat.DecryptKey, expected.DecryptKey},
+		{"message", kat.Message, expected.Message},
+		{"ciphertext", kat.Ciphertext, expected.Ciphertext},
+	} {
+		if !jsonEqual(field.got, field.exp) {
+			t.Errorf("Known-answer %s differs.", field.name)
+		}
+	}
+}
+
+// jsonEqual reports whether a and b encode the same JSON value.
+func jsonEqual(a, b []byte) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return string(a) == string(b)
+	}
+	x2, _ := json.Marshal(x)
+	y2, _ := json.Marshal(y)
+	return string(x2) == string(y2)
+}
@@ -1,7 +1,10 @@
 package bsw07
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
+	"sync"
 
 	"github.com/Nik-U/pbc"
 )
@@ -9,6 +12,9 @@ import (
 type Element struct {
 	Field string       `json:"field"`
 	E     *pbc.Element `json:"e"`
+
+	raw     []byte   // encoding E was decoded from, if any
+	pairing *Pairing // pairing E belongs to, if known
 }
 
 type tempEl struct {
@@ -25,29 +31,33 @@ type Pairing struct {
 
 func (p *Pairing) NewG() *Element {
 	return &Element{
-		Field: "G",
-		E:     p.P.NewG1(),
+		Field:   "G",
+		E:       p.P.NewG1(),
+		pairing: p,
 	}
 }
 
 func (p *Pairing) NewGT() *Element {
 	return &Element{
-		Field: "GT",
-		E:     p.P.NewGT(),
+		Field:   "GT",
+		E:       p.P.NewGT(),
+		pairing: p,
 	}
 }
 
 func (p *Pairing) NewZr() *Element {
 	return &Element{
-		Field: "Zr",
-		E:     p.P.NewZr(),
+		Field:   "Zr",
+		E:       p.P.NewZr(),
+		pairing: p,
 	}
 }
 
 type PublicKey struct {
-	KeyType string `json:"type"`
-	H       *G     `json:"h"`
-	E       *GT    `json:"e"`
+	KeyType     string `json:"type"`
+	H           *G     `json:"h"`
+	E           *GT    `json:"e"`
+	Fingerprint []byte `json:"fingerprint,omitempty"`
 }
 
 func NewPublicKey(h *G, e *GT) *PublicKey {
@@ -59,12 +69,13 @@ func NewPublicKey(h *G, e *GT) *PublicKey {
 }
 
 type DecryptKey struct {
-	KeyType string              `json:"type"`
-	S       map[string]struct{} `json:"s"`
-	D       *G                  `json:"d"`
-	F       *G                  `json:"f"`
-	D1      map[string]*G       `json:"d1"`
-	D2      map[string]*G       `json:"d2"`
+	KeyType     string              `json:"type"`
+	S           map[string]struct{} `json:"s"`
+	D           *G                  `json:"d"`
+	F           *G                  `json:"f"`
+	D1          map[string]*G       `json:"d1"`
+	D2          map[string]*G       `json:"d2"`
+	Fingerprint []byte              `json:"fingerprint,omitempty"`
 }
 
 func NewDecryptKey(s map[string]struct{}, d, f *G, d1, d2 map[string]*G) *DecryptKey {
@@ -79,9 +90,10 @@ func NewDecryptKey(s map[string]struct{}, d, f *G, d1, d2 map[string]*G) *Decryp
 }
 
 type MasterKey struct {
-	KeyType string `json:"type"`
-	A       *G     `json:"a"`
-	B       *Zr    `json:"b"`
+	KeyType     string `json:"type"`
+	A           *G     `json:"a"`
+	B           *Zr    `json:"b"`
+	Fingerprint []byte `json:"fingerprint,omitempty"`
 }
 
 func NewMasterKey(a *G, b *Zr) *MasterKey {
@@ -96,15 +108,64 @@ type Message struct {
 	M *GT `json:"m"`
 }
 
+// TransformKey is a decryption key blinded by the retrieval key, and lets its
+// holder compute a PartialCiphertext only.
+type TransformKey struct {
+	KeyType string              `json:"type"`
+	S       map[string]struct{} `json:"s"`
+	D       *G                  `json:"d"`
+	D1      map[string]*G       `json:"d1"`
+	D2      map[string]*G       `json:"d2"`
+}
+
+func NewTransformKey(s map[string]struct{}, d *G, d1, d2 map[string]*G) *TransformKey {
+	return &TransformKey{
+		KeyType: "transform",
+		S:       s,
+		D:       d,
+		D1:      d1,
+		D2:      d2,
+	}
+}
+
+type RetrievalKey struct {
+	KeyType string `json:"type"`
+	Z       *Zr    `json:"z"`
+}
+
+func NewRetrievalKey(z *Zr) *RetrievalKey {
+	return &RetrievalKey{
+		KeyType: "retrieval",
+		Z:       z,
+	}
+}
+
+// PartialCiphertext holds the encrypted message of a ciphertext together with
+// T = e(g,g)^(as/z) computed by Transform.
+type PartialCiphertext struct {
+	Msg *GT `json:"msg"`
+	T   *GT `json:"t"`
+}
+
+func NewPartialCiphertext(msg, t *GT) *PartialCiphertext {
+	return &PartialCiphertext{
+		Msg: msg,
+		T:   t,
+	}
+}
+
+// Ciphertext holds the leaf components C1, C2 keyed by the position of each leaf
+// in the tree, as numbered by leafPositions.
 type Ciphertext struct {
-	Tree []byte        `json:"t"`
-	Msg  *GT           `json:"msg"`
-	C    *G            `json:"c"`
-	C1   map[string]*G `json:"c1"`
-	C2   map[string]*G `json:"c2"`
+	Tree        []byte     `json:"t"`
+	Msg         *GT        `json:"msg"`
+	C           *G         `json:"c"`
+	C1          map[int]*G `json:"c1"`
+	C2          map[int]*G `json:"c2"`
+	Fingerprint []byte     `json:"fingerprint,omitempty"`
 }
 
-func NewCiphertext(t []byte, msg *GT, c *G, c1, c2 map[string]*G) *Ciphertext {
+func NewCiphertext(t []byte, msg *GT, c *G, c1, c2 map[int]*G) *Ciphertext {
 	return &Ciphertext{
 		Tree: t,
 		Msg:  msg,
@@ -115,18 +176,38 @@ func NewCiphertext(t []byte, msg *GT, c *G, c1, c2 map[string]*G) *Ciphertext {
 }
 
 type BSW07 struct {
+	numericBits int
+	workers     int
+	random      io.Reader
+	params      *Params
+
+	mu     sync.Mutex
+	powers *publicPowers // tables of the public key last used by Encrypt
+}
+
+// publicPowers holds fixed-base tables for powers of the components of a public
+// key.
+type publicPowers struct {
+	h *pbc.Power
+	e *pbc.Power
 }
 
 type polynomial struct {
 	c []*Zr
 }
 
-// Element implements encoding/json.Marshaler
+// Element implements encoding/json.Marshaler. Elements which were not decoded
+// are written as they were read.
 func (e *Element) MarshalJSON() ([]byte, error) {
+	if e.E == nil {
+		return json.Marshal(tempEl{e.Field, e.raw})
+	}
 	return json.Marshal(tempEl{e.Field, e.E.Bytes()})
 }
 
-// Element implements encoding/jsonUnmarshaler
+// Element implements encoding/jsonUnmarshaler. The element is decoded with the
+// default parameters or other parameters created by NewParams, and decoded
+// again when bound to the parameters of a scheme by Params.Bind.
 func (e *Element) UnmarshalJSON(b []byte) error {
 	temp := &tempEl{}
 	if err := json.Unmarshal(b, temp); err != nil {
@@ -134,22 +215,108 @@ func (e *Element) UnmarshalJSON(b []byte) error {
 	}
 
 	switch temp.Field {
+	case "G", "GT", "Zr":
+	default:
+		return ErrUnknownField
+	}
+	if temp.E == nil {
+		return ErrMissingElement
+	}
+
+	e.Field = temp.Field
+	e.E = nil
+	e.raw = temp.E
+	e.pairing = nil
+
+	return e.decodeLoaded()
+}
+
+// decodeLoaded decodes e with the default parameters or, failing that, with any
+// other pairing of parameters created by NewParams. The error of the default
+// parameters is returned if none of them decodes e.
+func (e *Element) decodeLoaded() error {
+	err := ErrParamsMismatch
+	tried := make(map[string]bool)
+	if defaultParamsErr == nil {
+		if err = e.decode(defaultParams); err == nil {
+			return nil
+		}
+		tried[defaultParams.param] = true
+	}
+
+	loaded.Range(func(_, v interface{}) bool {
+		p := v.(*Params)
+		if tried[p.param] {
+			return true
+		}
+		tried[p.param] = true
+
+		if e.decode(p) == nil {
+			err = nil
+			return false
+		}
+		return true
+	})
+
+	return err
+}
+
+// decode sets E to the element of params p encoded in raw. Encodings of the
+// wrong length or not in canonical form are rejected, and so are elements of G
+// and GT outside the subgroup of order r. No element of a key or ciphertext may
+// be the identity, or zero in Zr.
+func (e *Element) decode(p *Params) error {
+	var el *pbc.Element
+	switch e.Field {
 	case "G":
-		e.E = pairing.P.NewG1().SetBytes(temp.E)
+		el = p.pairing.P.NewG1()
 	case "GT":
-		e.E = pairing.P.NewGT().SetBytes(temp.E)
+		el = p.pairing.P.NewGT()
 	case "Zr":
-		e.E = pairing.P.NewZr().SetBytes(temp.E)
+		el = p.pairing.P.NewZr()
+	default:
+		return ErrUnknownField
 	}
 
-	e.Field = temp.Field
+	// SetBytes reads beyond short buffers, so check the length first. Points
+	// are also accepted in the compressed form of binary encodings.
+	var canonical []byte
+	switch {
+	case len(e.raw) == el.BytesLen():
+		canonical = el.SetBytes(e.raw).Bytes()
+	case e.Field == "G" && len(e.raw) == el.CompressedBytesLen():
+		canonical = el.SetCompressedBytes(e.raw).CompressedBytes()
+	default:
+		return ErrElementLength
+	}
 
+	if !bytes.Equal(canonical, e.raw) {
+		return ErrNonCanonical
+	}
+
+	if e.Field == "Zr" {
+		if el.Is0() {
+			return ErrIdentityElement
+		}
+	} else if el.Is1() {
+		return ErrIdentityElement
+	} else if !el.NewFieldElement().PowBig(el, p.order).Is1() {
+		return ErrNotInSubgroup
+	}
+
+	e.E = el
+	e.pairing = p.pairing
 	return nil
 }
 
-// NewMessage creates an empty Message.
+// NewMessage creates an empty Message of the default parameters.
 func NewMessage() *Message {
-	return &Message{pairing.NewGT()}
+	return &Message{defaultParams.pairing.NewGT()}
+}
+
+// NewMessage creates an empty Message of the parameters of algo.
+func (algo *BSW07) NewMessage() *Message {
+	return &Message{algo.params.pairing.NewGT()}
 }
 
 // Rand set msg to a random value and returns msg.
@@ -162,26 +329,136 @@ func (msg *Message) Marshal() []byte {
 	return msg.M.E.Bytes()
 }
 
+// Unmarshal sets msg to the element of GT encoded in b by Marshal. Encodings of
+// the wrong length or not in canonical form are rejected, and so are elements
+// outside the subgroup of order r, leaving msg unchanged.
 func (msg *Message) Unmarshal(b []byte) error {
-	msg.M.E.SetBytes(b)
+	el := msg.M.E.NewFieldElement()
+
+	// SetBytes reads beyond short buffers, so check the length first
+	if len(b) != el.BytesLen() {
+		return ErrElementLength
+	} else if !bytes.Equal(el.SetBytes(b).Bytes(), b) {
+		return ErrNonCanonical
+	} else if !el.NewFieldElement().PowBig(el, order(el.Pairing())).Is1() {
+		return ErrNotInSubgroup
+	}
+
+	msg.M.E.Set(el)
+	return nil
+}
+
+// PublicKey implements encoding/json.Unmarshaler, rejecting keys without a
+// fingerprint with ErrMissingFingerprint.
+func (pk *PublicKey) UnmarshalJSON(b []byte) error {
+	type plain PublicKey
+	key := plain(*pk)
+	if err := json.Unmarshal(b, &key); err != nil {
+		return err
+	} else if len(key.Fingerprint) == 0 {
+		return ErrMissingFingerprint
+	}
+
+	*pk = PublicKey(key)
+	return nil
+}
+
+// MasterKey implements encoding/json.Unmarshaler, rejecting keys without a
+// fingerprint with ErrMissingFingerprint.
+func (msk *MasterKey) UnmarshalJSON(b []byte) error {
+	type plain MasterKey
+	key := plain(*msk)
+	if err := json.Unmarshal(b, &key); err != nil {
+		return err
+	} else if len(key.Fingerprint) == 0 {
+		return ErrMissingFingerprint
+	}
+
+	*msk = MasterKey(key)
+	return nil
+}
+
+// DecryptKey implements encoding/json.Unmarshaler, rejecting keys without a
+// fingerprint with ErrMissingFingerprint.
+func (dk *DecryptKey) UnmarshalJSON(b []byte) error {
+	type plain DecryptKey
+	key := plain(*dk)
+	if err := json.Unmarshal(b, &key); err != nil {
+		return err
+	} else if len(key.Fingerprint) == 0 {
+		return ErrMissingFingerprint
+	}
+
+	*dk = DecryptKey(key)
+	return nil
+}
+
+// Ciphertext implements encoding/json.Unmarshaler, rejecting ciphertexts without
+// a fingerprint with ErrMissingFingerprint.
+func (ct *Ciphertext) UnmarshalJSON(b []byte) error {
+	type plain Ciphertext
+	c := plain(*ct)
+	if err := json.Unmarshal(b, &c); err != nil {
+		return err
+	} else if len(c.Fingerprint) == 0 {
+		return ErrMissingFingerprint
+	}
+
+	*ct = Ciphertext(c)
 	return nil
 }
 
+// elements lists the elements of G of dk.
+func (dk *DecryptKey) elements() []*Element {
+	els := []*Element{dk.D, dk.F}
+	for _, el := range dk.D1 {
+		els = append(els, el)
+	}
+	for _, el := range dk.D2 {
+		els = append(els, el)
+	}
+	return els
+}
+
+// elements lists the elements of G of tk.
+func (tk *TransformKey) elements() []*Element {
+	els := []*Element{tk.D}
+	for _, el := range tk.D1 {
+		els = append(els, el)
+	}
+	for _, el := range tk.D2 {
+		els = append(els, el)
+	}
+	return els
+}
+
+// elements lists the elements of G of ct, which leaves out Msg.
+func (ct *Ciphertext) elements() []*Element {
+	els := []*Element{ct.C}
+	for _, el := range ct.C1 {
+		els = append(els, el)
+	}
+	for _, el := range ct.C2 {
+		els = append(els, el)
+	}
+	return els
+}
+
 func newPolynomial(deg int) *polynomial {
 	return &polynomial{make([]*Zr, deg)}
 }
 
 func (p *polynomial) evaluate(x *Zr) *Zr {
-	output := pairing.NewZr()
-	temp := pairing.NewZr()
-	temp.E.Set1()
+	output := &Element{Field: "Zr", E: x.E.NewFieldElement(), pairing: x.pairing}
+	temp := x.E.NewFieldElement()
+	temp.Set1()
 	for i, c := range p.c {
-		temp.E.Set1()
+		temp.Set1()
 		if i != 0 {
-			temp.E.PowZn(x.E, pairing.P.NewZr().SetInt32(int32(i)))
+			temp.PowZn(x.E, x.E.NewFieldElement().SetInt32(int32(i)))
 		}
-		temp.E.Mul(temp.E, c.E)
-		output.E.Add(output.E, temp.E)
+		temp.Mul(temp, c.E)
+		output.E.Add(output.E, temp)
 	}
 	return output
 }
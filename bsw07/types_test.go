@@ -1,55 +1,278 @@
 package bsw07
 
 import (
+	"encoding/json"
+	"sort"
 	"testing"
 )
 
 func TestEvaluate1(t *testing.T) {
 	var (
-		one      = &Element{"Zr", pairing.P.NewZr().Set1()}
-		two      = &Element{"Zr", pairing.P.NewZr().SetInt32(2)}
-		three    = &Element{"Zr", pairing.P.NewZr().SetInt32(3)}
-		twoEight = &Element{"Zr", pairing.P.NewZr().SetInt32(28)}
+		one      = &Element{"Zr", defaultParams.pairing.P.NewZr().Set1(), nil, nil}
+		two      = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(2), nil, nil}
+		three    = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(3), nil, nil}
+		twoEight = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(28), nil, nil}
 	)
 
 	f := newPolynomial(1)
-	f.c[0] = &Element{"Zr", pairing.P.NewZr().SetInt32(3)}
+	f.c[0] = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(3), nil, nil}
 
-	if !(f.evaluate(&Element{"Zr", zero}).E.Equals(three.E) && f.evaluate(one).E.Equals(three.E) && f.evaluate(two).E.Equals(three.E) && f.evaluate(twoEight).E.Equals(three.E)) {
+	if !(f.evaluate(&Element{"Zr", defaultParams.zero, nil, nil}).E.Equals(three.E) && f.evaluate(one).E.Equals(three.E) && f.evaluate(two).E.Equals(three.E) && f.evaluate(twoEight).E.Equals(three.E)) {
 		t.Errorf("Polynomial (degree 1) evaluated wrongly.")
 	}
 }
 
 func TestEvaluate2(t *testing.T) {
 	var (
-		one      = &Element{"Zr", pairing.P.NewZr().Set1()}
-		two      = &Element{"Zr", pairing.P.NewZr().SetInt32(2)}
-		three    = &Element{"Zr", pairing.P.NewZr().SetInt32(3)}
-		four     = &Element{"Zr", pairing.P.NewZr().SetInt32(4)}
-		seven    = &Element{"Zr", pairing.P.NewZr().SetInt32(7)}
-		nine     = &Element{"Zr", pairing.P.NewZr().SetInt32(9)}
-		ten      = &Element{"Zr", pairing.P.NewZr().SetInt32(10)}
-		twoEight = &Element{"Zr", pairing.P.NewZr().SetInt32(28)}
+		one      = &Element{"Zr", defaultParams.pairing.P.NewZr().Set1(), nil, nil}
+		two      = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(2), nil, nil}
+		three    = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(3), nil, nil}
+		four     = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(4), nil, nil}
+		seven    = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(7), nil, nil}
+		nine     = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(9), nil, nil}
+		ten      = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(10), nil, nil}
+		twoEight = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(28), nil, nil}
 	)
 	f := newPolynomial(2)
-	f.c[1] = &Element{"Zr", pairing.P.NewZr().SetInt32(3)}
-	f.c[0] = &Element{"Zr", pairing.P.NewZr().Set1()}
+	f.c[1] = &Element{"Zr", defaultParams.pairing.P.NewZr().SetInt32(3), nil, nil}
+	f.c[0] = &Element{"Zr", defaultParams.pairing.P.NewZr().Set1(), nil, nil}
 
-	if !(f.evaluate(&Element{"Zr", zero}).E.Equals(one.E) && f.evaluate(one).E.Equals(four.E) && f.evaluate(two).E.Equals(seven.E) && f.evaluate(three).E.Equals(ten.E) && f.evaluate(nine).E.Equals(twoEight.E)) {
+	if !(f.evaluate(&Element{"Zr", defaultParams.zero, nil, nil}).E.Equals(one.E) && f.evaluate(one).E.Equals(four.E) && f.evaluate(two).E.Equals(seven.E) && f.evaluate(three).E.Equals(ten.E) && f.evaluate(nine).E.Equals(twoEight.E)) {
 		t.Errorf("Polynomial (degree 2) evaluated wrongly.")
 	}
 }
 
 func TestEvaluate3(t *testing.T) {
 	var (
-		num = &Element{"Zr", pairing.P.NewZr().Rand()}
-		one = &Element{"Zr", pairing.P.NewZr().Set1()}
+		num = &Element{"Zr", defaultParams.pairing.P.NewZr().Rand(), nil, nil}
+		one = &Element{"Zr", defaultParams.pairing.P.NewZr().Set1(), nil, nil}
 	)
 
 	f := newPolynomial(1)
 	f.c[0] = num
 
-	if !(f.evaluate(&Element{"Zr", zero}).E.Equals(num.E) && f.evaluate(one).E.Equals(num.E)) {
+	if !(f.evaluate(&Element{"Zr", defaultParams.zero, nil, nil}).E.Equals(num.E) && f.evaluate(one).E.Equals(num.E)) {
 		t.Errorf("Polynomial (degree 1, big number) evaluated wrongly.")
 	}
 }
+
+func TestElement_decode(t *testing.T) {
+	pairing := defaultParams.pairing.P
+	r := pairing.NewZr().Rand()
+
+	overflow := make([]byte, pairing.NewZr().BytesLen())
+	for i := range overflow {
+		overflow[i] = 0xff
+	}
+
+	for _, v := range []struct {
+		name string
+		el   tempEl
+		err  error
+	}{
+		{"valid G", tempEl{"G", pairing.NewG1().Rand().Bytes()}, nil},
+		{"compressed G", tempEl{"G", pairing.NewG1().Rand().CompressedBytes()}, nil},
+		{"valid GT", tempEl{"GT", pairing.NewGT().Rand().Bytes()}, nil},
+		{"valid Zr", tempEl{"Zr", r.Bytes()}, nil},
+		{"short G", tempEl{"G", pairing.NewG1().Rand().Bytes()[1:]}, ErrElementLength},
+		{"long Zr", tempEl{"Zr", append(r.Bytes(), 0)}, ErrElementLength},
+		{"empty GT", tempEl{"GT", []byte{}}, ErrElementLength},
+		{"null GT", tempEl{"GT", nil}, ErrMissingElement},
+		{"identity G", tempEl{"G", pairing.NewG1().Set1().Bytes()}, ErrIdentityElement},
+		{"identity GT", tempEl{"GT", pairing.NewGT().Set1().Bytes()}, ErrIdentityElement},
+		{"zero Zr", tempEl{"Zr", pairing.NewZr().Set0().Bytes()}, ErrIdentityElement},
+		{"overflowing Zr", tempEl{"Zr", overflow}, ErrNonCanonical},
+	} {
+		data, _ := json.Marshal(v.el)
+		el := &Element{}
+		if err := json.Unmarshal(data, el); err != v.err {
+			t.Errorf("Element %s unmarshaled with %v, expecting %v", v.name, err, v.err)
+		} else if err == nil {
+			if err := defaultParams.Bind(el); err != nil {
+				t.Errorf("Error (%v) during binding %s.", err, v.name)
+			}
+		}
+	}
+
+	el := &Element{}
+	if err := json.Unmarshal([]byte(`{"field":"G2","e":"AA=="}`), el); err != ErrUnknownField {
+		t.Errorf("Unknown field accepted: %v", err)
+	}
+
+	if err := defaultParams.Bind(nil); err != ErrMissingElement {
+		t.Errorf("Missing element accepted: %v", err)
+	}
+
+	// Elements which were not decoded are written as they were read
+	raw := pairing.NewG1().Rand().Bytes()
+	expected, _ := json.Marshal(tempEl{"G", raw})
+	if data, err := json.Marshal(&Element{Field: "G", raw: raw}); err != nil || string(data) != string(expected) {
+		t.Errorf("Element not decoded marshaled wrongly: %s, %v", data, err)
+	}
+}
+
+func TestBSW07_DecryptMalformed(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+	dk, _ := algo.KeyGen(msk, map[string]struct{}{"a": {}})
+
+	ct, err := algo.Encrypt(pk, algo.NewMessage().Rand(), &leafNode{"a", nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(ct)
+
+	identity, _ := json.Marshal(tempEl{"G", algo.params.pairing.P.NewG1().Set1().Bytes()})
+
+	// Ciphertexts tampered with are rejected when unmarshaled, or else rather
+	// than crashing Decrypt
+	for _, v := range []struct {
+		name         string
+		json         string
+		unmarshalErr error
+		decryptErr   error
+	}{
+		{"missing C", `{"c":null}`, nil, ErrMissingElement},
+		{"missing C1", `{"c1":{"0":null}}`, nil, ErrMissingElement},
+		{"null element C", `{"c":{"field":"G","e":null}}`, ErrMissingElement, nil},
+		{"identity C", `{"c":` + string(identity) + `}`, ErrIdentityElement, nil},
+		{"short C", `{"c":{"field":"G","e":"AAAA"}}`, ErrElementLength, nil},
+		{"empty tree", `{"t":""}`, nil, ErrBadNodeJSON},
+	} {
+		ct2 := &Ciphertext{}
+		if err := json.Unmarshal(data, ct2); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(v.json), ct2); err != v.unmarshalErr {
+			t.Errorf("Ciphertext with %s unmarshaled with %v, expecting %v", v.name, err, v.unmarshalErr)
+			continue
+		} else if err != nil {
+			continue
+		}
+
+		if _, err := algo.Decrypt(ct2, dk); err != v.decryptErr {
+			t.Errorf("Ciphertext with %s decrypted with %v, expecting %v", v.name, err, v.decryptErr)
+		}
+	}
+}
+
+// swapFields calls f with the JSON encoding of v once for each of its elements
+// replaced by a valid element of each other field.
+func swapFields(t *testing.T, v interface{}, f func(name string, data []byte)) {
+	pairing := defaultParams.pairing.P
+	valid := map[string][]byte{
+		"G":  pairing.NewG1().Rand().Bytes(),
+		"GT": pairing.NewGT().Rand().Bytes(),
+		"Zr": pairing.NewZr().Rand().Bytes(),
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// walk visits the elements in x in the order of their keys
+	var walk func(x interface{}, path string, visit func(el map[string]interface{}, path string))
+	walk = func(x interface{}, path string, visit func(el map[string]interface{}, path string)) {
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			return
+		} else if _, ok := m["field"]; ok {
+			visit(m, path)
+			return
+		}
+
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walk(m[k], path+"."+k, visit)
+		}
+	}
+
+	var x interface{}
+	json.Unmarshal(data, &x)
+	var paths []string
+	walk(x, "", func(_ map[string]interface{}, path string) { paths = append(paths, path) })
+
+	for _, path := range paths {
+		for _, field := range []string{"G", "GT", "Zr"} {
+			var y interface{}
+			json.Unmarshal(data, &y)
+
+			swapped := false
+			walk(y, "", func(el map[string]interface{}, p string) {
+				if p == path && el["field"] != field {
+					el["field"], el["e"] = field, valid[field]
+					swapped = true
+				}
+			})
+			if !swapped {
+				continue
+			}
+
+			out, _ := json.Marshal(y)
+			f(path+" as "+field, out)
+		}
+	}
+}
+
+func TestBSW07_WrongField(t *testing.T) {
+	algo, _ := NewBSW07()
+	pk, msk, _ := algo.Setup()
+	attrs := map[string]struct{}{"a": {}}
+	dk, _ := algo.KeyGen(msk, attrs)
+	tree, _ := ParsePolicy("a")
+
+	ct, err := algo.Encrypt(pk, algo.NewMessage().Rand(), tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Elements of the wrong field are rejected rather than reaching the pairing
+	for _, v := range []struct {
+		in  interface{}
+		use func(data []byte) error
+	}{
+		{pk, func(data []byte) error {
+			key := &PublicKey{}
+			if err := json.Unmarshal(data, key); err != nil {
+				return err
+			}
+			_, err := algo.Encrypt(key, algo.NewMessage().Rand(), tree)
+			return err
+		}},
+		{msk, func(data []byte) error {
+			key := &MasterKey{}
+			if err := json.Unmarshal(data, key); err != nil {
+				return err
+			}
+			_, err := algo.KeyGen(key, attrs)
+			return err
+		}},
+		{dk, func(data []byte) error {
+			key := &DecryptKey{}
+			if err := json.Unmarshal(data, key); err != nil {
+				return err
+			}
+			_, err := algo.Decrypt(ct, key)
+			return err
+		}},
+		{ct, func(data []byte) error {
+			ct2 := &Ciphertext{}
+			if err := json.Unmarshal(data, ct2); err != nil {
+				return err
+			}
+			_, err := algo.Decrypt(ct2, dk)
+			return err
+		}},
+	} {
+		swapFields(t, v.in, func(name string, data []byte) {
+			if err := v.use(data); err != ErrWrongField {
+				t.Errorf("%T with %s used with %v, expecting %v", v.in, name, err, ErrWrongField)
+			}
+		})
+	}
+}
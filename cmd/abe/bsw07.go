@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"ABE/bsw07"
+)
+
+var errWrongKeyType = errors.New("file does not hold the expected type of key")
+
+type bsw07Scheme struct {
+	algo *bsw07.BSW07
+}
+
+func newBSW07(params string) (*bsw07Scheme, error) {
+	var opts []bsw07.Option
+	if params != "" {
+		p, err := bsw07.ReadParamsFile(params)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, bsw07.WithParams(p))
+	}
+
+	algo, err := bsw07.NewBSW07(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &bsw07Scheme{algo}, nil
+}
+
+// readJSON decodes the named file into v.
+func readJSON(name string, v interface{}) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON encodes v into the named file, or standard output if name is empty.
+func writeJSON(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeOutput(name, data)
+}
+
+func (s *bsw07Scheme) setup(pk, msk string) error {
+	publicKey, masterKey, err := s.algo.Setup()
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(pk, publicKey); err != nil {
+		return err
+	}
+	return writeJSON(msk, masterKey)
+}
+
+func (s *bsw07Scheme) keygen(msk, spec, out string) error {
+	masterKey := &bsw07.MasterKey{}
+	if err := readJSON(msk, masterKey); err != nil {
+		return err
+	} else if masterKey.KeyType != "master" {
+		return errWrongKeyType
+	}
+
+	attrs, err := readSet(spec)
+	if err != nil {
+		return err
+	} else if len(attrs) == 0 {
+		return errMissingFlag
+	}
+
+	dk, err := s.algo.KeyGen(masterKey, attrs)
+	if err != nil {
+		return err
+	}
+	return writeJSON(out, dk)
+}
+
+func (s *bsw07Scheme) delegate(key, spec, out string) error {
+	dk, err := s.readDecryptKey(key)
+	if err != nil {
+		return err
+	}
+
+	attrs, err := readSet(spec)
+	if err != nil {
+		return err
+	} else if len(attrs) == 0 {
+		return errMissingFlag
+	}
+
+	dk, err = s.algo.Delegate(dk, attrs)
+	if err != nil {
+		return err
+	}
+	return writeJSON(out, dk)
+}
+
+func (s *bsw07Scheme) encrypt(pk, spec, in, out string) error {
+	publicKey := &bsw07.PublicKey{}
+	if err := readJSON(pk, publicKey); err != nil {
+		return err
+	} else if publicKey.KeyType != "public" {
+		return errWrongKeyType
+	}
+
+	policy, err := readSpec(spec)
+	if err != nil {
+		return err
+	}
+	tree, err := bsw07.ParsePolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := readInput(in)
+	if err != nil {
+		return err
+	}
+
+	env, err := s.algo.EncryptBytes(publicKey, plaintext, tree)
+	if err != nil {
+		return err
+	}
+	return writeJSON(out, env)
+}
+
+func (s *bsw07Scheme) decrypt(key, in, out string) error {
+	dk, err := s.readDecryptKey(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := readInput(in)
+	if err != nil {
+		return err
+	}
+	env := &bsw07.Envelope{}
+	if err := json.Unmarshal(data, env); err != nil {
+		return err
+	}
+
+	plaintext, err := s.algo.DecryptBytes(env, dk)
+	if err != nil {
+		return err
+	}
+	return writeOutput(out, plaintext)
+}
+
+func (s *bsw07Scheme) readDecryptKey(name string) (*bsw07.DecryptKey, error) {
+	dk := &bsw07.DecryptKey{}
+	if err := readJSON(name, dk); err != nil {
+		return nil, err
+	} else if dk.KeyType != "private" {
+		return nil, errWrongKeyType
+	}
+	return dk, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"io/ioutil"
+
+	"ABE/gpsw06"
+)
+
+type gpsw06Scheme struct {
+	algo *gpsw06.GPSW06
+}
+
+func newGPSW06(params string, universe []string) (*gpsw06Scheme, error) {
+	var opts []gpsw06.Option
+	if params != "" {
+		p, err := gpsw06.ReadParamsFile(params)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, gpsw06.WithParams(p))
+	}
+
+	var (
+		algo *gpsw06.GPSW06
+		err  error
+	)
+	if universe == nil {
+		algo, err = gpsw06.NewLargeUniverseGPSW06(opts...)
+	} else {
+		algo, err = gpsw06.NewGPSW06(gpsw06.NewAttributes(universe), opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &gpsw06Scheme{algo}, nil
+}
+
+// unmarshaler is implemented by the keys and ciphertexts of gpsw06.
+type unmarshaler interface {
+	Unmarshal(b []byte) ([]byte, error)
+}
+
+// marshaler is implemented by the keys and ciphertexts of gpsw06.
+type marshaler interface {
+	Marshal() ([]byte, error)
+}
+
+func readEncoded(name string, v unmarshaler) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	_, err = v.Unmarshal(data)
+	return err
+}
+
+func writeEncoded(name string, v marshaler) error {
+	data, err := v.Marshal()
+	if err != nil {
+		return err
+	}
+	return writeOutput(name, data)
+}
+
+func (s *gpsw06Scheme) setup(pk, msk string) error {
+	publicKey, masterKey, err := s.algo.Setup()
+	if err != nil {
+		return err
+	}
+	if err := writeEncoded(pk, publicKey); err != nil {
+		return err
+	}
+	return writeEncoded(msk, masterKey)
+}
+
+func (s *gpsw06Scheme) parsePolicy(spec string) (gpsw06.Node, error) {
+	policy, err := readSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	return s.algo.ParsePolicy(policy)
+}
+
+func (s *gpsw06Scheme) keygen(msk, spec, out string) error {
+	masterKey := &gpsw06.MasterKey{}
+	if err := readEncoded(msk, masterKey); err != nil {
+		return err
+	}
+
+	tree, err := s.parsePolicy(spec)
+	if err != nil {
+		return err
+	}
+
+	dk, err := s.algo.KeyGen(tree, masterKey)
+	if err != nil {
+		return err
+	}
+	return writeEncoded(out, dk)
+}
+
+func (s *gpsw06Scheme) delegate(key, spec, out string) error {
+	dk := &gpsw06.DecryptKey{}
+	if err := readEncoded(key, dk); err != nil {
+		return err
+	}
+
+	tree, err := s.parsePolicy(spec)
+	if err != nil {
+		return err
+	}
+
+	dk, err = s.algo.Delegate(dk, tree)
+	if err != nil {
+		return err
+	}
+	return writeEncoded(out, dk)
+}
+
+func (s *gpsw06Scheme) encrypt(pk, spec, in, out string) error {
+	publicKey := &gpsw06.PublicKey{}
+	if err := readEncoded(pk, publicKey); err != nil {
+		return err
+	}
+
+	labels, err := readList(spec)
+	if err != nil {
+		return err
+	} else if len(labels) == 0 {
+		return errMissingFlag
+	}
+
+	attrs := make(map[string]struct{})
+	for _, label := range labels {
+		attrs[label] = struct{}{}
+	}
+
+	plaintext, err := readInput(in)
+	if err != nil {
+		return err
+	}
+
+	env, err := s.algo.EncryptBytesLabels(plaintext, attrs, publicKey)
+	if err != nil {
+		return err
+	}
+	return writeEncoded(out, env)
+}
+
+func (s *gpsw06Scheme) decrypt(key, in, out string) error {
+	dk := &gpsw06.DecryptKey{}
+	if err := readEncoded(key, dk); err != nil {
+		return err
+	}
+
+	data, err := readInput(in)
+	if err != nil {
+		return err
+	}
+	env := &gpsw06.Envelope{}
+	if _, err := env.Unmarshal(data); err != nil {
+		return err
+	}
+
+	plaintext, err := s.algo.DecryptBytes(env, dk)
+	if err != nil {
+		return err
+	}
+	return writeOutput(out, plaintext)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ABE/bsw07"
+	"ABE/gpsw06"
+)
+
+var keyTypes = map[string]string{
+	"public":  "public key",
+	"master":  "master key",
+	"private": "decryption key",
+}
+
+// inspect describes the key, ciphertext or envelope read from in without
+// decrypting anything. universe, if given, names the attribute ids of gpsw06.
+func inspect(in, out, universe string) error {
+	data, err := readInput(in)
+	if err != nil {
+		return err
+	}
+	data = bytes.TrimSpace(data)
+
+	var lines []string
+	if bytes.HasPrefix(data, []byte("{")) {
+		lines, err = inspectBSW07(data)
+	} else {
+		var labels []string
+		if labels, err = readList(universe); err == nil {
+			lines, err = inspectGPSW06(data, labels)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(out, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+func inspectBSW07(data []byte) ([]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, errUnknownFormat
+	}
+	lines := []string{"scheme: bsw07"}
+
+	if raw, ok := fields["type"]; ok {
+		var typ string
+		if err := json.Unmarshal(raw, &typ); err != nil || keyTypes[typ] == "" {
+			return nil, errUnknownFormat
+		}
+		lines = append(lines, "kind: "+keyTypes[typ])
+
+		if typ == "private" {
+			var attrs map[string]struct{}
+			if err := json.Unmarshal(fields["s"], &attrs); err != nil {
+				return nil, err
+			}
+			labels := make([]string, 0, len(attrs))
+			for attr := range attrs {
+				labels = append(labels, attr)
+			}
+			sort.Strings(labels)
+			lines = append(lines, "attributes: "+strings.Join(labels, ", "))
+		}
+		return lines, nil
+	}
+
+	// Only the tree is read, as the elements of parameters which were not
+	// loaded cannot be decoded
+	var ct struct {
+		Tree []byte `json:"t"`
+	}
+	if key, ok := fields["key"]; ok {
+		var env struct {
+			Data []byte `json:"data"`
+		}
+		if err := json.Unmarshal(data, &env); err != nil || json.Unmarshal(key, &ct) != nil || ct.Tree == nil {
+			return nil, errUnknownFormat
+		}
+		lines = append(lines, "kind: envelope", fmt.Sprintf("payload: %d bytes", len(env.Data)))
+	} else if _, ok := fields["t"]; ok {
+		if err := json.Unmarshal(data, &ct); err != nil {
+			return nil, errUnknownFormat
+		}
+		lines = append(lines, "kind: ciphertext")
+	} else {
+		return nil, errUnknownFormat
+	}
+
+	tree, err := bsw07.NodeFromJSON(ct.Tree)
+	if err != nil {
+		return nil, err
+	}
+	return append(lines, "policy: "+tree.String()), nil
+}
+
+func inspectGPSW06(data []byte, universe []string) ([]string, error) {
+	fields, err := decodeFields(data)
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{"scheme: gpsw06"}
+
+	if raw, ok := fields["type"]; ok {
+		var typ string
+		if err := json.Unmarshal(raw, &typ); err != nil || keyTypes[typ] == "" {
+			return nil, errUnknownFormat
+		}
+		lines = append(lines, "kind: "+keyTypes[typ])
+
+		if typ == "private" {
+			var encoded []byte
+			if err := json.Unmarshal(fields["tree"], &encoded); err != nil {
+				return nil, err
+			}
+			tree, err := gpsw06.NodeFromJSON(encoded)
+			if err != nil {
+				return nil, err
+			}
+			_, delegatable := fields["r"]
+			lines = append(lines, "policy: "+tree.String(), "delegatable: "+strconv.FormatBool(delegatable))
+		}
+		return lines, nil
+	}
+
+	if raw, ok := fields["key"]; ok {
+		var key, payload []byte
+		if err := json.Unmarshal(raw, &key); err != nil {
+			return nil, errUnknownFormat
+		} else if err := json.Unmarshal(fields["data"], &payload); err != nil {
+			return nil, errUnknownFormat
+		}
+		if fields, err = decodeFields(key); err != nil {
+			return nil, err
+		}
+		lines = append(lines, "kind: envelope", fmt.Sprintf("payload: %d bytes", len(payload)))
+	} else {
+		lines = append(lines, "kind: ciphertext")
+	}
+
+	var attrs map[string]json.RawMessage
+	if raw, ok := fields["attrs"]; !ok {
+		return nil, errUnknownFormat
+	} else if err := json.Unmarshal(raw, &attrs); err != nil {
+		return nil, errUnknownFormat
+	}
+
+	// Ciphertexts of the large universe, which hold e2, name their labels
+	if _, large := fields["e2"]; large {
+		labels := make([]string, 0, len(attrs))
+		for label := range attrs {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		return append(lines, "attributes: "+strings.Join(labels, ", ")), nil
+	}
+
+	ids := make([]int, 0, len(attrs))
+	for attr := range attrs {
+		id, err := strconv.Atoi(attr)
+		if err != nil {
+			return nil, errUnknownFormat
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	// Name the ids after the universe
+	labels := make([]string, len(ids))
+	for i, id := range ids {
+		labels[i] = strconv.Itoa(id)
+		if id >= 0 && id < len(universe) {
+			labels[i] = universe[id]
+		}
+	}
+	return append(lines, "attributes: "+strings.Join(labels, ", ")), nil
+}
+
+// decodeFields decodes the base64 encoded JSON object of gpsw06.
+func decodeFields(data []byte) (map[string]json.RawMessage, error) {
+	str, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, errUnknownFormat
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(str, &fields); err != nil {
+		return nil, errUnknownFormat
+	}
+	return fields, nil
+}
@@ -0,0 +1,233 @@
+// Command abe runs the lifecycle of BSW07 and GPSW06 keys and ciphertexts.
+//
+// Usage:
+//
+//	abe setup    -scheme s -pk file -msk file
+//	abe keygen   -scheme s -msk file (-attrs list | -policy policy) [-o file]
+//	abe delegate -scheme s -key file (-attrs list | -policy policy) [-o file]
+//	abe encrypt  -scheme s -pk file (-policy policy | -attrs list) [-in file] [-o file]
+//	abe decrypt  -scheme s -key file [-in file] [-o file]
+//	abe inspect  [-in file]
+//
+// The scheme s is bsw07 (the default) or gpsw06. BSW07 keys hold attribute lists
+// and ciphertexts hold policies, while it is the other way round for GPSW06.
+// Attribute lists are separated by commas or newlines. A policy or attribute
+// list starting with @ is read from the named file.
+//
+// GPSW06 uses the large universe construction unless -universe lists the
+// attribute labels of a fixed universe. Parameters other than the default are
+// loaded from the parameter file given by -params, as written by abeparams.
+//
+// Keys and ciphertexts are written in the JSON encoding of bsw07 and the base64
+// encoding of gpsw06. Plaintexts of any size are sealed in envelopes.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var (
+	errMissingFlag   = errors.New("missing required flag")
+	errUnknownScheme = errors.New("unknown scheme")
+	errUnknownFormat = errors.New("input is neither a bsw07 nor a gpsw06 encoding")
+)
+
+// scheme runs the subcommands of one ABE scheme. spec is the attribute list or
+// policy given to the subcommand.
+type scheme interface {
+	setup(pk, msk string) error
+	keygen(msk, spec, out string) error
+	delegate(key, spec, out string) error
+	encrypt(pk, spec, in, out string) error
+	decrypt(key, in, out string) error
+}
+
+// options holds the flags shared by all subcommands.
+type options struct {
+	scheme   string
+	params   string
+	universe string
+	pk       string
+	msk      string
+	key      string
+	attrs    string
+	policy   string
+	in       string
+	out      string
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: abe setup|keygen|delegate|encrypt|decrypt|inspect [flags]")
+	fmt.Fprintln(os.Stderr, "run abe <command> -h for the flags of a command")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "abe:", err)
+		os.Exit(1)
+	}
+}
+
+func run(command string, args []string) error {
+	opts := &options{}
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.StringVar(&opts.scheme, "scheme", "bsw07", "scheme: bsw07 or gpsw06")
+	fs.StringVar(&opts.params, "params", "", "parameter file, default parameters if empty")
+	fs.StringVar(&opts.universe, "universe", "", "attribute labels of a fixed gpsw06 universe")
+	fs.StringVar(&opts.pk, "pk", "", "public key file")
+	fs.StringVar(&opts.msk, "msk", "", "master key file")
+	fs.StringVar(&opts.key, "key", "", "decryption key file")
+	fs.StringVar(&opts.attrs, "attrs", "", "attribute list")
+	fs.StringVar(&opts.policy, "policy", "", "policy")
+	fs.StringVar(&opts.in, "in", "", "input file, standard input if empty")
+	fs.StringVar(&opts.out, "o", "", "output file, standard output if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if command == "inspect" {
+		return inspect(opts.in, opts.out, opts.universe)
+	}
+
+	s, err := newScheme(opts)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "setup":
+		if err := require(opts.pk, opts.msk); err != nil {
+			return err
+		}
+		return s.setup(opts.pk, opts.msk)
+	case "keygen":
+		if err := require(opts.msk); err != nil {
+			return err
+		}
+		return s.keygen(opts.msk, opts.spec(), opts.out)
+	case "delegate":
+		if err := require(opts.key); err != nil {
+			return err
+		}
+		return s.delegate(opts.key, opts.spec(), opts.out)
+	case "encrypt":
+		if err := require(opts.pk); err != nil {
+			return err
+		}
+		return s.encrypt(opts.pk, opts.spec(), opts.in, opts.out)
+	case "decrypt":
+		if err := require(opts.key); err != nil {
+			return err
+		}
+		return s.decrypt(opts.key, opts.in, opts.out)
+	default:
+		usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func newScheme(opts *options) (scheme, error) {
+	switch opts.scheme {
+	case "bsw07":
+		return newBSW07(opts.params)
+	case "gpsw06":
+		var universe []string
+		if opts.universe != "" {
+			labels, err := readList(opts.universe)
+			if err != nil {
+				return nil, err
+			}
+			universe = labels
+		}
+		return newGPSW06(opts.params, universe)
+	default:
+		return nil, errUnknownScheme
+	}
+}
+
+// spec returns whichever of the attribute list and the policy was given.
+func (opts *options) spec() string {
+	if opts.policy != "" {
+		return opts.policy
+	}
+	return opts.attrs
+}
+
+func require(values ...string) error {
+	for _, v := range values {
+		if v == "" {
+			return errMissingFlag
+		}
+	}
+	return nil
+}
+
+// readSpec returns spec, or the content of the named file if spec starts with @.
+func readSpec(spec string) (string, error) {
+	if !strings.HasPrefix(spec, "@") {
+		return spec, nil
+	}
+
+	data, err := ioutil.ReadFile(spec[1:])
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// readList splits the attribute list spec by commas and newlines.
+func readList(spec string) ([]string, error) {
+	spec, err := readSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []string
+	for _, item := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
+// readSet is like readList, but returns a set.
+func readSet(spec string) (map[string]struct{}, error) {
+	list, err := readList(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]struct{})
+	for _, item := range list {
+		set[item] = struct{}{}
+	}
+	return set, nil
+}
+
+// readInput reads the named file, or standard input if name is empty.
+func readInput(name string) ([]byte, error) {
+	if name == "" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// writeOutput writes data to the named file, or standard output if name is empty.
+func writeOutput(name string, data []byte) error {
+	if name == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(name, data, 0600)
+}
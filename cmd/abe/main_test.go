@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := func(name string) string { return filepath.Join(dir, name) }
+	plaintext := []byte("attack at dawn")
+	if err := ioutil.WriteFile(path("plain"), plaintext, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path("attrs"), []byte("a\nb\nc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		scheme   []string
+		keySpec  []string // spec of the decryption key
+		narrow   []string // spec of the delegated key
+		ctSpec   []string // spec of the ciphertext
+		inspects string   // line expected from inspecting the envelope
+	}{
+		{
+			[]string{"-scheme", "bsw07"},
+			[]string{"-attrs", "@" + path("attrs")},
+			[]string{"-attrs", "a,b"},
+			[]string{"-policy", "a AND b"},
+			"policy: a AND b",
+		},
+		{
+			[]string{"-scheme", "gpsw06"},
+			[]string{"-policy", "a OR (b AND c)"},
+			[]string{"-policy", "(a OR (b AND c)) AND d"},
+			[]string{"-attrs", "a,d"},
+			"attributes: ",
+		},
+	}
+
+	for _, test := range tests {
+		name := test.scheme[1]
+		commands := []struct {
+			command string
+			args    []string
+		}{
+			{"setup", []string{"-pk", path("pk"), "-msk", path("msk")}},
+			{"keygen", append([]string{"-msk", path("msk"), "-o", path("dk")}, test.keySpec...)},
+			{"delegate", append([]string{"-key", path("dk"), "-o", path("dk2")}, test.narrow...)},
+			{"encrypt", append([]string{"-pk", path("pk"), "-in", path("plain"), "-o", path("env")}, test.ctSpec...)},
+			{"decrypt", []string{"-key", path("dk"), "-in", path("env"), "-o", path("out")}},
+			{"decrypt", []string{"-key", path("dk2"), "-in", path("env"), "-o", path("out2")}},
+		}
+
+		for _, c := range commands {
+			if err := run(c.command, append(test.scheme, c.args...)); err != nil {
+				t.Fatalf("Error (%v) during %s %s.", err, name, c.command)
+			}
+		}
+
+		for _, out := range []string{"out", "out2"} {
+			if data, err := ioutil.ReadFile(path(out)); err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(data, plaintext) {
+				t.Errorf("%s plaintext does not match. (In: %q, Out: %q)", name, plaintext, data)
+			}
+		}
+
+		if err := run("inspect", []string{"-in", path("env"), "-o", path("info")}); err != nil {
+			t.Errorf("Error (%v) during %s inspect.", err, name)
+		} else if info, err := ioutil.ReadFile(path("info")); err != nil {
+			t.Error(err)
+		} else if !strings.Contains(string(info), "scheme: "+name) || !strings.Contains(string(info), test.inspects) {
+			t.Errorf("Unexpected %s inspect output: %q", name, info)
+		}
+
+		if err := run("inspect", []string{"-in", path("dk"), "-o", path("info")}); err != nil {
+			t.Errorf("Error (%v) during %s inspect of a key.", err, name)
+		}
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	if err := run("setup", []string{"-scheme", "none", "-pk", "pk", "-msk", "msk"}); err != errUnknownScheme {
+		t.Errorf("Unknown scheme accepted: %v", err)
+	}
+
+	if err := run("setup", []string{"-pk", "pk"}); err != errMissingFlag {
+		t.Errorf("Missing flag accepted: %v", err)
+	}
+
+	if err := run("inspect", []string{"-in", os.DevNull}); err != errUnknownFormat {
+		t.Errorf("Empty input inspected: %v", err)
+	}
+}
+
+func TestReadList(t *testing.T) {
+	list, err := readList(" a, b\nc,,\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(list, "|") != "a|b|c" {
+		t.Errorf("Attribute list does not match. (Out: %q)", list)
+	}
+}
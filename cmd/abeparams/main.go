@@ -0,0 +1,101 @@
+// Command abeparams generates pairing parameters and writes them as a parameter
+// file that bsw07.ReadParamsFile and gpsw06.ReadParamsFile load directly.
+//
+// Usage:
+//
+//	abeparams [-type a|a1|e|f] [-rbits n] [-qbits n] [-primes n] [-g1 elem] [-g2 elem] [-o file]
+//
+// Types a, a1 and e are symmetric and can be used by both bsw07 and gpsw06,
+// while type f is asymmetric and only usable by gpsw06. Generators are chosen
+// at random unless given in the format of pbc.Element.SetString.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	"ABE/gpsw06"
+	"github.com/Nik-U/pbc"
+)
+
+var errUnknownType = errors.New("unknown pairing type")
+
+// generate creates pairing parameters of type typ. rbits is the size of the
+// group order of types a, e and f, and of each of the primes multiplied into
+// the group order of type a1. qbits is the size of the base field of types a
+// and e.
+func generate(typ string, rbits, qbits uint, primes int) (*pbc.Params, error) {
+	switch typ {
+	case "a":
+		return pbc.GenerateA(uint32(rbits), uint32(qbits)), nil
+	case "a1":
+		if primes < 2 {
+			return nil, errors.New("type a1 requires at least 2 primes")
+		}
+		n := big.NewInt(1)
+		for i := 0; i < primes; i++ {
+			p, err := rand.Prime(rand.Reader, int(rbits))
+			if err != nil {
+				return nil, err
+			}
+			n.Mul(n, p)
+		}
+		return pbc.GenerateA1(n), nil
+	case "e":
+		return pbc.GenerateE(uint32(rbits), uint32(qbits)), nil
+	case "f":
+		return pbc.GenerateF(uint32(rbits)), nil
+	default:
+		return nil, errUnknownType
+	}
+}
+
+func main() {
+	var (
+		typ    = flag.String("type", "a", "pairing type: a, a1, e or f")
+		rbits  = flag.Uint("rbits", 160, "bits of group order, or of each prime for type a1")
+		qbits  = flag.Uint("qbits", 512, "bits of base field for types a and e")
+		primes = flag.Int("primes", 2, "number of primes in group order for type a1")
+		g1     = flag.String("g1", "", "generator of G1, random if empty")
+		g2     = flag.String("g2", "", "generator of G2, random if empty")
+		output = flag.String("o", "", "output file, standard output if empty")
+	)
+	flag.Parse()
+
+	if err := run(*typ, *rbits, *qbits, *primes, *g1, *g2, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "abeparams:", err)
+		os.Exit(1)
+	}
+}
+
+func run(typ string, rbits, qbits uint, primes int, g1, g2, output string) error {
+	pbc.SetCryptoRandom()
+
+	p, err := generate(typ, rbits, qbits, primes)
+	if err != nil {
+		return err
+	}
+
+	params, err := gpsw06.NewParams(p.String(), g1, g2)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(params, "", "\t")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(output, data, 0644)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ABE/bsw07"
+	"ABE/gpsw06"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abeparams")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, typ := range []string{"a", "a1", "e", "f"} {
+		path := filepath.Join(dir, typ+".json")
+		if err := run(typ, 160, 512, 2, "", "", path); err != nil {
+			t.Errorf("Error (%v) during generating type %s parameters.", err, typ)
+			continue
+		}
+
+		if _, err := gpsw06.ReadParamsFile(path); err != nil {
+			t.Errorf("Error (%v) during loading type %s parameters into gpsw06.", err, typ)
+		}
+
+		_, err := bsw07.ReadParamsFile(path)
+		if typ == "f" && err != bsw07.ErrAsymmetricParams {
+			t.Errorf("Type f parameters loaded into bsw07: %v", err)
+		} else if typ != "f" && err != nil {
+			t.Errorf("Error (%v) during loading type %s parameters into bsw07.", err, typ)
+		}
+	}
+
+	if err := run("d", 160, 512, 2, "", "", ""); err != errUnknownType {
+		t.Errorf("Unknown type accepted: %v", err)
+	}
+}
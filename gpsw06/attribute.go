@@ -1,5 +1,7 @@
 package gpsw06
 
+import "strconv"
+
 type Attribute struct {
 	label string
 	id    uint
@@ -14,3 +16,53 @@ func NewAttributes(labels []string) []Attribute {
 
 	return attrs
 }
+
+// attributeID resolves label to its index in the universe of algo.
+func (algo *GPSW06) attributeID(label string) (int, error) {
+	for _, attr := range algo.universe {
+		if attr.label == label {
+			return int(attr.id), nil
+		}
+	}
+	return 0, ErrUnknownAttribute
+}
+
+// attrKey identifies the attribute of a leaf in keys and ciphertexts, which is
+// an index of the universe, or a label of the large universe.
+type attrKey struct {
+	id    int
+	label string
+}
+
+// String formats k as a key of the JSON encodings of keys and ciphertexts.
+func (k attrKey) String() string {
+	if k.label != "" {
+		return k.label
+	}
+	return strconv.Itoa(k.id)
+}
+
+// less orders keys by index, or by label in the large universe.
+func (k attrKey) less(other attrKey) bool {
+	if k.id != other.id {
+		return k.id < other.id
+	}
+	return k.label < other.label
+}
+
+// parseKey reverses String for keys of the large universe if large is set, and
+// for indices in canonical form otherwise.
+func parseKey(s string, large bool) (attrKey, error) {
+	if large {
+		if s == "" {
+			return attrKey{}, ErrEmptyLabel
+		}
+		return attrKey{0, s}, nil
+	}
+
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 0 || strconv.Itoa(id) != s {
+		return attrKey{}, ErrAttrOutOfRange
+	}
+	return attrKey{id, ""}, nil
+}
@@ -24,3 +24,37 @@ func TestNewAttributes(t *testing.T) {
 		}
 	}
 }
+
+func TestHashAttribute(t *testing.T) {
+	params, err := DefaultParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !params.hashAttribute("a").Equals(params.hashAttribute("a")) {
+		t.Errorf("hashAttribute is not deterministic")
+	}
+
+	if params.hashAttribute("a").Equals(params.hashAttribute("b")) {
+		t.Errorf("hashAttribute maps different labels to the same element")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for _, key := range []attrKey{{0, ""}, {17, ""}, {0, "a"}, {0, "17"}} {
+		parsed, err := parseKey(key.String(), key.label != "")
+		if err != nil || parsed != key {
+			t.Errorf("Key %v parsed as %v: %v", key, parsed, err)
+		}
+	}
+
+	for _, s := range []string{"", "-1", "01", "+1", "a"} {
+		if _, err := parseKey(s, false); err != ErrAttrOutOfRange {
+			t.Errorf("Index %q accepted: %v", s, err)
+		}
+	}
+
+	if _, err := parseKey("", true); err != ErrEmptyLabel {
+		t.Errorf("Empty label accepted: %v", err)
+	}
+}
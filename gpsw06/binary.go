@@ -0,0 +1,625 @@
+package gpsw06
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// BinaryVersion is the version of encodings written by the MarshalBinary
+// methods. Version 1 lacks the parameter fingerprints of keys and ciphertexts,
+// and versions before 3 identify attributes of the large universe by truncated
+// hashes of their labels, which are no longer read.
+const BinaryVersion = 3
+
+// Type tags following the version byte of binary encodings.
+const (
+	binaryPublicKey byte = iota + 1
+	binaryMasterKey
+	binaryDecryptKey
+	binaryCiphertext
+	binaryEnvelope
+	binaryPolicy
+)
+
+// Node tags of binary policies.
+const (
+	binaryLeaf byte = iota
+	binaryGate
+	binaryLabel // leaf of a label of the large universe, since version 3
+)
+
+// maxNodeDepth bounds the nesting of gates in binary policies and of parentheses
+// in policy strings, far beyond that of expanded policies, so that decoding
+// crafted input cannot exhaust the stack.
+const maxNodeDepth = 1024
+
+// encoder appends the fields of a binary encoding to buf. Variable-length
+// fields are prefixed by their length as a uvarint, and points of G1 and G2
+// are compressed.
+type encoder struct {
+	buf []byte
+}
+
+func newEncoder(tag byte) *encoder {
+	return &encoder{[]byte{BinaryVersion, tag}}
+}
+
+func (e *encoder) uvarint(x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, b[:binary.PutUvarint(b[:], x)]...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// element writes an element of GT or Zr.
+func (e *encoder) element(el *Zr) {
+	e.bytes(el.Bytes())
+}
+
+// point writes a point of G1 or G2, or an empty field if el is nil.
+func (e *encoder) point(el *G1) {
+	if el == nil {
+		e.bytes(nil)
+		return
+	}
+	e.bytes(el.CompressedBytes())
+}
+
+// key writes the index of an attribute, or its label in the large universe.
+func (e *encoder) key(k attrKey) {
+	if k.label != "" {
+		e.bytes([]byte(k.label))
+		return
+	}
+	e.uvarint(uint64(k.id))
+}
+
+// points writes the points of m in ascending order of attributes.
+func (e *encoder) points(m map[attrKey]*G1) {
+	keys := make([]attrKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+
+	e.uvarint(uint64(len(keys)))
+	for _, k := range keys {
+		e.key(k)
+		e.point(m[k])
+	}
+}
+
+// list writes the fields of l, as read by decoder.list.
+func (e *encoder) list(l [][]byte) {
+	e.uvarint(uint64(len(l)))
+	for _, b := range l {
+		e.bytes(b)
+	}
+}
+
+// rawPoints is like points for encodings of points, as read by decoder.points,
+// whose keys are labels if large is set.
+func (e *encoder) rawPoints(m map[string][]byte, large bool) error {
+	keys := make([]attrKey, 0, len(m))
+	for k := range m {
+		key, err := parseKey(k, large)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+
+	e.uvarint(uint64(len(keys)))
+	for _, k := range keys {
+		e.key(k)
+		e.bytes(m[k.String()])
+	}
+	return nil
+}
+
+func (e *encoder) node(x Node) error {
+	switch node := x.(type) {
+	case *leafNode:
+		if node.Label != "" {
+			e.buf = append(e.buf, binaryLabel)
+			e.bytes([]byte(node.Label))
+			break
+		}
+		e.buf = append(e.buf, binaryLeaf)
+		e.uvarint(uint64(node.Attr))
+	case *nonLeafNode:
+		e.buf = append(e.buf, binaryGate, byte(node.Gate))
+		e.uvarint(uint64(node.K))
+		e.uvarint(uint64(len(node.Children)))
+		for _, child := range node.Children {
+			if err := e.node(child); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrUnknownNodeType
+	}
+	return nil
+}
+
+// tree writes the access tree encoded in JSON by data.
+func (e *encoder) tree(data []byte) error {
+	tree, err := NodeFromJSON(data)
+	if err != nil {
+		return err
+	}
+	return e.node(tree)
+}
+
+// decoder reads the fields written by encoder. The first error is kept and
+// reported by finish, and later reads return zero values.
+type decoder struct {
+	buf     []byte
+	err     error
+	version byte
+}
+
+// newDecoder starts decoding data, which fails with wrongType unless data is
+// an encoding of type tag.
+func newDecoder(data []byte, tag byte, wrongType error) *decoder {
+	d := &decoder{buf: data}
+	switch {
+	case len(data) < 2:
+		d.err = ErrBadBinary
+	case data[0] < 1 || data[0] > BinaryVersion:
+		d.err = ErrBinaryVersion
+	case data[1] != tag:
+		d.err = wrongType
+	default:
+		d.buf, d.version = data[2:], data[0]
+	}
+	return d
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	} else if len(d.buf) == 0 {
+		d.err = ErrBadBinary
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = ErrBadBinary
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return x
+}
+
+// int reads a non-negative int.
+func (d *decoder) int() int {
+	x := d.uvarint()
+	if x > uint64(^uint(0)>>1) {
+		d.err = ErrBadBinary
+		return 0
+	}
+	return int(x)
+}
+
+// count reads the number of items of a list, each of which takes at least one
+// byte, so that corrupt counts cannot cause huge allocations.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.err = ErrBadBinary
+		return 0
+	}
+	return int(n)
+}
+
+// bytes reads a field, which is nil if empty.
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	if d.err != nil || n == 0 {
+		return nil
+	}
+	b := d.buf[:n:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+// fingerprint reads a parameter fingerprint, which is required since version 2
+// and absent before.
+func (d *decoder) fingerprint() []byte {
+	if d.version < 2 {
+		// Legacy encodings are decoded with any loaded parameters they fit
+		return nil
+	}
+
+	fp := d.bytes()
+	if len(fp) == 0 && d.err == nil {
+		d.err = ErrMissingFingerprint
+	}
+	return fp
+}
+
+func (d *decoder) list() [][]byte {
+	n := d.count()
+	l := make([][]byte, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		l = append(l, d.bytes())
+	}
+	return l
+}
+
+// label reads a non-empty label of the large universe, which is only found in
+// encodings since version 3.
+func (d *decoder) label() string {
+	if d.err == nil && d.version < 3 {
+		d.err = ErrBinaryVersion
+	}
+	label := d.bytes()
+	if d.err == nil && len(label) == 0 {
+		d.err = ErrEmptyLabel
+	}
+	return string(label)
+}
+
+// points reads points keyed by attrKey.String, whose keys are labels if large
+// is set.
+func (d *decoder) points(large bool) map[string][]byte {
+	n := d.count()
+	m := make(map[string][]byte, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		k := attrKey{}
+		if large {
+			k.label = d.label()
+		} else {
+			k.id = d.int()
+		}
+		m[k.String()] = d.bytes()
+	}
+	return m
+}
+
+// node reads a node nested in depth gates.
+func (d *decoder) node(depth int) Node {
+	switch d.byte() {
+	case binaryLeaf:
+		return &leafNode{d.int(), "", nil}
+	case binaryLabel:
+		return &leafNode{0, d.label(), nil}
+	case binaryGate:
+		if d.err == nil && depth >= maxNodeDepth {
+			d.err = ErrBadBinary
+		}
+		gate, k, n := operator(d.byte()), d.int(), d.count()
+		if d.err != nil {
+			return nil
+		}
+
+		switch {
+		case n == 0:
+			d.err = ErrEmptyGate
+			return nil
+		case gate == or || gate == and:
+			k = 0
+		case gate == threshold:
+			if k < 1 || k > n {
+				d.err = ErrInvalidThreshold
+				return nil
+			}
+		default:
+			d.err = ErrUnknownNodeType
+			return nil
+		}
+
+		children := make([]Node, 0, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			children = append(children, d.node(depth+1))
+		}
+		if d.err != nil {
+			return nil
+		}
+
+		x := newGate(gate, children)
+		x.K = k
+		return x
+	default:
+		if d.err == nil {
+			d.err = ErrUnknownNodeType
+		}
+		return nil
+	}
+}
+
+// tree reads an access tree and returns its JSON encoding, as held by keys, and
+// whether its leaves hold labels, as those of keys of the large universe do.
+func (d *decoder) tree() ([]byte, bool) {
+	x := d.node(0)
+	if d.err != nil {
+		return nil, false
+	}
+
+	data, err := x.MarshalJSON()
+	if err != nil {
+		d.err = err
+	}
+	return data, hasLabels(x)
+}
+
+// hasLabels reports whether any leaf of x holds a label.
+func hasLabels(x Node) bool {
+	switch node := x.(type) {
+	case *leafNode:
+		return node.Label != ""
+	case *nonLeafNode:
+		for _, child := range node.Children {
+			if hasLabels(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// finish returns the first error of d, or ErrBadBinary if bytes are left over.
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.buf) != 0 {
+		return ErrBadBinary
+	}
+	return d.err
+}
+
+// MarshalBinary encodes the tree rooted at l in the binary format of policies.
+func (l *leafNode) MarshalBinary() ([]byte, error) {
+	return marshalNode(l)
+}
+
+// MarshalBinary encodes the tree rooted at n in the binary format of policies.
+func (n *nonLeafNode) MarshalBinary() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func marshalNode(x Node) ([]byte, error) {
+	e := newEncoder(binaryPolicy)
+	if err := e.node(x); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// NodeFromBinary decodes a policy encoded by Node.MarshalBinary.
+func NodeFromBinary(data []byte) (Node, error) {
+	d := newDecoder(data, binaryPolicy, ErrBinaryType)
+	x := d.node(0)
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// PublicKey implements encoding.BinaryMarshaler.
+func (pk *PublicKey) MarshalBinary() ([]byte, error) {
+	e := newEncoder(binaryPublicKey)
+	e.bytes(pk.fingerprint())
+	if pk.params == nil && pk.raw != nil {
+		// Key not decoded, which is written as it was read
+		e.list(pk.raw.T)
+		e.bytes(pk.raw.Y)
+		return e.buf, nil
+	}
+
+	e.uvarint(uint64(len(pk.t)))
+	for _, t := range pk.t {
+		e.point(t)
+	}
+	e.element(pk.y)
+	return e.buf, nil
+}
+
+// PublicKey implements encoding.BinaryUnmarshaler. Like Unmarshal, the key is
+// decoded with the parameters of its fingerprint.
+func (pk *PublicKey) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryPublicKey, ErrExpectingPublicKey)
+	fp := d.fingerprint()
+	t := d.list()
+	y := d.bytes()
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	pk.t, pk.y, pk.params, pk.raw = nil, nil, nil, &publicKey{"public", t, y, fp}
+	return decodeLoaded(fp, pk.decode)
+}
+
+// MasterKey implements encoding.BinaryMarshaler.
+func (msk *MasterKey) MarshalBinary() ([]byte, error) {
+	e := newEncoder(binaryMasterKey)
+	e.bytes(msk.fingerprint())
+	if msk.params == nil && msk.raw != nil {
+		// Key not decoded, which is written as it was read
+		e.list(msk.raw.T)
+		e.bytes(msk.raw.Y)
+		return e.buf, nil
+	}
+
+	e.uvarint(uint64(len(msk.t)))
+	for _, t := range msk.t {
+		e.element(t)
+	}
+	e.element(msk.y)
+	return e.buf, nil
+}
+
+// MasterKey implements encoding.BinaryUnmarshaler.
+func (msk *MasterKey) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryMasterKey, ErrExpectingMasterKey)
+	fp := d.fingerprint()
+	t := d.list()
+	y := d.bytes()
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	msk.t, msk.y, msk.params, msk.raw = nil, nil, nil, &masterKey{"master", t, y, fp}
+	return decodeLoaded(fp, msk.decode)
+}
+
+// DecryptKey implements encoding.BinaryMarshaler. The access tree is written in
+// the binary format of policies.
+func (dk *DecryptKey) MarshalBinary() ([]byte, error) {
+	e := newEncoder(binaryDecryptKey)
+	e.bytes(dk.fingerprint())
+	if err := e.tree(dk.tree); err != nil {
+		return nil, err
+	}
+
+	if dk.params == nil && dk.raw != nil {
+		// Key not decoded, which is written as it was read
+		large := dk.raw.R != nil
+		if err := e.rawPoints(dk.raw.D, large); err != nil {
+			return nil, err
+		}
+		if !large {
+			e.buf = append(e.buf, 0)
+		} else {
+			e.buf = append(e.buf, 1)
+			if err := e.rawPoints(dk.raw.R, large); err != nil {
+				return nil, err
+			}
+		}
+		return e.buf, nil
+	}
+
+	e.points(dk.d)
+
+	// Only keys of the large universe hold r
+	if dk.r == nil {
+		e.buf = append(e.buf, 0)
+	} else {
+		e.buf = append(e.buf, 1)
+		e.points(dk.r)
+	}
+
+	return e.buf, nil
+}
+
+// DecryptKey implements encoding.BinaryUnmarshaler.
+func (dk *DecryptKey) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryDecryptKey, ErrExpectingPrivateKey)
+	fp := d.fingerprint()
+	tree, large := d.tree()
+	dd := d.points(large)
+
+	// Only keys of the large universe, whose leaves hold labels, hold r
+	var r map[string][]byte
+	switch flag := d.byte(); {
+	case flag == 0 && !large:
+	case flag == 1 && large:
+		r = d.points(large)
+	case flag == 1 && d.version < 3:
+		// Keys of the large universe holding hashes of their labels
+		d.err = ErrBinaryVersion
+	default:
+		if d.err == nil {
+			d.err = ErrBadBinary
+		}
+	}
+
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	dk.d, dk.tree, dk.r, dk.params, dk.raw = nil, tree, nil, nil, &decryptKey{"private", dd, tree, r, fp}
+	return decodeLoaded(fp, dk.decode)
+}
+
+func (ct *Ciphertext) encodeBinary(e *encoder) error {
+	e.bytes(ct.fingerprint())
+	if ct.params == nil && ct.raw != nil {
+		// Ciphertext not decoded, which is written as it was read
+		e.bytes(ct.raw.Msg)
+		e.bytes(ct.raw.E2)
+		return e.rawPoints(ct.raw.Attrs, ct.raw.E2 != nil)
+	}
+
+	e.element(ct.encMsg)
+	e.point(ct.e2)
+	e.points(ct.encAttrs)
+	return nil
+}
+
+func (ct *Ciphertext) decodeBinary(d *decoder) {
+	instance := &ciphertext{}
+	instance.Fingerprint = d.fingerprint()
+	instance.Msg = d.bytes()
+	instance.E2 = d.bytes()
+	instance.Attrs = d.points(instance.E2 != nil)
+	if d.err != nil {
+		return
+	}
+
+	a, err := instance.attrs()
+	if err != nil {
+		d.err = err
+		return
+	}
+
+	ct.attrs, ct.encMsg, ct.encAttrs, ct.e2, ct.params, ct.raw = a, nil, nil, nil, nil, instance
+	d.err = decodeLoaded(instance.Fingerprint, ct.decode)
+}
+
+// Ciphertext implements encoding.BinaryMarshaler.
+func (ct *Ciphertext) MarshalBinary() ([]byte, error) {
+	e := newEncoder(binaryCiphertext)
+	if err := ct.encodeBinary(e); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Ciphertext implements encoding.BinaryUnmarshaler.
+func (ct *Ciphertext) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryCiphertext, ErrBinaryType)
+	ct.decodeBinary(d)
+	return d.finish()
+}
+
+// Envelope implements encoding.BinaryMarshaler.
+func (env *Envelope) MarshalBinary() ([]byte, error) {
+	if env.key == nil {
+		return nil, ErrBadEnvelope
+	}
+
+	e := newEncoder(binaryEnvelope)
+	if err := env.key.encodeBinary(e); err != nil {
+		return nil, err
+	}
+	e.bytes(env.nonce)
+	e.bytes(env.data)
+	return e.buf, nil
+}
+
+// Envelope implements encoding.BinaryUnmarshaler.
+func (env *Envelope) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, binaryEnvelope, ErrBinaryType)
+	key := &Ciphertext{}
+	key.decodeBinary(d)
+	nonce, payload := d.bytes(), d.bytes()
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	env.key, env.nonce, env.data = key, nonce, payload
+	return nil
+}
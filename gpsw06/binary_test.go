@@ -0,0 +1,165 @@
+package gpsw06
+
+import (
+	"encoding"
+	"testing"
+)
+
+func TestNodeFromBinary(t *testing.T) {
+	for _, policy := range []string{"1", "1 AND 2", "1 OR (2 AND 3)", "2 of (1, 2 AND 3, 4)"} {
+		tree, err := ParsePolicy(policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := tree.MarshalBinary()
+		if err != nil {
+			t.Errorf("Error (%v) during marshaling %q.", err, policy)
+			continue
+		}
+
+		tree2, err := NodeFromBinary(data)
+		if err != nil {
+			t.Errorf("Error (%v) during unmarshaling %q.", err, policy)
+		} else if !tree.Equal(tree2) {
+			t.Errorf("Policy %q unmarshaled wrongly: %v", policy, tree2)
+		}
+	}
+
+	for _, data := range [][]byte{
+		{},
+		{BinaryVersion},
+		{BinaryVersion + 1, binaryPolicy, binaryLeaf, 1},
+		{BinaryVersion, binaryPublicKey, binaryLeaf, 1},
+		{BinaryVersion, binaryPolicy, binaryLeaf},
+		{BinaryVersion, binaryPolicy, binaryLeaf, 1, 0},
+		{BinaryVersion, binaryPolicy, binaryGate, byte(or), 0, 0},
+		{BinaryVersion, binaryPolicy, binaryGate, byte(threshold), 2, 1, binaryLeaf, 1},
+		{BinaryVersion, binaryPolicy, 7},
+	} {
+		if _, err := NodeFromBinary(data); err == nil {
+			t.Errorf("Malformed policy %v accepted", data)
+		}
+	}
+
+	// Gates nested beyond maxNodeDepth are rejected before exhausting the stack
+	nested := func(depth int) []byte {
+		data := []byte{BinaryVersion, binaryPolicy}
+		for i := 0; i < depth; i++ {
+			data = append(data, binaryGate, byte(or), 0, 1)
+		}
+		return append(data, binaryLeaf, 1)
+	}
+	if _, err := NodeFromBinary(nested(maxNodeDepth)); err != nil {
+		t.Errorf("Error (%v) during unmarshaling policy nested %d deep.", err, maxNodeDepth)
+	}
+	if _, err := NodeFromBinary(nested(maxNodeDepth + 1)); err != ErrBadBinary {
+		t.Errorf("Policy nested too deep unmarshaled with %v, expecting %v", err, ErrBadBinary)
+	}
+}
+
+// marshaler is implemented by the keys and ciphertexts of the package.
+type marshaler interface {
+	encoding.BinaryMarshaler
+	Marshal() ([]byte, error)
+}
+
+func TestBinary(t *testing.T) {
+	for _, large := range []bool{false, true} {
+		algo := wideInstance(4, large)
+		pk, msk, _ := algo.Setup()
+
+		tree, _ := algo.ParsePolicy("1 AND (2 OR 3)")
+		dk, err := algo.KeyGen(tree, msk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		labels := map[string]struct{}{"1": {}, "2": {}}
+		msg := algo.NewMessage().Rand()
+		ct, err := algo.EncryptLabels(msg, labels, pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		env, err := algo.EncryptBytesLabels([]byte("plaintext"), labels, pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Decoded values must encode to the same base64 encoding
+		for _, v := range []struct {
+			in  marshaler
+			out interface {
+				marshaler
+				encoding.BinaryUnmarshaler
+			}
+		}{
+			{pk, &PublicKey{}},
+			{msk, &MasterKey{}},
+			{dk, &DecryptKey{}},
+			{ct, &Ciphertext{}},
+			{env, &Envelope{}},
+		} {
+			data, err := v.in.MarshalBinary()
+			if err != nil {
+				t.Errorf("Error (%v) during marshaling %T.", err, v.in)
+				continue
+			}
+
+			if err := v.out.UnmarshalBinary(data); err != nil {
+				t.Errorf("Error (%v) during unmarshaling %T.", err, v.in)
+				continue
+			}
+
+			expected, _ := v.in.Marshal()
+			actual, _ := v.out.Marshal()
+			if string(expected) != string(actual) {
+				t.Errorf("%T unmarshaled wrongly (large universe: %v)", v.in, large)
+			}
+
+			if len(data) >= len(expected) {
+				t.Errorf("Binary encoding of %T (%d bytes) is not smaller than base64 (%d bytes)", v.in, len(data), len(expected))
+			}
+
+			if err := v.out.UnmarshalBinary(data[:len(data)-1]); err == nil {
+				t.Errorf("Truncated %T accepted", v.in)
+			}
+		}
+
+		data, _ := msk.MarshalBinary()
+		if err := (&PublicKey{}).UnmarshalBinary(data); err != ErrExpectingPublicKey {
+			t.Errorf("Master key accepted as public key: %v", err)
+		}
+
+		// Decoded keys and ciphertexts still work together
+		data, _ = ct.MarshalBinary()
+		ct2 := &Ciphertext{}
+		if err := ct2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		data, _ = dk.MarshalBinary()
+		dk2 := &DecryptKey{}
+		if err := dk2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+
+		if plain, err := algo.Decrypt(ct2, dk2); err != nil {
+			t.Errorf("Error (%v) during decryption.", err)
+		} else if !plain.m.Equals(msg.m) {
+			t.Errorf("Message before encryption and after decryption differs.")
+		}
+
+		data, _ = env.MarshalBinary()
+		env2 := &Envelope{}
+		if err := env2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+
+		if plaintext, err := algo.DecryptBytes(env2, dk2); err != nil {
+			t.Errorf("Error (%v) during decryption of envelope.", err)
+		} else if string(plaintext) != "plaintext" {
+			t.Errorf("Envelope decrypted wrongly: %q", plaintext)
+		}
+	}
+}
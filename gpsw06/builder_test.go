@@ -0,0 +1,77 @@
+package gpsw06
+
+import "testing"
+
+func TestBuilder(t *testing.T) {
+	sub, err := And(Leaf(3), Leaf(4))
+	if err != nil {
+		t.Errorf("Error (%v) during building and gate.", err)
+		return
+	}
+
+	tree, err := Or(Leaf(1), Leaf(2), sub)
+	if err != nil {
+		t.Errorf("Error (%v) during building or gate.", err)
+		return
+	}
+
+	parsed, err := ParsePolicy("1 OR 2 OR (3 AND 4)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	if !tree.Equal(parsed) {
+		t.Errorf("Built tree does not match with parsed policy: %s", tree)
+	}
+
+	if sub.Parent() != tree || sub.Index() != 3 {
+		t.Errorf("Built tree has wrong parent or index")
+	}
+
+	th, err := Threshold(2, Leaf(1), Leaf(2), Leaf(3))
+	if err != nil {
+		t.Errorf("Error (%v) during building threshold gate.", err)
+		return
+	}
+
+	if th.Threshold() != 2 {
+		t.Errorf("Built threshold gate has threshold %d", th.Threshold())
+	}
+}
+
+func TestBuilder_Error(t *testing.T) {
+	leaf := Leaf(1)
+	if _, err := And(leaf, leaf); err != ErrNodeReused {
+		t.Errorf("Reused leaf accepted: %v", err)
+	}
+
+	tree, _ := Or(leaf, Leaf(2))
+	if _, err := And(leaf, Leaf(3)); err != ErrNodeReused {
+		t.Errorf("Attached leaf accepted: %v", err)
+	}
+
+	// Close a loop by hand, which the builders never produce themselves
+	gate := tree.(*nonLeafNode)
+	gate.Children = append(gate.Children, gate)
+	gate.parent = gate
+	if _, err := And(Leaf(3), &nonLeafNode{or, 0, nil, []Node{gate}}); err != ErrCyclicTree {
+		t.Errorf("Cyclic tree accepted: %v", err)
+	}
+
+	if _, err := Or(); err != ErrEmptyGate {
+		t.Errorf("Empty gate accepted: %v", err)
+	}
+
+	if _, err := And(Leaf(1), nil); err != ErrNilNode {
+		t.Errorf("Nil child accepted: %v", err)
+	}
+
+	if _, err := Threshold(3, Leaf(1), Leaf(2)); err != ErrInvalidThreshold {
+		t.Errorf("Threshold out of range accepted: %v", err)
+	}
+
+	if _, err := Or(Leaf(1), Leaf(-1)); err != ErrAttrOutOfRange {
+		t.Errorf("Invalid attribute accepted: %v", err)
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"testing"
 )
 
+var (
+	pairing = defaultParams.pairing
+	zero    = defaultParams.zero
+)
+
 func TestConstants(t *testing.T) {
-	if _, _, _, err := loadParams(_paramString, _g1, _g2); err != nil {
+	if _, err := NewParams(_paramString, _g1, _g2); err != nil {
 		t.Error(err)
 	}
 }
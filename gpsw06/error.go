@@ -1,18 +1,60 @@
 package gpsw06
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	ErrAttrOutOfRange   = errors.New("attribute Index out of range")
-	ErrBadAttributeList = errors.New("incomplete attribute list (universe) or not sorted")
-	ErrBadNodeJSON      = errors.New("bad structured json for node")
-	ErrEncAttrNotExist  = errors.New("encrypted key not exist for such attribute")
-	ErrInvalidG1        = errors.New("could not find well-formed string describing g1")
-	ErrInvalidG2        = errors.New("could not find well-formed string describing g2")
-	ErrUnknownNodeType  = errors.New("unknown node type")
-	ErrTreeNotSatisfied = errors.New("ciphertext does not Satisfy decryption key policy")
+	ErrAttrOutOfRange        = errors.New("attribute Index out of range")
+	ErrBadAttributeList      = errors.New("incomplete attribute list (universe) or not sorted")
+	ErrBadBinary             = errors.New("malformed binary encoding")
+	ErrBadEnvelope           = errors.New("malformed envelope")
+	ErrBadPEM                = errors.New("malformed PEM block")
+	ErrBadNodeJSON           = errors.New("bad structured json for node")
+	ErrBinaryType            = errors.New("binary encoding is of another type")
+	ErrBinaryVersion         = errors.New("unsupported version of binary encoding")
+	ErrCyclicTree            = errors.New("access tree contains a cycle")
+	ErrDelegationUnsupported = errors.New("only keys of large universe can be delegated")
+	ErrElementLength         = errors.New("encoding of element has the wrong length")
+	ErrEmptyGate             = errors.New("gate has no children")
+	ErrEmptyLabel            = errors.New("label of large universe attribute is empty")
+	ErrEncAttrNotExist       = errors.New("encrypted key not exist for such attribute")
+	ErrEnvelopeAuth          = errors.New("envelope payload failed authentication")
+	ErrIdentityElement       = errors.New("element is the identity")
+	ErrInvalidG1             = errors.New("could not find well-formed string describing a generator g1")
+	ErrInvalidG2             = errors.New("could not find well-formed string describing a generator g2")
+	ErrInvalidThreshold      = errors.New("threshold of gate must be between 1 and number of children")
+	ErrMissingFingerprint    = errors.New("encoding carries no fingerprint of its pairing parameters")
+	ErrNilNode               = errors.New("node is nil")
+	ErrNilRandom             = errors.New("source of randomness is nil")
+	ErrNilParams             = errors.New("params is nil")
+	ErrNodeReused            = errors.New("node is already part of an access tree")
+	ErrNonCanonical          = errors.New("encoding of element is not canonical")
+	ErrNotInSubgroup         = errors.New("element is not in the subgroup of order r")
+	ErrNotNarrower           = errors.New("access tree is not more restrictive than that of the key")
+	ErrParamsMismatch        = errors.New("element does not belong to the pairing parameters of the scheme")
+	ErrParamsVersion         = errors.New("unsupported version of parameter file")
+	ErrRepeatedAttribute     = errors.New("attribute appears in more than one leaf")
+	ErrUnknownNodeType       = errors.New("unknown node type")
+	ErrTreeNotSatisfied      = errors.New("ciphertext does not Satisfy decryption key policy")
+	ErrUniverseMismatch      = errors.New("key and ciphertext belong to different attribute universes")
+	ErrUnknownAttribute      = errors.New("attribute label not in universe")
 
+	ErrExpectingCiphertext = errors.New("data provided is not a ciphertext")
+	ErrExpectingEnvelope   = errors.New("data provided is not an envelope")
 	ErrExpectingMasterKey  = errors.New("key provided is not a master key")
 	ErrExpectingPrivateKey = errors.New("key provided is not a private key")
 	ErrExpectingPublicKey  = errors.New("key provided is not a public key")
 )
+
+// PolicySyntaxError reports a malformed policy string and the byte offset at
+// which the problem was found.
+type PolicySyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *PolicySyntaxError) Error() string {
+	return fmt.Sprintf("policy syntax error at offset %d: %s", e.Offset, e.Msg)
+}
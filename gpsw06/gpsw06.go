@@ -1,11 +1,16 @@
 package gpsw06
 
 import (
+	cryptorand "crypto/rand"
+
 	"github.com/Nik-U/pbc"
 )
 
-// NewGPSW06 instantiates a GPSW06 from a set of attributes
-func NewGPSW06(attrs []Attribute) (*GPSW06, error) {
+// Option configures a GPSW06 instantiated by NewGPSW06 or NewLargeUniverseGPSW06.
+type Option func(*GPSW06) error
+
+// NewGPSW06 instantiates a GPSW06 from a set of attributes, configured by opts
+func NewGPSW06(attrs []Attribute, opts ...Option) (*GPSW06, error) {
 	// Check ordering of attributes
 	for i := range attrs {
 		if uint(i) != attrs[i].id {
@@ -13,15 +18,46 @@ func NewGPSW06(attrs []Attribute) (*GPSW06, error) {
 		}
 	}
 
+	return newGPSW06(&GPSW06{
+		universe: attrs,
+		random:   cryptorand.Reader,
+	}, opts)
+}
+
+// NewLargeUniverseGPSW06 instantiates a GPSW06 whose attributes are arbitrary
+// labels hashed into the group, so that the size of public key does not depend
+// on the number of attributes. Access trees and ciphertexts hold the labels, so
+// such instances encrypt with EncryptLabels.
+func NewLargeUniverseGPSW06(opts ...Option) (*GPSW06, error) {
+	return newGPSW06(&GPSW06{
+		large:  true,
+		random: cryptorand.Reader,
+	}, opts)
+}
+
+func newGPSW06(algo *GPSW06, opts []Option) (*GPSW06, error) {
 	pbc.SetCryptoRandom()
 
-	return &GPSW06{
-		attrs,
-	}, nil
+	for _, opt := range opts {
+		if err := opt(algo); err != nil {
+			return nil, err
+		}
+	}
+
+	if algo.params == nil {
+		if defaultParamsErr != nil {
+			return nil, defaultParamsErr
+		}
+		algo.params = defaultParams
+	}
+
+	return algo, nil
 }
 
 // Setup outputs a public key and a master key.
-func (algo *GPSW06) Setup() (*PublicKey, *MasterKey) {
+func (algo *GPSW06) Setup() (*PublicKey, *MasterKey, error) {
+	pairing, g2Power := algo.params.pairing, algo.params.g2Power
+
 	var (
 		t []*Zr // components of master key
 		y *Zr   // component of master key
@@ -29,71 +65,174 @@ func (algo *GPSW06) Setup() (*PublicKey, *MasterKey) {
 		Y *GT   // component of public key, e(g1, g2)^y
 	)
 
-	// For each attribute
+	// For each attribute, of which there is none in large universe
 	for range algo.universe {
 		// choose a random number r from Zr as secret key for current attribute
-		r := pairing.NewZr().Rand()
+		r, err := algo.randZr()
+		if err != nil {
+			return nil, nil, err
+		}
 
 		// Calculate g2^r as public key relative to secret key
 		// and store both r and g2^r
-		T = append(T, pairing.NewG2().PowZn(g2, r))
+		T = append(T, pairing.NewG2().PowerZn(g2Power, r))
 		t = append(t, r)
 	}
 
 	// Choose a random number y from Zr as secret key
-	y = pairing.NewZr().Rand()
+	y, err := algo.randZr()
+	if err != nil {
+		return nil, nil, err
+	}
 	// Calculate e(g1, g2)^y as public key relative to secret key
-	Y = pairing.NewGT().PowZn(e, y)
+	Y = pairing.NewGT().PowerZn(algo.params.ePower, y)
 
 	return &PublicKey{
 			T,
 			Y,
+			algo.params,
+			nil,
 		}, &MasterKey{
 			t,
 			y,
-		}
+			algo.params,
+			nil,
+		}, nil
+}
+
+// publicPower returns the fixed-base table of Y of key, which is prepared once
+// for as long as Encrypt keeps being called with the same key.
+func (algo *GPSW06) publicPower(key *PublicKey) *pbc.Power {
+	algo.mu.Lock()
+	defer algo.mu.Unlock()
+
+	if algo.yPower == nil || !algo.yPower.Source().Equals(key.y) {
+		algo.yPower = key.y.PreparePower()
+	}
+	return algo.yPower
 }
 
 // Encrypt takes as input a message msg, a set of attributes attrs and the public key
-// and output the ciphertext.
+// and output the ciphertext. Large universe instances take labels instead, and
+// reject attrs with ErrUniverseMismatch.
 func (algo *GPSW06) Encrypt(msg *Message, attrs map[int]struct{}, key *PublicKey) (*Ciphertext, error) {
+	if algo.large {
+		return nil, ErrUniverseMismatch
+	}
+
+	keys := make(map[attrKey]struct{})
+	for attr := range attrs {
+		if attr < 0 {
+			return nil, ErrAttrOutOfRange
+		}
+		keys[attrKey{attr, ""}] = struct{}{}
+	}
+
+	return algo.encrypt(msg, keys, key)
+}
+
+// EncryptLabels is like Encrypt, but takes the attributes as labels, which are
+// resolved by the universe of algo, or kept by the ciphertext in large universe.
+func (algo *GPSW06) EncryptLabels(msg *Message, labels map[string]struct{}, key *PublicKey) (*Ciphertext, error) {
+	if !algo.large {
+		attrs := make(map[int]struct{})
+		for label := range labels {
+			attr, err := algo.attributeID(label)
+			if err != nil {
+				return nil, err
+			}
+			attrs[attr] = struct{}{}
+		}
+
+		return algo.Encrypt(msg, attrs, key)
+	}
+
+	keys := make(map[attrKey]struct{})
+	for label := range labels {
+		if label == "" {
+			return nil, ErrEmptyLabel
+		}
+		keys[attrKey{0, label}] = struct{}{}
+	}
+
+	return algo.encrypt(msg, keys, key)
+}
+
+func (algo *GPSW06) encrypt(msg *Message, attrs map[attrKey]struct{}, key *PublicKey) (*Ciphertext, error) {
+	pairing, g2Power := algo.params.pairing, algo.params.g2Power
+
+	if err := key.bind(algo.params); err != nil {
+		return nil, err
+	}
+	if err := msg.bind(algo.params); err != nil {
+		return nil, err
+	}
+
 	var (
 		s      *Zr // Random number
 		encMsg *GT // Encrypted Message
+		e2     *G2 // g2^s, only in large universe
 	)
 
 	// Encrypted attribute keys
-	encAttrs := make(map[int]*G2)
+	encAttrs := make(map[attrKey]*G2)
 
 	attrLength := len(key.t)
 
 	// Choose a random s
-	s = pairing.NewZr().Rand()
+	s, err := algo.randZr()
+	if err != nil {
+		return nil, err
+	}
 	// Compute Y^s
-	Ys := pairing.NewGT().PowZn(key.y, s)
+	Ys := pairing.NewGT().PowerZn(algo.publicPower(key), s)
 	// Compute encrypted message, E' = M*Y^s
 	encMsg = pairing.NewGT().Mul(msg.m, Ys)
+
+	if algo.large {
+		// Compute E'' = g2^s
+		e2 = pairing.NewG2().PowerZn(g2Power, s)
+		// Compute encrypted attribute key, E_i = H(i) ^ s, which lies in G1
+		for attr := range attrs {
+			encAttrs[attr] = algo.params.hashAttribute(attr.label).ThenPowZn(s)
+		}
+
+		return &Ciphertext{attrs, encMsg, encAttrs, e2, algo.params, nil}, nil
+	}
+
 	// Compute encrypted attribute key, E_i = T_i ^ s
 	for attr := range attrs {
-		if attrLength <= attr {
+		if attrLength <= attr.id {
 			return nil, ErrAttrOutOfRange
 		}
-		encAttrs[attr] = pairing.NewG2().PowZn(key.t[attr], s)
+		encAttrs[attr] = pairing.NewG2().PowZn(key.t[attr.id], s)
 	}
 
-	return &Ciphertext{attrs, encMsg, encAttrs}, nil
+	return &Ciphertext{attrs, encMsg, encAttrs, e2, algo.params, nil}, nil
 }
 
 // KeyGen takes as input an access structure tree and the master key, and generate
 // the corresponding decryption key
 func (algo *GPSW06) KeyGen(tree Node, msk *MasterKey) (*DecryptKey, error) {
+	pairing, g1Power, g2Power := algo.params.pairing, algo.params.g1Power, algo.params.g2Power
+
+	if err := msk.bind(algo.params); err != nil {
+		return nil, err
+	}
+
 	// polynomials holds a mapping of Node to slice of coefficients for
 	// the polynomial of corresponding node.
 	// Length of each slice equals to Threshold of node
 	polynomials := make(map[Node]*polynomial)
 
 	// leaves stores the computed decryption keys of each of the leaf attributes
-	leaves := make(map[int]*G1)
+	leaves := make(map[attrKey]*G1)
+
+	// randoms stores g2^r_x of each of the leaf attributes in large universe
+	var randoms map[attrKey]*G2
+	if algo.large {
+		randoms = make(map[attrKey]*G2)
+	}
 
 	// queue holds the children nodes which will be processed later.
 	queue := []Node{tree}
@@ -120,15 +259,41 @@ func (algo *GPSW06) KeyGen(tree Node, msk *MasterKey) (*DecryptKey, error) {
 
 		// Randomly choose the rest of the coefficients to completely define q_x
 		for i := 1; i < len(polynomials[current].c); i++ {
-			polynomials[current].c[i] = pairing.NewZr().Rand()
+			c, err := algo.randZr()
+			if err != nil {
+				return nil, err
+			}
+			polynomials[current].c[i] = c
 		}
 
 		switch node := current.(type) {
 		case *leafNode:
+			// Leaves hold labels exactly in large universe
+			if algo.large != (node.Label != "") {
+				return nil, ErrUniverseMismatch
+			}
+
+			if algo.large {
+				// Randomly choose r_x
+				r, err := algo.randZr()
+				if err != nil {
+					return nil, err
+				}
+				// Compute D_x = g1^q_x(0) * H(i)^r_x
+				leaves[node.key()] = pairing.NewG1().PowerZn(g1Power, polynomials[current].c[0]).
+					ThenMul(algo.params.hashAttribute(node.Label).ThenPowZn(r))
+				// Compute R_x = g2^r_x
+				randoms[node.key()] = pairing.NewG2().PowerZn(g2Power, r)
+				break
+			}
+
+			if node.Attr < 0 || node.Attr >= len(msk.t) {
+				return nil, ErrAttrOutOfRange
+			}
 			// Compute q_x(0) / t_i
-			qx := polynomials[current].evaluate(zero).ThenDiv(msk.t[node.Attr])
+			qx := polynomials[current].evaluate(algo.params.zero).ThenDiv(msk.t[node.Attr])
 			// Compute g^(q_x(0) / t_i)
-			leaves[node.Attr] = pairing.NewG1().PowZn(g1, qx)
+			leaves[node.key()] = pairing.NewG1().PowerZn(g1Power, qx)
 		case *nonLeafNode:
 			// Enqueue the current node's children
 			queue = append(queue, node.Children...)
@@ -142,73 +307,274 @@ func (algo *GPSW06) KeyGen(tree Node, msk *MasterKey) (*DecryptKey, error) {
 		return nil, err
 	}
 
-	return &DecryptKey{leaves, n}, nil
+	return &DecryptKey{leaves, n, randoms, algo.params, nil}, nil
 }
 
-// Decrypt takes ciphertext c and decryption key dk as input and returns the
-// decrypted message if attributes in c Satisfy policy in dk.
-func (algo *GPSW06) Decrypt(ct *Ciphertext, key *DecryptKey) (*Message, error) {
-	tree, err := NodeFromJSON(key.tree)
+// Delegate takes in a decryption key and an access structure tree more restrictive than
+// the one of the key, and generate the corresponding re-randomized decryption key.
+// The narrower tree may raise thresholds of gates, append children to gates as long
+// as their thresholds grow by at least the number of appended children, and put
+// subtrees under new AND gates. Only keys of large universe can be delegated.
+func (algo *GPSW06) Delegate(dk *DecryptKey, narrower Node) (*DecryptKey, error) {
+	if err := dk.bind(algo.params); err != nil {
+		return nil, err
+	}
+
+	if dk.r == nil {
+		return nil, ErrDelegationUnsupported
+	}
+
+	tree, err := NodeFromJSON(dk.tree)
 	if err != nil {
 		return nil, err
 	}
 
-	if !tree.Satisfy(ct.attrs) {
-		return nil, ErrTreeNotSatisfied
+	if narrower == nil {
+		return nil, ErrNilNode
+	}
+	if err := validateNode(narrower, make(map[Node]struct{}), make(map[Node]struct{})); err != nil {
+		return nil, err
 	}
 
-	Ys, err := algo.decryptNode(ct, key, tree)
+	// Keys are stored by attribute, so each attribute may only appear once
+	attrs := make(map[attrKey]struct{})
+	queue := []Node{narrower}
+	for len(queue) > 0 {
+		var current Node
+		current, queue = queue[0], queue[1:]
+		switch node := current.(type) {
+		case *leafNode:
+			if node.Label == "" {
+				return nil, ErrUniverseMismatch
+			} else if _, ok := attrs[node.key()]; ok {
+				return nil, ErrRepeatedAttribute
+			}
+			attrs[node.key()] = struct{}{}
+		case *nonLeafNode:
+			queue = append(queue, node.Children...)
+		}
+	}
+
+	if !narrows(tree, narrower) {
+		return nil, ErrNotNarrower
+	}
+
+	leaves := make(map[attrKey]*G1)
+	randoms := make(map[attrKey]*G2)
+	if err := algo.delegateNode(dk, tree, narrower, algo.params.pairing.NewZr().Set1(), algo.params.zero, leaves, randoms); err != nil {
+		return nil, err
+	}
+
+	n, err := narrower.MarshalJSON()
 	if err != nil {
 		return nil, err
 	}
-	return &Message{pairing.NewGT().Div(ct.encMsg, Ys)}, nil
+
+	return &DecryptKey{leaves, n, randoms, algo.params, nil}, nil
 }
 
-func (algo *GPSW06) decryptNode(ct *Ciphertext, key *DecryptKey, x Node) (*GT, error) {
-	switch node := x.(type) {
+// delegateNode derives the keys of the leaves under node y of the narrower tree,
+// such that the value shared to y is a * q_x(0) + b, where q_x(0) is the value
+// shared to node x of the tree of dk. x is nil for new subtrees, whose value is b.
+func (algo *GPSW06) delegateNode(dk *DecryptKey, x, y Node, a, b *Zr, leaves map[attrKey]*G1, randoms map[attrKey]*G2) error {
+	pairing, g1Power, g2Power := algo.params.pairing, algo.params.g1Power, algo.params.g2Power
+
+	switch node := y.(type) {
 	case *leafNode:
-		if _, ok := ct.attrs[node.Attr]; ok {
-			return pairing.NewGT().Pair(key.d[node.Attr], ct.encAttrs[node.Attr]), nil
+		// Randomly choose r_y
+		r, err := algo.randZr()
+		if err != nil {
+			return err
+		}
+		// Compute D_y = g1^b * H(i)^r_y and R_y = g2^r_y
+		d := pairing.NewG1().PowerZn(g1Power, b).ThenMul(algo.params.hashAttribute(node.Label).ThenPowZn(r))
+		rY := pairing.NewG2().PowerZn(g2Power, r)
+
+		if x != nil {
+			old := x.(*leafNode)
+			dX, ok1 := dk.d[old.key()]
+			rX, ok2 := dk.r[old.key()]
+			if !ok1 || !ok2 {
+				return ErrEncAttrNotExist
+			}
+			// Compute D_y = D_x^a * g1^b * H(i)^r_y and R_y = R_x^a * g2^r_y
+			d.Mul(d, pairing.NewG1().PowZn(dX, a))
+			rY.Mul(rY, pairing.NewG2().PowZn(rX, a))
 		}
-		return nil, ErrEncAttrNotExist
+
+		leaves[node.key()] = d
+		randoms[node.key()] = rY
 	case *nonLeafNode:
-		type element struct {
-			index *Zr
-			fx    *GT
+		var matched map[int]Node
+		if x != nil {
+			matched, _ = matching(x, node)
 		}
-		var sx []element
-		for _, child := range node.Children {
-			fz, _ := algo.decryptNode(ct, key, child)
-			if fz != nil {
-				sx = append(sx, element{
-					pairing.NewZr().SetInt32(int32(child.Index())),
-					fz,
-				})
+
+		// Randomly choose z of degree Threshold(y) - 1 with z(0) = b, so that
+		// q_y(X) = a * q_x(X) * prod_j (1 - X/j) + z(X) over the new children j
+		z := newPolynomial(node.Threshold())
+		z.c[0] = pairing.NewZr().Set(b)
+		for i := 1; i < len(z.c); i++ {
+			c, err := algo.randZr()
+			if err != nil {
+				return err
 			}
-		}
-		if len(sx) < node.Threshold() {
-			return nil, ErrTreeNotSatisfied
+			z.c[i] = c
 		}
 
-		sx = sx[:node.Threshold()]
+		for _, child := range node.Children {
+			index := pairing.NewZr().SetInt32(int32(child.Index()))
+			old, ok := matched[child.Index()]
+			if !ok {
+				if err := algo.delegateNode(dk, nil, child, nil, z.evaluate(index), leaves, randoms); err != nil {
+					return err
+				}
+				continue
+			}
 
-		fx := pairing.NewGT().Set1()
-		for i, fz := range sx {
-			// Compute lagrange coefficient
-			coefficient := pairing.NewZr().Set1()
-			for j := range sx {
-				if i != j {
-					// polynomial interpolation
-					numerator := pairing.NewZr().Sub(zero, sx[j].index)
-					denominator := pairing.NewZr().Sub(fz.index, sx[j].index)
-					coefficient.Mul(coefficient, numerator.Div(numerator, denominator))
+			// Compute a * prod_j (1 - index/j), which vanishes at every new child j
+			scale := pairing.NewZr().Set(a)
+			for _, other := range node.Children {
+				if _, ok := matched[other.Index()]; ok {
+					continue
 				}
+				j := pairing.NewZr().SetInt32(int32(other.Index()))
+				factor := pairing.NewZr().Set1().ThenSub(pairing.NewZr().Div(index, j))
+				scale.Mul(scale, factor)
+			}
+
+			if err := algo.delegateNode(dk, old, child, scale, z.evaluate(index), leaves, randoms); err != nil {
+				return err
 			}
-			temp := pairing.NewGT().PowZn(fz.fx, coefficient)
-			fx.Mul(fx, temp)
 		}
-		return fx, nil
 	default:
-		return nil, ErrUnknownNodeType
+		return ErrUnknownNodeType
 	}
+
+	return nil
+}
+
+// narrows reports whether tree y is at least as restrictive as tree x in the
+// sense accepted by Delegate.
+func narrows(x, y Node) bool {
+	switch node := y.(type) {
+	case *leafNode:
+		old, ok := x.(*leafNode)
+		return ok && old.key() == node.key()
+	case *nonLeafNode:
+		_, ok := matching(x, node)
+		return ok
+	default:
+		return false
+	}
+}
+
+// matching finds the node of the tree rooted at x from which each child of gate y
+// derives, keyed by the index of the child. Either the children of gate x are
+// the first children of y, or x is put under y which requires all its children.
+func matching(x Node, y *nonLeafNode) (map[int]Node, bool) {
+	if old, ok := x.(*nonLeafNode); ok && len(old.Children) <= len(y.Children) &&
+		y.Threshold() >= old.Threshold()+len(y.Children)-len(old.Children) {
+		matched := make(map[int]Node)
+		for i, child := range old.Children {
+			if !narrows(child, y.Children[i]) {
+				matched = nil
+				break
+			}
+			matched[y.Children[i].Index()] = child
+		}
+		if matched != nil {
+			return matched, true
+		}
+	}
+
+	if y.Threshold() == len(y.Children) {
+		for _, child := range y.Children {
+			if narrows(x, child) {
+				return map[int]Node{child.Index(): x}, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Decrypt takes ciphertext c and decryption key dk as input and returns the
+// decrypted message if attributes in c Satisfy policy in dk.
+func (algo *GPSW06) Decrypt(ct *Ciphertext, key *DecryptKey) (*Message, error) {
+	if err := ct.bind(algo.params); err != nil {
+		return nil, err
+	}
+	if err := key.bind(algo.params); err != nil {
+		return nil, err
+	}
+
+	tree, err := NodeFromJSON(key.tree)
+	if err != nil {
+		return nil, err
+	}
+
+	if (key.r != nil) != (ct.e2 != nil) {
+		return nil, ErrUniverseMismatch
+	}
+
+	Ys, err := algo.decryptNode(ct, key, tree)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{algo.params.pairing.NewGT().Div(ct.encMsg, Ys), algo.params}, nil
+}
+
+// decryptNode computes Y^s from the leaves of tree. Rather than interpolating in
+// GT at every gate, the Lagrange coefficients are flattened onto the leaves and
+// the result is evaluated as one product of pairings:
+//
+//	prod_x e(D_x^(c_x), E_i)
+//	e(prod_x D_x^(c_x), E'') * prod_x e(E_i^(-c_x), R_x)   for large universe
+func (algo *GPSW06) decryptNode(ct *Ciphertext, key *DecryptKey, tree Node) (*GT, error) {
+	pairing := algo.params.pairing
+
+	// Only the cheapest subtree satisfied by ct is evaluated
+	costs := make(map[Node]int)
+	if _, ok := plan(tree, ct.attrs, costs); !ok {
+		return nil, ErrTreeNotSatisfied
+	}
+
+	coefficients := make(map[*leafNode]*Zr)
+	if err := lagrange(tree, costs, pairing.NewZr().Set1(), coefficients); err != nil {
+		return nil, err
+	}
+
+	var x, y []*pbc.Element
+	d := pairing.NewG1().Set1()
+	for leaf, c := range coefficients {
+		dX, ok1 := key.d[leaf.key()]
+		eI, ok2 := ct.encAttrs[leaf.key()]
+		if !ok1 || !ok2 {
+			return nil, ErrEncAttrNotExist
+		}
+
+		if ct.e2 == nil {
+			// e(D_x, E_i)^c = e(D_x^c, E_i)
+			x = append(x, pairing.NewG1().PowZn(dX, c))
+			y = append(y, eI)
+			continue
+		}
+
+		rX, ok := key.r[leaf.key()]
+		if !ok {
+			return nil, ErrEncAttrNotExist
+		}
+		// e(D_x, E'')^c / e(E_i, R_x)^c, with all of e(D_x^c, E'') in one pairing
+		d.Mul(d, pairing.NewG1().PowZn(dX, c))
+		x = append(x, pairing.NewG1().PowZn(eI, pairing.NewZr().Neg(c)))
+		y = append(y, rX)
+	}
+
+	if ct.e2 != nil {
+		x = append(x, d)
+		y = append(y, ct.e2)
+	}
+
+	return pairing.NewGT().ProdPairSlice(x, y), nil
 }
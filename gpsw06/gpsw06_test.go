@@ -1,6 +1,9 @@
 package gpsw06
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 var (
 	labels = []string{
@@ -30,7 +33,7 @@ func TestNewGPSW06(t *testing.T) {
 
 func TestGPSW06_Encrypt(t *testing.T) {
 	algo, _ = NewGPSW06(NewAttributes(labels))
-	pk, msk = algo.Setup()
+	pk, msk, _ = algo.Setup()
 
 	attrsForCipher := make(map[int]struct{})
 	attrsForCipher[1] = struct{}{}
@@ -50,6 +53,7 @@ func TestGPSW06_Encrypt(t *testing.T) {
 func TestGPSW06_KeyGen(t *testing.T) {
 	tree := &leafNode{
 		1,
+		"",
 		nil,
 	}
 
@@ -59,7 +63,7 @@ func TestGPSW06_KeyGen(t *testing.T) {
 		t.Errorf("Error (%v) during decryption key generation.", err)
 	}
 	t.Logf("Decryption key: %v", dk)
-	t.Logf("%v", dk.d[1])
+	t.Logf("%v", dk.d[attrKey{1, ""}])
 	t.Logf("%v", dk.tree)
 }
 
@@ -76,3 +80,196 @@ func TestGPSW06_Decrypt(t *testing.T) {
 		t.Errorf("Message before encryption and after decryption differs.")
 	}
 }
+
+func TestGPSW06_Threshold(t *testing.T) {
+	algo, _ := NewGPSW06(NewAttributes(labels))
+	pk, msk, _ := algo.Setup()
+
+	tree, err := algo.ParsePolicy("2 of (a, b, c)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	dk, err := algo.KeyGen(tree, msk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption key generation.", err)
+		return
+	}
+
+	msg := NewMessage().Rand()
+	ct, _ := algo.Encrypt(msg, map[int]struct{}{0: {}, 2: {}}, pk)
+	plain, err := algo.Decrypt(ct, dk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+	} else if !plain.m.Equals(msg.m) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+
+	ct, _ = algo.Encrypt(msg, map[int]struct{}{1: {}, 3: {}}, pk)
+	if _, err := algo.Decrypt(ct, dk); err != ErrTreeNotSatisfied {
+		t.Errorf("Ciphertext with 1 of 3 attributes decrypted: %v", err)
+	}
+}
+
+func TestGPSW06_LargeUniverse(t *testing.T) {
+	algo, _ := NewLargeUniverseGPSW06()
+	pk, msk, _ := algo.Setup()
+
+	if len(pk.t) != 0 || len(msk.t) != 0 {
+		t.Errorf("Large universe keys depend on attributes: %d, %d", len(pk.t), len(msk.t))
+	}
+
+	tree, err := algo.ParsePolicy(`admin OR (finance AND "senior manager")`)
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	dk, err := algo.KeyGen(tree, msk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption key generation.", err)
+		return
+	}
+
+	msg := NewMessage().Rand()
+	ct, err := algo.EncryptLabels(msg, map[string]struct{}{"finance": {}, "senior manager": {}, "unused": {}}, pk)
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting. Msg: %v.", err, msg)
+		return
+	}
+
+	// Trees, keys and ciphertexts hold the labels themselves
+	if tree.String() != `admin OR (finance AND "senior manager")` {
+		t.Errorf("Policy of labels formatted as %s", tree)
+	}
+	if _, ok := dk.d[attrKey{0, "senior manager"}]; !ok {
+		t.Errorf("Key not held by label: %v", dk.d)
+	}
+	if _, ok := ct.attrs[attrKey{0, "senior manager"}]; !ok {
+		t.Errorf("Ciphertext not held by label: %v", ct.attrs)
+	}
+
+	if _, err := algo.Encrypt(msg, map[int]struct{}{0: {}}, pk); err != ErrUniverseMismatch {
+		t.Errorf("Attribute indices encrypted in large universe: %v", err)
+	}
+	if _, err := algo.KeyGen(Leaf(0), msk); err != ErrUniverseMismatch {
+		t.Errorf("Key generated for attribute index in large universe: %v", err)
+	}
+	if _, err := algo.EncryptLabels(msg, map[string]struct{}{"": {}}, pk); err != ErrEmptyLabel {
+		t.Errorf("Empty label encrypted: %v", err)
+	}
+
+	// Keys and ciphertexts must survive marshaling
+	data, _ := ct.Marshal()
+	ct = &Ciphertext{}
+	if _, err := ct.Unmarshal(data); err != nil {
+		t.Errorf("Error (%v) during unmarshaling ciphertext.", err)
+		return
+	}
+	data, _ = dk.Marshal()
+	dk = &DecryptKey{}
+	if _, err := dk.Unmarshal(data); err != nil {
+		t.Errorf("Error (%v) during unmarshaling decryption key.", err)
+		return
+	}
+
+	plain, err := algo.Decrypt(ct, dk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+	} else if !plain.m.Equals(msg.m) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+
+	ct, _ = algo.EncryptLabels(msg, map[string]struct{}{"finance": {}, "manager": {}}, pk)
+	if _, err := algo.Decrypt(ct, dk); err != ErrTreeNotSatisfied {
+		t.Errorf("Ciphertext without senior manager decrypted: %v", err)
+	}
+
+	small, _ := NewGPSW06(NewAttributes(labels))
+	spk, _, _ := small.Setup()
+	sct, _ := small.Encrypt(msg, map[int]struct{}{0: {}}, spk)
+	if _, err := algo.Decrypt(sct, dk); err != ErrUniverseMismatch {
+		t.Errorf("Ciphertext of small universe decrypted by large universe key: %v", err)
+	}
+
+	if _, err := small.EncryptLabels(msg, map[string]struct{}{"admin": {}}, spk); err != ErrUnknownAttribute {
+		t.Errorf("Label outside of universe accepted: %v", err)
+	}
+}
+
+func TestGPSW06_Delegate(t *testing.T) {
+	algo, _ := NewLargeUniverseGPSW06()
+	pk, msk, _ := algo.Setup()
+
+	tree, _ := algo.ParsePolicy("a OR (b AND c) OR 2 of (d, e, f)")
+	dk, err := algo.KeyGen(tree, msk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption key generation.", err)
+		return
+	}
+
+	msg := NewMessage().Rand()
+
+	for policy, cases := range map[string]map[string]bool{
+		// Re-randomization only
+		"a OR (b AND c) OR 2 of (d, e, f)": {"a": true, "b c": true, "d f": true, "b": false},
+		// Raised threshold of root
+		"2 of (a, (b AND c), 2 of (d, e, f))": {"a": false, "a b c": true, "b c e f": true},
+		// Appended child with threshold raised accordingly
+		"a OR (b AND c) OR 3 of (d, e, f, g)": {"d e": false, "d e g": true, "d e f": true, "d g": false, "a": true},
+		// Wrapped in a new AND gate
+		"h AND (a OR (b AND c) OR 2 of (d, e, f))": {"a": false, "a h": true, "d e h": true},
+	} {
+		narrower, err := algo.ParsePolicy(policy)
+		if err != nil {
+			t.Errorf("Error (%v) during parsing policy %q.", err, policy)
+			continue
+		}
+
+		dk2, err := algo.Delegate(dk, narrower)
+		if err != nil {
+			t.Errorf("Error (%v) during delegating to %q.", err, policy)
+			continue
+		}
+
+		for labels, expected := range cases {
+			attrs := make(map[string]struct{})
+			for _, label := range strings.Fields(labels) {
+				attrs[label] = struct{}{}
+			}
+			ct, _ := algo.EncryptLabels(msg, attrs, pk)
+
+			plain, err := algo.Decrypt(ct, dk2)
+			if !expected {
+				if err != ErrTreeNotSatisfied {
+					t.Errorf("Key delegated to %q decrypted ciphertext with %q: %v", policy, labels, err)
+				}
+			} else if err != nil {
+				t.Errorf("Error (%v) during decryption by key delegated to %q with %q.", err, policy, labels)
+			} else if !plain.m.Equals(msg.m) {
+				t.Errorf("Key delegated to %q decrypted ciphertext with %q wrongly.", policy, labels)
+			}
+		}
+	}
+
+	for policy, expected := range map[string]error{
+		"a OR (b AND c) OR d":                      ErrNotNarrower,
+		"a OR (b AND c) OR 2 of (d, e, f, g)":      ErrNotNarrower,
+		"h OR (a OR (b AND c) OR 2 of (d, e, f))":  ErrNotNarrower,
+		"a OR (b AND c) OR 2 of (d, e, f) OR g":    ErrNotNarrower,
+		"a AND (a OR (b AND c) OR 2 of (d, e, f))": ErrRepeatedAttribute,
+	} {
+		narrower, _ := algo.ParsePolicy(policy)
+		if _, err := algo.Delegate(dk, narrower); err != expected {
+			t.Errorf("Delegating to %q reported %v, expected %v", policy, err, expected)
+		}
+	}
+
+	small, _ := NewGPSW06(NewAttributes(labels))
+	_, smsk, _ := small.Setup()
+	sdk, _ := small.KeyGen(buildTree(), smsk)
+	if _, err := small.Delegate(sdk, buildTree()); err != ErrDelegationUnsupported {
+		t.Errorf("Key of small universe delegated: %v", err)
+	}
+}
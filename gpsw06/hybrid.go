@@ -0,0 +1,181 @@
+package gpsw06
+
+import (
+	"crypto/aes"
+	cryptocipher "crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+type Envelope struct {
+	// contains filtered or unexported fields
+	key   *Ciphertext
+	nonce []byte
+	data  []byte
+}
+
+type envelope struct {
+	Key   []byte `json:"key"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// DeriveKey derives a 256-bit symmetric key from msg.
+func (msg *Message) DeriveKey() []byte {
+	h := sha256.Sum256(msg.Marshal())
+	return h[:]
+}
+
+// attributeData encodes attrs in ascending order, so that the same attribute
+// set always yields the same associated data. Indices take eight bytes, and
+// labels of the large universe are prefixed by their length.
+func attributeData(attrs map[attrKey]struct{}) []byte {
+	keys := make([]attrKey, 0, len(attrs))
+	for attr := range attrs {
+		keys = append(keys, attr)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+
+	var data []byte
+	var b [binary.MaxVarintLen64]byte
+	for _, k := range keys {
+		if k.label != "" {
+			data = append(data, b[:binary.PutUvarint(b[:], uint64(len(k.label)))]...)
+			data = append(data, k.label...)
+			continue
+		}
+		binary.BigEndian.PutUint64(b[:8], uint64(k.id))
+		data = append(data, b[:8]...)
+	}
+
+	return data
+}
+
+func newAEAD(key []byte) (cryptocipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cryptocipher.NewGCM(block)
+}
+
+// EncryptBytes takes as input an arbitrary plaintext, a set of attributes attrs and
+// the public key, and output an envelope which only keys whose policy attrs Satisfy
+// can open.
+func (algo *GPSW06) EncryptBytes(plaintext []byte, attrs map[int]struct{}, key *PublicKey) (*Envelope, error) {
+	return algo.encryptBytes(plaintext, func(msg *Message) (*Ciphertext, error) {
+		return algo.Encrypt(msg, attrs, key)
+	})
+}
+
+// EncryptBytesLabels is like EncryptBytes, but takes the attributes as labels as
+// EncryptLabels does.
+func (algo *GPSW06) EncryptBytesLabels(plaintext []byte, labels map[string]struct{}, key *PublicKey) (*Envelope, error) {
+	return algo.encryptBytes(plaintext, func(msg *Message) (*Ciphertext, error) {
+		return algo.EncryptLabels(msg, labels, key)
+	})
+}
+
+// encryptBytes seals plaintext under a random message, which encrypt encapsulates.
+func (algo *GPSW06) encryptBytes(plaintext []byte, encrypt func(*Message) (*Ciphertext, error)) (*Envelope, error) {
+	// Encapsulate a random message, which the symmetric key is derived from
+	msg, err := algo.randMessage()
+	if err != nil {
+		return nil, err
+	}
+	ct, err := encrypt(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(msg.DeriveKey())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(algo.random, nonce); err != nil {
+		return nil, err
+	}
+
+	// Bind the attribute set to the payload
+	data := aead.Seal(nil, nonce, plaintext, attributeData(ct.attrs))
+
+	return &Envelope{ct, nonce, data}, nil
+}
+
+// DecryptBytes takes envelope env and decryption key dk as input and returns the
+// original plaintext if attributes in env Satisfy policy in dk.
+func (algo *GPSW06) DecryptBytes(env *Envelope, key *DecryptKey) ([]byte, error) {
+	if env.key == nil {
+		return nil, ErrBadEnvelope
+	}
+
+	msg, err := algo.Decrypt(env.key, key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(msg.DeriveKey())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(env.nonce) != aead.NonceSize() {
+		return nil, ErrBadEnvelope
+	}
+
+	plaintext, err := aead.Open(nil, env.nonce, env.data, attributeData(env.key.attrs))
+	if err != nil {
+		return nil, ErrEnvelopeAuth
+	}
+
+	return plaintext, nil
+}
+
+// Marshal converts env into a byte slice.
+func (env *Envelope) Marshal() ([]byte, error) {
+	k, err := env.key.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	str, err := json.Marshal(envelope{k, env.nonce, env.data})
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(base64.StdEncoding.EncodeToString(str)), nil
+}
+
+// Unmarshal set env to the result of converting the output of Marshal back into
+// an envelope structure and then return b.
+func (env *Envelope) Unmarshal(b []byte) ([]byte, error) {
+	str, err := base64.StdEncoding.DecodeString(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	var instance = envelope{}
+	if err := json.Unmarshal([]byte(str), &instance); err != nil {
+		return nil, err
+	} else if len(instance.Key) == 0 {
+		return nil, ErrBadEnvelope
+	}
+
+	ct := &Ciphertext{}
+	if _, err := ct.Unmarshal(instance.Key); err != nil {
+		return nil, err
+	}
+
+	env.key = ct
+	env.nonce = instance.Nonce
+	env.data = instance.Data
+
+	return b, nil
+}
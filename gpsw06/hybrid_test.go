@@ -0,0 +1,79 @@
+package gpsw06
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGPSW06_EncryptBytes(t *testing.T) {
+	algo, _ := NewGPSW06(NewAttributes(labels))
+	pk, msk, _ := algo.Setup()
+
+	attrs := make(map[int]struct{})
+	attrs[3] = struct{}{}
+	attrs[4] = struct{}{}
+
+	dk, err := algo.KeyGen(buildTree(), msk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption key generation.", err)
+		return
+	}
+
+	plaintext := []byte("attribute-based hybrid encryption")
+	env, err := algo.EncryptBytes(plaintext, attrs, pk)
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting.", err)
+		return
+	}
+
+	envStr, err := env.Marshal()
+	if err != nil {
+		t.Errorf("Error occurred during marshalling envelope: %v", err)
+		return
+	}
+
+	env2 := Envelope{}
+	if _, err := env2.Unmarshal(envStr); err != nil {
+		t.Errorf("Error occurred during unmarshalling envelope: %v", err)
+		return
+	}
+
+	plain, err := algo.DecryptBytes(&env2, dk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+		return
+	}
+
+	if !bytes.Equal(plain, plaintext) {
+		t.Errorf("Plaintext before encryption and after decryption differs.")
+	}
+}
+
+func TestGPSW06_DecryptBytes(t *testing.T) {
+	algo, _ := NewGPSW06(NewAttributes(labels))
+	pk, msk, _ := algo.Setup()
+
+	attrs := make(map[int]struct{})
+	attrs[3] = struct{}{}
+	attrs[4] = struct{}{}
+
+	dk, err := algo.KeyGen(buildTree(), msk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption key generation.", err)
+		return
+	}
+
+	env, err := algo.EncryptBytes([]byte("secret"), attrs, pk)
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting.", err)
+		return
+	}
+
+	// Label the envelope with an extra attribute after the fact
+	env.key.attrs[attrKey{6, ""}] = struct{}{}
+	env.key.encAttrs[attrKey{6, ""}] = pairing.NewG2().Rand()
+
+	if _, err := algo.DecryptBytes(env, dk); err != ErrEnvelopeAuth {
+		t.Errorf("Envelope with swapped attributes was not rejected: %v", err)
+	}
+}
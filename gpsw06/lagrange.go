@@ -0,0 +1,100 @@
+package gpsw06
+
+import "sort"
+
+// plan records in costs the number of leaves of the cheapest subtree of x that
+// attrs satisfy, for x and every node below it that attrs satisfy, and returns
+// the cost of x. ok is false if attrs do not satisfy x. The number of leaves is
+// proportional to the number of pairings needed for decryption.
+func plan(x Node, attrs map[attrKey]struct{}, costs map[Node]int) (cost int, ok bool) {
+	switch node := x.(type) {
+	case *leafNode:
+		if _, ok := attrs[node.key()]; !ok {
+			return 0, false
+		}
+		cost = 1
+	case *nonLeafNode:
+		for _, child := range node.Children {
+			plan(child, attrs, costs)
+		}
+
+		sx := cheapest(node, costs)
+		if len(sx) == 0 || len(sx) < node.Threshold() {
+			return 0, false
+		}
+		for _, child := range sx {
+			cost += costs[child]
+		}
+	default:
+		return 0, false
+	}
+
+	costs[x] = cost
+	return cost, true
+}
+
+// cheapest returns the Threshold satisfied children of node of the lowest cost
+// in costs, preferring earlier children among those of equal cost. Fewer are
+// returned if node is not satisfied.
+func cheapest(node *nonLeafNode, costs map[Node]int) []Node {
+	var sx []Node
+	for _, child := range node.Children {
+		if _, ok := costs[child]; ok {
+			sx = append(sx, child)
+		}
+	}
+
+	sort.SliceStable(sx, func(i, j int) bool {
+		return costs[sx[i]] < costs[sx[j]]
+	})
+
+	if len(sx) > node.Threshold() {
+		sx = sx[:node.Threshold()]
+	}
+	return sx
+}
+
+// lagrange sets coefficients[leaf] to the flattened Lagrange coefficient of every
+// leaf of the cheapest subtree of x planned in costs, multiplied by c. The
+// flattened coefficient of a leaf is the product of the Lagrange coefficients of
+// the nodes on its path, so that the secret of x is the sum of the leaf secrets
+// weighted by them.
+func lagrange(x Node, costs map[Node]int, c *Zr, coefficients map[*leafNode]*Zr) error {
+	if _, ok := costs[x]; !ok {
+		return ErrTreeNotSatisfied
+	}
+
+	switch node := x.(type) {
+	case *leafNode:
+		coefficients[node] = c
+		return nil
+	case *nonLeafNode:
+		sx := cheapest(node, costs)
+
+		indices := make([]*Zr, len(sx))
+		for i, child := range sx {
+			indices[i] = c.NewFieldElement().SetInt32(int32(child.Index()))
+		}
+
+		numerator := c.NewFieldElement()
+		denominator := c.NewFieldElement()
+		for i, child := range sx {
+			// Lagrange coefficient of child at 0, times c
+			coefficient := c.NewFieldElement().Set(c)
+			for j := range sx {
+				if i != j {
+					numerator.Neg(indices[j])
+					denominator.Sub(indices[i], indices[j])
+					coefficient.Mul(coefficient, numerator.Div(numerator, denominator))
+				}
+			}
+
+			if err := lagrange(child, costs, coefficient, coefficients); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return ErrUnknownNodeType
+	}
+}
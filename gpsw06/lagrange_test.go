@@ -0,0 +1,247 @@
+package gpsw06
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// wideTree returns a policy of algo over the labels of attributes 0 to n-1 in
+// gates of five. More than half of the gates and of the children of each gate
+// are required.
+func wideTree(algo *GPSW06, n int) (Node, error) {
+	var gates []string
+	for i := 0; i < n; i += 5 {
+		var leaves []string
+		for j := i; j < i+5 && j < n; j++ {
+			leaves = append(leaves, fmt.Sprint(j))
+		}
+		gates = append(gates, fmt.Sprintf("%d of (%s)", (len(leaves)+1)/2, strings.Join(leaves, ", ")))
+	}
+	return algo.ParsePolicy(fmt.Sprintf("%d of (%s)", len(gates)/2+1, strings.Join(gates, ", ")))
+}
+
+// wideInstance returns an instance with a universe of n attributes labeled by
+// their indices, or a large universe instance.
+func wideInstance(n int, large bool) *GPSW06 {
+	if large {
+		algo, _ := NewLargeUniverseGPSW06()
+		return algo
+	}
+
+	labels := make([]string, n)
+	for i := range labels {
+		labels[i] = fmt.Sprint(i)
+	}
+	algo, _ := NewGPSW06(NewAttributes(labels))
+	return algo
+}
+
+// decryptRecursive is Decrypt interpolating in GT at every gate, as done before
+// the Lagrange coefficients were flattened.
+func decryptRecursive(algo *GPSW06, ct *Ciphertext, key *DecryptKey) (*Message, error) {
+	pairing := algo.params.pairing
+
+	tree, err := NodeFromJSON(key.tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var decryptNode func(x Node) *GT
+	decryptNode = func(x Node) *GT {
+		switch node := x.(type) {
+		case *leafNode:
+			if _, ok := ct.attrs[node.key()]; !ok {
+				return nil
+			}
+			if ct.e2 != nil {
+				return pairing.NewGT().Pair(key.d[node.key()], ct.e2).
+					ThenDiv(pairing.NewGT().Pair(ct.encAttrs[node.key()], key.r[node.key()]))
+			}
+			return pairing.NewGT().Pair(key.d[node.key()], ct.encAttrs[node.key()])
+		case *nonLeafNode:
+			var indices []int
+			var fxs []*GT
+			for _, child := range node.Children {
+				if fz := decryptNode(child); fz != nil && len(fxs) < node.Threshold() {
+					indices = append(indices, child.Index())
+					fxs = append(fxs, fz)
+				}
+			}
+			if len(fxs) < node.Threshold() {
+				return nil
+			}
+
+			fx := pairing.NewGT().Set1()
+			for i := range fxs {
+				coefficient := pairing.NewZr().Set1()
+				for j := range fxs {
+					if i != j {
+						numerator := pairing.NewZr().SetInt32(int32(-indices[j]))
+						denominator := pairing.NewZr().SetInt32(int32(indices[i] - indices[j]))
+						coefficient.Mul(coefficient, numerator.Div(numerator, denominator))
+					}
+				}
+				fx.Mul(fx, pairing.NewGT().PowZn(fxs[i], coefficient))
+			}
+			return fx
+		}
+		return nil
+	}
+
+	ys := decryptNode(tree)
+	if ys == nil {
+		return nil, ErrTreeNotSatisfied
+	}
+	return &Message{pairing.NewGT().Div(ct.encMsg, ys), algo.params}, nil
+}
+
+func TestLagrange(t *testing.T) {
+	var tests = []struct {
+		attrs    []int
+		expected bool
+	}{
+		{[]int{0, 1, 2, 5, 6, 7, 10, 11, 12}, true},
+		{[]int{1, 2, 4, 6, 8, 9, 15, 17, 19}, true},
+		{[]int{0, 1, 5, 6, 10, 11, 15}, false},
+	}
+
+	for _, large := range []bool{false, true} {
+		algo := wideInstance(20, large)
+		pk, msk, _ := algo.Setup()
+		tree, err := wideTree(algo, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dk, err := algo.KeyGen(tree, msk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, test := range tests {
+			labels := make(map[string]struct{})
+			for _, attr := range test.attrs {
+				labels[fmt.Sprint(attr)] = struct{}{}
+			}
+
+			msg := algo.NewMessage().Rand()
+			ct, err := algo.EncryptLabels(msg, labels, pk)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plain, err := algo.Decrypt(ct, dk)
+			if !test.expected {
+				if err != ErrTreeNotSatisfied {
+					t.Errorf("%v (large: %v): unsatisfying ciphertext accepted: %v", test.attrs, large, err)
+				}
+				continue
+			}
+
+			if err != nil {
+				t.Errorf("%v (large: %v): error (%v) during decryption.", test.attrs, large, err)
+			} else if !plain.m.Equals(msg.m) {
+				t.Errorf("%v (large: %v): message before encryption and after decryption differs.", test.attrs, large)
+			}
+
+			if reference, err := decryptRecursive(algo, ct, dk); err != nil || !reference.m.Equals(msg.m) {
+				t.Errorf("%v (large: %v): flattened and recursive decryption differ: %v", test.attrs, large, err)
+			}
+		}
+	}
+}
+
+func BenchmarkGPSW06_Decrypt(b *testing.B) {
+	for _, large := range []bool{false, true} {
+		for _, n := range []int{10, 50, 200} {
+			algo := wideInstance(n, large)
+			pk, msk, _ := algo.Setup()
+
+			tree, err := wideTree(algo, n)
+			if err != nil {
+				b.Fatal(err)
+			}
+			dk, err := algo.KeyGen(tree, msk)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			labels := make(map[string]struct{})
+			for i := 0; i < n; i++ {
+				labels[fmt.Sprint(i)] = struct{}{}
+			}
+			ct, err := algo.EncryptLabels(algo.NewMessage().Rand(), labels, pk)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.Run(fmt.Sprintf("large=%v/leaves=%d/flattened", large, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := algo.Decrypt(ct, dk); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+
+			b.Run(fmt.Sprintf("large=%v/leaves=%d/recursive", large, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := decryptRecursive(algo, ct, dk); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	var tests = []struct {
+		policy string
+		attrs  []int
+		leaves []int // leaves of the cheapest satisfied subtree
+	}{
+		{"(1 AND 2) OR 0", []int{0, 1, 2}, []int{0}},
+		{"0 OR (1 AND 2)", []int{1, 2}, []int{1, 2}},
+		{"2 of ((0 AND 1 AND 2), 3, (4 OR 5))", []int{0, 1, 2, 4, 5}, []int{0, 1, 2, 4}},
+		{"2 of ((0 AND 1 AND 2), 3, (4 OR 5))", []int{0, 1, 2, 3, 5}, []int{3, 5}},
+		{"(0 AND 1) OR (2 AND 3)", []int{0, 2}, nil},
+	}
+
+	for _, test := range tests {
+		tree, err := ParsePolicy(test.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		attrs := make(map[attrKey]struct{})
+		for _, attr := range test.attrs {
+			attrs[attrKey{attr, ""}] = struct{}{}
+		}
+
+		costs := make(map[Node]int)
+		cost, ok := plan(tree, attrs, costs)
+		if ok != (test.leaves != nil) {
+			t.Errorf("%s with %v: satisfied %v, expected %v", test.policy, test.attrs, ok, test.leaves != nil)
+			continue
+		} else if !ok {
+			continue
+		}
+
+		coefficients := make(map[*leafNode]*Zr)
+		if err := lagrange(tree, costs, pairing.NewZr().Set1(), coefficients); err != nil {
+			t.Errorf("%s with %v: error (%v) during computing coefficients", test.policy, test.attrs, err)
+			continue
+		}
+
+		var leaves []int
+		for leaf := range coefficients {
+			leaves = append(leaves, leaf.Attr)
+		}
+		sort.Ints(leaves)
+
+		if cost != len(test.leaves) || fmt.Sprint(leaves) != fmt.Sprint(test.leaves) {
+			t.Errorf("%s with %v: evaluated leaves %v at cost %d, expected %v", test.policy, test.attrs, leaves, cost, test.leaves)
+		}
+	}
+}
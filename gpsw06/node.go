@@ -2,6 +2,9 @@ package gpsw06
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 )
 
 type operator uint
@@ -9,6 +12,7 @@ type operator uint
 const (
 	or operator = iota
 	and
+	threshold
 )
 
 type Node interface {
@@ -17,17 +21,23 @@ type Node interface {
 	Satisfy(map[int]struct{}) bool
 	Threshold() int
 	Equal(Node) bool
+	String() string
 	MarshalJSON() ([]byte, error)
 	UnmarshalJSON([]byte) error
+	MarshalBinary() ([]byte, error)
 }
 
+// leafNode holds the index Attr of an attribute, or the Label of an attribute of
+// the large universe, whose index is unused.
 type leafNode struct {
 	Attr   int
+	Label  string
 	parent Node
 }
 
 type nonLeafNode struct {
 	Gate     operator
+	K        int // number of children required by a threshold gate
 	parent   Node
 	Children []Node
 }
@@ -36,13 +46,19 @@ type trimLeaf struct {
 	Attr int `json:"attr"`
 }
 
+type trimLabel struct {
+	Label string `json:"label"`
+}
+
 type trimNonLeaf struct {
 	Gate     operator `json:"gate"`
+	K        int      `json:"k,omitempty"`
 	Children []Node   `json:"children"`
 }
 
 type rawNonLeaf struct {
 	Gate     operator          `json:"gate"`
+	K        int               `json:"k,omitempty"`
 	Children []json.RawMessage `json:"children"`
 }
 
@@ -62,9 +78,16 @@ func (l *leafNode) Parent() Node {
 	return l.parent
 }
 
+// Satisfy reports whether attrs holds the index of l. Leaves of labels are
+// satisfied by no indices.
 func (l *leafNode) Satisfy(attrs map[int]struct{}) bool {
 	_, ok := attrs[l.Attr]
-	return ok
+	return ok && l.Label == ""
+}
+
+// key returns the key of the attribute of l in keys and ciphertexts.
+func (l *leafNode) key() attrKey {
+	return attrKey{l.Attr, l.Label}
 }
 
 func (l *leafNode) Threshold() int {
@@ -74,7 +97,7 @@ func (l *leafNode) Threshold() int {
 func (l *leafNode) Equal(node Node) bool {
 	switch n2 := node.(type) {
 	case *leafNode:
-		return (l.Attr == n2.Attr) && ((l.parent != nil && n2.parent != nil) || (l.parent == n2.parent))
+		return (l.Attr == n2.Attr) && (l.Label == n2.Label) && ((l.parent != nil && n2.parent != nil) || (l.parent == n2.parent))
 	case *nonLeafNode:
 		return false
 	default:
@@ -82,20 +105,38 @@ func (l *leafNode) Equal(node Node) bool {
 	}
 }
 
+// String formats l in the policy language accepted by ParsePolicy.
+func (l *leafNode) String() string {
+	if l.Label != "" {
+		return formatLabel(l.Label)
+	}
+	return strconv.Itoa(l.Attr)
+}
+
 func (l *leafNode) MarshalJSON() ([]byte, error) {
+	if l.Label != "" {
+		return json.Marshal(trimLabel{l.Label})
+	}
 	return json.Marshal(trimLeaf{l.Attr})
 }
 
+// UnmarshalJSON reads a leaf holding either an index or a label.
 func (l *leafNode) UnmarshalJSON(data []byte) error {
 	var tl = trimLeaf{-1}
+	var label trimLabel
 	if err := json.Unmarshal(data, &tl); err != nil {
 		return err
+	} else if err := json.Unmarshal(data, &label); err != nil {
+		return err
 	}
-	if tl.Attr < 0 {
+	if (tl.Attr < 0) == (label.Label == "") {
 		return ErrBadNodeJSON
 	}
 
-	l.Attr = tl.Attr
+	l.Attr, l.Label = 0, label.Label
+	if label.Label == "" {
+		l.Attr = tl.Attr
+	}
 	return nil
 }
 
@@ -116,23 +157,13 @@ func (n *nonLeafNode) Parent() Node {
 }
 
 func (n *nonLeafNode) Satisfy(attrs map[int]struct{}) bool {
-	result := false
-
-	switch n.Gate {
-	case or:
-		for i := 0; i < len(n.Children); i++ {
-			if n.Children[i].Satisfy(attrs) {
-				return true
-			}
-		}
-		return false
-	case and:
-		for i := 0; i < len(n.Children) && n.Children[i].Satisfy(attrs); i, result = i+1, true {
+	satisfied := 0
+	for i := 0; i < len(n.Children) && satisfied < n.Threshold(); i++ {
+		if n.Children[i].Satisfy(attrs) {
+			satisfied++
 		}
-		return result
-	default:
-		return false
 	}
+	return len(n.Children) > 0 && satisfied >= n.Threshold()
 }
 
 func (n *nonLeafNode) Threshold() int {
@@ -141,17 +172,39 @@ func (n *nonLeafNode) Threshold() int {
 		return 1
 	case and:
 		return len(n.Children)
+	case threshold:
+		return n.K
 	default:
 		return 1
 	}
 }
 
+// String formats n in the policy language accepted by ParsePolicy.
+func (n *nonLeafNode) String() string {
+	children := make([]string, len(n.Children))
+	for i, child := range n.Children {
+		children[i] = child.String()
+		if _, ok := child.(*nonLeafNode); ok {
+			children[i] = "(" + children[i] + ")"
+		}
+	}
+
+	switch {
+	case len(children) > 1 && n.Gate == or:
+		return strings.Join(children, " OR ")
+	case len(children) > 1 && n.Gate == and:
+		return strings.Join(children, " AND ")
+	default:
+		return fmt.Sprintf("%d of (%s)", n.Threshold(), strings.Join(children, ", "))
+	}
+}
+
 func (n *nonLeafNode) MarshalJSON() ([]byte, error) {
-	return json.Marshal(trimNonLeaf{n.Gate, n.Children})
+	return json.Marshal(trimNonLeaf{n.Gate, n.K, n.Children})
 }
 
 func (n *nonLeafNode) UnmarshalJSON(data []byte) error {
-	var tnl = rawNonLeaf{or, make([]json.RawMessage, 0)}
+	var tnl = rawNonLeaf{or, 0, make([]json.RawMessage, 0)}
 	if err := json.Unmarshal(data, &tnl); err != nil {
 		return err
 	}
@@ -160,6 +213,17 @@ func (n *nonLeafNode) UnmarshalJSON(data []byte) error {
 		return ErrBadNodeJSON
 	}
 
+	switch tnl.Gate {
+	case or, and:
+		tnl.K = 0
+	case threshold:
+		if tnl.K < 1 || tnl.K > len(tnl.Children) {
+			return ErrInvalidThreshold
+		}
+	default:
+		return ErrBadNodeJSON
+	}
+
 	children := make([]Node, 0)
 
 	for i := range tnl.Children {
@@ -177,6 +241,7 @@ func (n *nonLeafNode) UnmarshalJSON(data []byte) error {
 	}
 
 	n.Gate = tnl.Gate
+	n.K = tnl.K
 	n.Children = children
 
 	return nil
@@ -187,7 +252,7 @@ func (n *nonLeafNode) Equal(node Node) bool {
 	case *leafNode:
 		return false
 	case *nonLeafNode:
-		if n.Gate != n2.Gate || len(n.Children) != len(n2.Children) {
+		if n.Gate != n2.Gate || n.K != n2.K || len(n.Children) != len(n2.Children) {
 			return false
 		}
 		for i := range n.Children {
@@ -202,17 +267,21 @@ func (n *nonLeafNode) Equal(node Node) bool {
 }
 
 func NodeFromJSON(data []byte) (Node, error) {
+	if len(data) < 3 {
+		return nil, ErrBadNodeJSON
+	}
+
 	switch data[2] {
-	case 'a':
-		// Try leaf node
-		l := &leafNode{0, nil}
+	case 'a', 'l':
+		// Try leaf node of an index or a label
+		l := &leafNode{0, "", nil}
 		if err := l.UnmarshalJSON(data); err != nil {
 			return nil, err
 		}
 		return l, nil
 	case 'g':
 		// Try non leaf node
-		n1 := &nonLeafNode{or, nil, make([]Node, 0)}
+		n1 := &nonLeafNode{or, 0, nil, make([]Node, 0)}
 		if err := n1.UnmarshalJSON(data); err != nil {
 			return nil, err
 		}
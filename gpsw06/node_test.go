@@ -7,7 +7,7 @@ import (
 var data []byte
 
 func TestLeafNode_MarshalJSON(t *testing.T) {
-	var l *leafNode = &leafNode{1, nil}
+	var l *leafNode = &leafNode{1, "", nil}
 
 	var err error
 	data, err = l.MarshalJSON()
@@ -20,7 +20,7 @@ func TestLeafNode_MarshalJSON(t *testing.T) {
 }
 
 func TestLeafNode_UnmarshalJSON(t *testing.T) {
-	var l = &leafNode{1, nil}
+	var l = &leafNode{1, "", nil}
 	var l2 leafNode
 	if err := l2.UnmarshalJSON(data); err != nil {
 		t.Errorf("Error during de-serializing leaf node: %v", err)
@@ -33,11 +33,11 @@ func TestLeafNode_UnmarshalJSON(t *testing.T) {
 }
 
 func buildTree() *nonLeafNode {
-	n := &nonLeafNode{or, nil, make([]Node, 0)}
-	n.Children = append(n.Children, &leafNode{1, n}, &leafNode{2, n})
+	n := &nonLeafNode{or, 0, nil, make([]Node, 0)}
+	n.Children = append(n.Children, &leafNode{1, "", n}, &leafNode{2, "", n})
 
-	nc := &nonLeafNode{and, n, make([]Node, 0)}
-	nc.Children = append(nc.Children, &leafNode{3, nc}, &leafNode{4, nc})
+	nc := &nonLeafNode{and, 0, n, make([]Node, 0)}
+	nc.Children = append(nc.Children, &leafNode{3, "", nc}, &leafNode{4, "", nc})
 	n.Children = append(n.Children, nc)
 
 	return n
@@ -71,3 +71,50 @@ func TestNonLeafNode_UnmarshalJSON(t *testing.T) {
 		t.Logf("%s", string(str))
 	}
 }
+
+func buildThresholdTree() *nonLeafNode {
+	n := &nonLeafNode{threshold, 2, nil, make([]Node, 0)}
+	n.Children = append(n.Children, &leafNode{5, "", n}, &leafNode{6, "", n}, &leafNode{7, "", n})
+
+	return n
+}
+
+func TestThresholdNode_UnmarshalJSON(t *testing.T) {
+	n := buildThresholdTree()
+
+	data, err := n.MarshalJSON()
+	if err != nil {
+		t.Errorf("Error durting serializing threshold node: %v", err)
+		return
+	}
+
+	var n1 nonLeafNode
+	if err := n1.UnmarshalJSON(data); err != nil {
+		t.Errorf("Error during de-serializing threshold node: %v", err)
+		return
+	}
+
+	if !n1.Equal(n) || n1.Threshold() != 2 {
+		t.Errorf("threshold node serialization does not match with de-serialization")
+	}
+
+	for _, k := range []int{0, 4} {
+		n.K = k
+		data, _ := n.MarshalJSON()
+		if err := n1.UnmarshalJSON(data); err != ErrInvalidThreshold {
+			t.Errorf("threshold %d of 3 accepted during de-serializing: %v", k, err)
+		}
+	}
+}
+
+func TestThresholdNode_Satisfy(t *testing.T) {
+	n := buildThresholdTree()
+
+	if !n.Satisfy(map[int]struct{}{5: {}, 7: {}}) {
+		t.Errorf("2 of 3 gate not satisfied by 2 attributes")
+	}
+
+	if n.Satisfy(map[int]struct{}{6: {}}) {
+		t.Errorf("2 of 3 gate satisfied by 1 attribute")
+	}
+}
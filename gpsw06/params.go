@@ -0,0 +1,332 @@
+package gpsw06
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"sync"
+
+	"github.com/Nik-U/pbc"
+)
+
+// ParamsVersion is the version of parameter files written by Params.MarshalJSON.
+const ParamsVersion = 1
+
+// FingerprintSize is the length of fingerprints returned by Params.Fingerprint.
+const FingerprintSize = 8
+
+// paramsFile is the format of parameter files, shared with bsw07. g2 may be
+// left out for symmetric pairings, in which case it equals g1.
+type paramsFile struct {
+	Version int    `json:"version"`
+	Pairing string `json:"pairing"`
+	G1      string `json:"g1"`
+	G2      string `json:"g2,omitempty"`
+}
+
+// Params is a set of pairing parameters together with the generators g1 and g2,
+// which determines the groups all keys and ciphertexts of a GPSW06 live in.
+type Params struct {
+	param   string
+	pairing *Pairing
+	g1      *G1
+	g2      *G2
+	zero    *Zr
+	e       *GT      // e(g1, g2) to reduce redundant calculation
+	order   *big.Int // order r of the groups
+
+	fingerprint []byte // digest of param, g1 and g2 embedded in encodings
+
+	// Fixed-base tables for powers of g1, g2 and e(g1, g2)
+	g1Power *pbc.Power
+	g2Power *pbc.Power
+	ePower  *pbc.Power
+}
+
+// loaded holds the parameters created by NewParams keyed by fingerprint, so that
+// encodings of parameters other than the default ones are checked as they are
+// decoded.
+var loaded sync.Map
+
+// NewParams loads pairing parameters in the format of the PBC library and the
+// generators g1, g2 in the format of pbc.Element.SetString. Random generators
+// are chosen for empty g1 or g2, which have to be saved with G1 and G2 for later
+// use. Generators which do not generate the subgroup of order r are rejected
+// with ErrInvalidG1 and ErrInvalidG2.
+func NewParams(param, g1, g2 string) (*Params, error) {
+	pairing, err := pbc.NewPairingFromString(param)
+	if err != nil {
+		return nil, err
+	}
+
+	r := order(pairing)
+	gen1, gen2 := pairing.NewG1().Rand(), pairing.NewG2().Rand()
+
+	if g1 != "" {
+		var ok bool
+		if gen1, ok = pairing.NewG1().SetString(g1, 10); !ok || !isGenerator(gen1, r) {
+			return nil, ErrInvalidG1
+		}
+	}
+
+	if g2 != "" {
+		var ok bool
+		if gen2, ok = pairing.NewG2().SetString(g2, 10); !ok || !isGenerator(gen2, r) {
+			return nil, ErrInvalidG2
+		}
+	}
+
+	e := pairing.NewGT().Pair(gen1, gen2)
+	params := &Params{
+		param,
+		pairing,
+		gen1,
+		gen2,
+		pairing.NewZr().Set0(),
+		e,
+		r,
+		fingerprint(param, gen1, gen2),
+		gen1.PreparePower(),
+		gen2.PreparePower(),
+		e.PreparePower(),
+	}
+	loaded.LoadOrStore(string(params.fingerprint), params)
+
+	return params, nil
+}
+
+// order returns the order r of the groups of pairing, as -1 is r - 1 in Zr.
+func order(pairing *Pairing) *big.Int {
+	r := pairing.NewZr().Set1()
+	return new(big.Int).Add(r.Neg(r).BigInt(), big.NewInt(1))
+}
+
+// isGenerator reports whether g generates the subgroup of order r, which holds
+// for each of its elements but the identity as r is prime.
+func isGenerator(g *pbc.Element, r *big.Int) bool {
+	return !g.Is1() && g.NewFieldElement().PowBig(g, r).Is1()
+}
+
+// fingerprint digests pairing parameters param and generators g1, g2 in the
+// same way as bsw07, so that both packages agree on a parameter file.
+func fingerprint(param string, g1, g2 *pbc.Element) []byte {
+	h := sha256.New()
+	for _, b := range [][]byte{[]byte(param), g1.Bytes(), g2.Bytes()} {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+	return h.Sum(nil)[:FingerprintSize]
+}
+
+// LoadParams is like NewParams, but reads the pairing parameters from the PBC
+// params file at path.
+func LoadParams(path, g1, g2 string) (*Params, error) {
+	param, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewParams(string(param), g1, g2)
+}
+
+// ReadParamsFile reads parameters from the parameter file at path, as written
+// by Params.MarshalJSON.
+func ReadParamsFile(path string) (*Params, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &Params{}
+	if err := json.Unmarshal(data, params); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+// DefaultParams returns the parameters used unless configured with WithParams.
+func DefaultParams() (*Params, error) {
+	return defaultParams, defaultParamsErr
+}
+
+// WithParams sets the pairing parameters of GPSW06 to params.
+func WithParams(params *Params) Option {
+	return func(algo *GPSW06) error {
+		if params == nil {
+			return ErrNilParams
+		}
+		algo.params = params
+		return nil
+	}
+}
+
+// String returns the pairing parameters of p in the format of the PBC library.
+func (p *Params) String() string {
+	return p.param
+}
+
+// Pairing returns the pairing of p.
+func (p *Params) Pairing() *Pairing {
+	return p.pairing
+}
+
+// G1 returns the generator g1 of p.
+func (p *Params) G1() *G1 {
+	return p.g1
+}
+
+// G2 returns the generator g2 of p.
+func (p *Params) G2() *G2 {
+	return p.g2
+}
+
+// Fingerprint returns a short digest of the pairing parameters and generators
+// of p, which is embedded in encodings of keys and ciphertexts.
+func (p *Params) Fingerprint() []byte {
+	return append([]byte(nil), p.fingerprint...)
+}
+
+// checkFingerprint checks that fp is the fingerprint of p, and returns
+// ErrParamsMismatch otherwise. An empty fp is accepted on purpose for keys and
+// ciphertexts of version 1 of the binary format, the only encoding without
+// fingerprints, as Unmarshal and UnmarshalBinary reject other encodings without
+// one.
+func (p *Params) checkFingerprint(fp []byte) error {
+	if len(fp) != 0 && !bytes.Equal(fp, p.fingerprint) {
+		return ErrParamsMismatch
+	}
+	return nil
+}
+
+// decodeLoaded calls decode with the parameters created by NewParams of
+// fingerprint fp, and fails with ErrParamsMismatch if there are none. Without
+// a fingerprint, as for version 1 of binary encodings, it tries the default
+// parameters and then any others, returning the error of the default ones if
+// none of them succeeds.
+func decodeLoaded(fp []byte, decode func(*Params) error) error {
+	if len(fp) != 0 {
+		params, ok := loaded.Load(string(fp))
+		if !ok {
+			return ErrParamsMismatch
+		}
+		return decode(params.(*Params))
+	}
+
+	err := ErrParamsMismatch
+	if defaultParamsErr == nil {
+		if err = decode(defaultParams); err == nil {
+			return nil
+		}
+	}
+
+	loaded.Range(func(_, v interface{}) bool {
+		if p := v.(*Params); p != defaultParams && decode(p) == nil {
+			err = nil
+			return false
+		}
+		return true
+	})
+
+	return err
+}
+
+// hashAttribute maps label into G1 for the large universe construction. The
+// whole label is hashed, so that labels only collide with sha256.
+func (p *Params) hashAttribute(label string) *G1 {
+	h := sha256.Sum256([]byte(label))
+	return p.pairing.NewG1().SetFromHash(h[:])
+}
+
+// setBytes sets el to the element encoded in b, which must be the canonical
+// encoding of el.
+func setBytes(el *pbc.Element, b []byte) (*pbc.Element, error) {
+	// SetBytes reads beyond short buffers, so check the length first
+	if len(b) != el.BytesLen() {
+		return nil, ErrElementLength
+	} else if !bytes.Equal(el.SetBytes(b).Bytes(), b) {
+		return nil, ErrNonCanonical
+	}
+	return el, nil
+}
+
+// setScalar is like setBytes for el of Zr, which must not be zero.
+func setScalar(el *pbc.Element, b []byte) (*pbc.Element, error) {
+	if _, err := setBytes(el, b); err != nil {
+		return nil, err
+	} else if el.Is0() {
+		return nil, ErrIdentityElement
+	}
+	return el, nil
+}
+
+// setElement is like setBytes for el of GT, which must be in the subgroup of
+// order r of params, but not the identity.
+func setElement(params *Params, el *pbc.Element, b []byte) (*pbc.Element, error) {
+	if _, err := setBytes(el, b); err != nil {
+		return nil, err
+	} else if err := params.checkSubgroup(el); err != nil {
+		return nil, err
+	}
+	return el, nil
+}
+
+// setPoint is like setElement for points el of G1 or G2, but also accepts the
+// compressed form of binary encodings.
+func setPoint(params *Params, el *pbc.Element, b []byte) (*pbc.Element, error) {
+	if len(b) == 0 || len(b) != el.CompressedBytesLen() {
+		return setElement(params, el, b)
+	} else if !bytes.Equal(el.SetCompressedBytes(b).CompressedBytes(), b) {
+		return nil, ErrNonCanonical
+	} else if err := params.checkSubgroup(el); err != nil {
+		return nil, err
+	}
+	return el, nil
+}
+
+// checkSubgroup checks that el is in the subgroup of order r, but not the
+// identity.
+func (p *Params) checkSubgroup(el *pbc.Element) error {
+	if el.Is1() {
+		return ErrIdentityElement
+	} else if !el.NewFieldElement().PowBig(el, p.order).Is1() {
+		return ErrNotInSubgroup
+	}
+	return nil
+}
+
+// Params implements encoding/json.Marshaler, producing a parameter file.
+func (p *Params) MarshalJSON() ([]byte, error) {
+	return json.Marshal(paramsFile{ParamsVersion, p.param, p.g1.String(), p.g2.String()})
+}
+
+// Params implements encoding/json.Unmarshaler, reading a parameter file.
+func (p *Params) UnmarshalJSON(b []byte) error {
+	var file paramsFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return err
+	} else if file.Version != ParamsVersion {
+		return ErrParamsVersion
+	} else if file.G1 == "" {
+		return ErrInvalidG1
+	}
+
+	g2 := file.G2
+	if g2 == "" {
+		// Only symmetric pairings accept g1 as an element of G2
+		g2 = file.G1
+	}
+
+	params, err := NewParams(file.Pairing, file.G1, g2)
+	if err != nil {
+		return err
+	}
+
+	*p = *params
+	return nil
+}
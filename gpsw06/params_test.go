@@ -0,0 +1,392 @@
+package gpsw06
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNewParams(t *testing.T) {
+	if _, err := NewParams("type f\nq 1", _g1, _g2); err == nil {
+		t.Errorf("Malformed pairing parameters accepted")
+	}
+
+	if _, err := NewParams(_paramString, "[1, 2", _g2); err != ErrInvalidG1 {
+		t.Errorf("Malformed generator g1 accepted: %v", err)
+	}
+
+	if _, err := NewParams(_paramString, _g1, "[1, 2"); err != ErrInvalidG2 {
+		t.Errorf("Malformed generator g2 accepted: %v", err)
+	}
+
+	identity := defaultParams.pairing.NewG1().Set1().String()
+	if _, err := NewParams(_paramString, identity, _g2); err != ErrInvalidG1 {
+		t.Errorf("Identity accepted as generator g1: %v", err)
+	}
+	if _, err := NewParams(_paramString, _g1, identity); err != ErrInvalidG2 {
+		t.Errorf("Identity accepted as generator g2: %v", err)
+	}
+
+	params, err := NewParams(_paramString, "", "")
+	if err != nil {
+		t.Errorf("Error (%v) during loading parameters with random generators.", err)
+		return
+	}
+
+	params2, err := NewParams(_paramString, params.G1().String(), params.G2().String())
+	if err != nil {
+		t.Errorf("Error (%v) during loading parameters with saved generators.", err)
+	} else if !params2.G1().Equals(params.G1()) || params2.G2().String() != params.G2().String() {
+		t.Errorf("Saved generators loaded wrongly")
+	}
+}
+
+func TestLoadParams(t *testing.T) {
+	f, err := ioutil.TempFile("", "gpsw06")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(_paramString)
+	f.Close()
+
+	if _, err := LoadParams(f.Name(), _g1, _g2); err != nil {
+		t.Errorf("Error (%v) during loading parameters from file.", err)
+	}
+
+	if _, err := LoadParams(f.Name()+".missing", _g1, _g2); err == nil {
+		t.Errorf("Missing parameters file accepted")
+	}
+}
+
+func TestGPSW06_WithParams(t *testing.T) {
+	if _, err := NewGPSW06(NewAttributes(labels), WithParams(nil)); err != ErrNilParams {
+		t.Errorf("Nil parameters accepted: %v", err)
+	}
+
+	params, _ := NewParams(_paramString, "", "")
+	algo, err := NewGPSW06(NewAttributes(labels), WithParams(params))
+	if err != nil {
+		t.Errorf("Error (%v) during initializing GPSW06.", err)
+		return
+	}
+	pk, msk, _ := algo.Setup()
+
+	msg := algo.NewMessage().Rand()
+	ct, err := algo.Encrypt(msg, map[int]struct{}{1: {}}, pk)
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting. Msg: %v.", err, msg)
+		return
+	}
+	dk, _ := algo.KeyGen(&leafNode{1, "", nil}, msk)
+
+	// Keys and ciphertexts decoded are bound to the parameters of the scheme
+	data, _ := ct.Marshal()
+	ct2 := &Ciphertext{}
+	if _, err := ct2.Unmarshal(data); err != nil {
+		t.Errorf("Error (%v) during unmarshaling.", err)
+		return
+	}
+	data, _ = dk.Marshal()
+	dk2 := &DecryptKey{}
+	if _, err := dk2.Unmarshal(data); err != nil {
+		t.Errorf("Error (%v) during unmarshaling.", err)
+		return
+	}
+
+	plain, err := algo.Decrypt(ct2, dk2)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+	} else if !plain.m.Equals(msg.m) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+
+	other, _ := NewGPSW06(NewAttributes(labels))
+	if _, err := other.Decrypt(ct, dk); err != ErrParamsMismatch {
+		t.Errorf("Ciphertext of other parameters accepted: %v", err)
+	}
+	if _, err := other.Encrypt(NewMessage().Rand(), map[int]struct{}{1: {}}, pk); err != ErrParamsMismatch {
+		t.Errorf("Public key of other parameters accepted: %v", err)
+	}
+	if _, err := algo.Encrypt(NewMessage().Rand(), map[int]struct{}{1: {}}, pk); err != ErrParamsMismatch {
+		t.Errorf("Message of other parameters accepted: %v", err)
+	}
+}
+
+func TestParams_JSON(t *testing.T) {
+	params, _ := NewParams(_paramString, "", "")
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Errorf("Error (%v) during marshaling.", err)
+		return
+	}
+
+	params2 := &Params{}
+	if err := json.Unmarshal(data, params2); err != nil {
+		t.Errorf("Error (%v) during unmarshaling.", err)
+	} else if params2.String() != params.String() || !params2.G1().Equals(params.G1()) || !params2.G2().Equals(params.G2()) {
+		t.Errorf("Parameters unmarshaled wrongly")
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":2,"pairing":"","g1":""}`), params2); err != ErrParamsVersion {
+		t.Errorf("Unsupported version accepted: %v", err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":1,"pairing":"type f\nr 11"}`), params2); err != ErrInvalidG1 {
+		t.Errorf("Parameters without generator accepted: %v", err)
+	}
+}
+
+func TestParams_Power(t *testing.T) {
+	params := defaultParams
+	x := params.pairing.NewZr().Rand()
+
+	if !params.pairing.NewG1().PowerZn(params.g1Power, x).Equals(params.pairing.NewG1().PowZn(params.g1, x)) {
+		t.Errorf("Power table of g1 does not match g1")
+	}
+
+	if !params.pairing.NewG2().PowerZn(params.g2Power, x).Equals(params.pairing.NewG2().PowZn(params.g2, x)) {
+		t.Errorf("Power table of g2 does not match g2")
+	}
+
+	if !params.pairing.NewGT().PowerZn(params.ePower, x).Equals(params.pairing.NewGT().PowZn(params.e, x)) {
+		t.Errorf("Power table of e(g1, g2) does not match e(g1, g2)")
+	}
+}
+
+func TestGPSW06_publicPower(t *testing.T) {
+	algo, _ := NewLargeUniverseGPSW06()
+	tree := &leafNode{0, "a", nil}
+
+	// The table must follow the public key when it changes between calls
+	for i := 0; i < 2; i++ {
+		pk, msk, _ := algo.Setup()
+		dk, _ := algo.KeyGen(tree, msk)
+
+		msg := algo.NewMessage().Rand()
+		ct, err := algo.EncryptLabels(msg, map[string]struct{}{"a": {}}, pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if plain, err := algo.Decrypt(ct, dk); err != nil {
+			t.Errorf("Error (%v) during decryption with public key %d.", err, i)
+		} else if !plain.m.Equals(msg.m) {
+			t.Errorf("Message encrypted with public key %d and decrypted differs.", i)
+		}
+	}
+}
+
+func BenchmarkParams_Power(b *testing.B) {
+	params := defaultParams
+	x := params.pairing.NewZr().Rand()
+	y := params.pairing.NewG1()
+
+	b.Run("PowZn", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			y.PowZn(params.g1, x)
+		}
+	})
+
+	b.Run("PowerZn", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			y.PowerZn(params.g1Power, x)
+		}
+	})
+}
+
+func BenchmarkGPSW06_KeyGen(b *testing.B) {
+	for _, n := range []int{10, 50} {
+		algo := wideInstance(n, true)
+		_, msk, _ := algo.Setup()
+
+		tree, err := wideTree(algo, n)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("leaves=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := algo.KeyGen(tree, msk); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestParams_setBytes(t *testing.T) {
+	params := defaultParams
+	pairing := params.pairing
+	r := pairing.NewZr().Rand()
+
+	overflow := make([]byte, r.BytesLen())
+	for i := range overflow {
+		overflow[i] = 0xff
+	}
+
+	for _, v := range []struct {
+		name string
+		set  func() (*Zr, error)
+		err  error
+	}{
+		{"valid G1", func() (*Zr, error) { return setPoint(params, pairing.NewG1(), pairing.NewG1().Rand().Bytes()) }, nil},
+		{"compressed G2", func() (*Zr, error) {
+			return setPoint(params, pairing.NewG2(), pairing.NewG2().Rand().CompressedBytes())
+		}, nil},
+		{"valid GT", func() (*Zr, error) { return setElement(params, pairing.NewGT(), pairing.NewGT().Rand().Bytes()) }, nil},
+		{"valid Zr", func() (*Zr, error) { return setScalar(pairing.NewZr(), r.Bytes()) }, nil},
+		{"short G1", func() (*Zr, error) { return setPoint(params, pairing.NewG1(), pairing.NewG1().Rand().Bytes()[1:]) }, ErrElementLength},
+		{"empty GT", func() (*Zr, error) { return setElement(params, pairing.NewGT(), nil) }, ErrElementLength},
+		{"identity G2", func() (*Zr, error) { return setPoint(params, pairing.NewG2(), pairing.NewG2().Set1().Bytes()) }, ErrIdentityElement},
+		{"identity GT", func() (*Zr, error) { return setElement(params, pairing.NewGT(), pairing.NewGT().Set1().Bytes()) }, ErrIdentityElement},
+		{"zero Zr", func() (*Zr, error) { return setScalar(pairing.NewZr(), pairing.NewZr().Set0().Bytes()) }, ErrIdentityElement},
+		{"overflowing Zr", func() (*Zr, error) { return setScalar(pairing.NewZr(), overflow) }, ErrNonCanonical},
+	} {
+		if _, err := v.set(); err != v.err {
+			t.Errorf("Element %s set with %v, expecting %v", v.name, err, v.err)
+		}
+	}
+}
+
+func TestGPSW06_DecryptMalformed(t *testing.T) {
+	algo, _ := NewGPSW06(NewAttributes(labels))
+	pk, _, _ := algo.Setup()
+
+	ct, err := algo.Encrypt(algo.NewMessage().Rand(), map[int]struct{}{1: {}}, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw ciphertext
+	data, _ := ct.Marshal()
+	str, _ := base64.StdEncoding.DecodeString(string(data))
+	if err := json.Unmarshal(str, &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	identity := algo.params.pairing.NewG2().Set1().Bytes()
+
+	// Ciphertexts tampered with are rejected when unmarshaled rather than
+	// crashing Decrypt
+	for _, v := range []struct {
+		name string
+		raw  ciphertext
+		err  error
+	}{
+		{"missing message", ciphertext{nil, raw.Attrs, nil, raw.Fingerprint}, ErrElementLength},
+		{"missing attribute", ciphertext{raw.Msg, map[string][]byte{"1": nil}, nil, raw.Fingerprint}, ErrElementLength},
+		{"identity attribute", ciphertext{raw.Msg, map[string][]byte{"1": identity}, nil, raw.Fingerprint}, ErrIdentityElement},
+		{"unknown parameters", ciphertext{raw.Msg, raw.Attrs, nil, []byte("unknown")}, ErrParamsMismatch},
+	} {
+		str, _ := json.Marshal(v.raw)
+		data := []byte(base64.StdEncoding.EncodeToString(str))
+		ct2 := &Ciphertext{}
+		if _, err := ct2.Unmarshal(data); err != v.err {
+			t.Errorf("Ciphertext with %s unmarshaled with %v, expecting %v", v.name, err, v.err)
+			continue
+		}
+
+		// Ciphertexts which were not decoded are written as they were read
+		if data2, err := ct2.Marshal(); err != nil || string(data2) != string(data) {
+			t.Errorf("Ciphertext with %s marshaled wrongly: %v", v.name, err)
+		}
+		if data2, err := ct2.MarshalBinary(); err != nil {
+			t.Errorf("Error (%v) during marshaling %s to binary.", err, v.name)
+		} else if err := (&Ciphertext{}).UnmarshalBinary(data2); err != v.err {
+			t.Errorf("Binary ciphertext with %s unmarshaled with %v, expecting %v", v.name, err, v.err)
+		}
+	}
+
+	// Deleting the fingerprint does not skip the check of parameters
+	str, _ = json.Marshal(ciphertext{raw.Msg, raw.Attrs, nil, nil})
+	if _, err := (&Ciphertext{}).Unmarshal([]byte(base64.StdEncoding.EncodeToString(str))); err != ErrMissingFingerprint {
+		t.Errorf("Ciphertext without fingerprint unmarshaled with %v, expecting %v", err, ErrMissingFingerprint)
+	}
+
+	dk2 := &DecryptKey{}
+	str, _ = json.Marshal(decryptKey{"private", nil, nil, nil, raw.Fingerprint})
+	if _, err := dk2.Unmarshal([]byte(base64.StdEncoding.EncodeToString(str))); err != nil {
+		t.Fatal(err)
+	} else if _, err := algo.Decrypt(ct, dk2); err != ErrBadNodeJSON {
+		t.Errorf("Key without tree decrypted with %v", err)
+	}
+}
+
+func TestParams_Fingerprint(t *testing.T) {
+	params, _ := NewParams(_paramString, "", "")
+	params2, _ := NewParams(_paramString, params.G1().String(), params.G2().String())
+	other, _ := NewParams(_paramString, "", "")
+
+	if len(params.Fingerprint()) != FingerprintSize {
+		t.Errorf("Fingerprint of %d bytes", len(params.Fingerprint()))
+	} else if !bytes.Equal(params.Fingerprint(), params2.Fingerprint()) {
+		t.Errorf("Fingerprints of the same parameters differ")
+	} else if bytes.Equal(params.Fingerprint(), other.Fingerprint()) {
+		t.Errorf("Fingerprints of other generators equal")
+	}
+
+	algo, _ := NewGPSW06(NewAttributes(labels), WithParams(params))
+	pk, msk, _ := algo.Setup()
+	dk, _ := algo.KeyGen(&leafNode{1, "", nil}, msk)
+	ct, err := algo.Encrypt(algo.NewMessage().Rand(), map[int]struct{}{1: {}}, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Elements of the same lengths would decode to garbage under other
+	// generators, so the fingerprint is all there is to reject them
+	algo2, _ := NewGPSW06(NewAttributes(labels), WithParams(other))
+	data, _ := ct.Marshal()
+	ct2 := &Ciphertext{}
+	if _, err := ct2.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = dk.Marshal()
+	dk2 := &DecryptKey{}
+	if _, err := dk2.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = msk.Marshal()
+	msk2 := &MasterKey{}
+	if _, err := msk2.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := algo2.Decrypt(ct2, dk2); err != ErrParamsMismatch {
+		t.Errorf("Ciphertext of other generator accepted: %v", err)
+	}
+	if _, err := algo2.KeyGen(&leafNode{1, "", nil}, msk2); err != ErrParamsMismatch {
+		t.Errorf("Master key of other generator accepted: %v", err)
+	}
+
+	data, _ = ct.MarshalBinary()
+	ct3 := &Ciphertext{}
+	if err := ct3.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	} else if _, err := algo2.Decrypt(ct3, dk2); err != ErrParamsMismatch {
+		t.Errorf("Binary ciphertext of other generator accepted: %v", err)
+	} else if _, err := algo.Decrypt(ct3, dk2); err != nil {
+		t.Errorf("Error (%v) during decryption of binary ciphertext.", err)
+	}
+
+	// Encodings of version 1 have no fingerprint
+	data, _ = pk.MarshalBinary()
+	v1 := append([]byte{1, binaryPublicKey}, data[3+FingerprintSize:]...)
+	pk2 := &PublicKey{}
+	if err := pk2.UnmarshalBinary(v1); err != nil {
+		t.Errorf("Error (%v) during unmarshaling version 1.", err)
+	} else if pk2.raw.Fingerprint != nil {
+		t.Errorf("Fingerprint in version 1")
+	}
+
+	// Later versions require a fingerprint
+	v2 := append([]byte{BinaryVersion, binaryPublicKey, 0}, data[3+FingerprintSize:]...)
+	if err := (&PublicKey{}).UnmarshalBinary(v2); err != ErrMissingFingerprint {
+		t.Errorf("Public key without fingerprint unmarshaled with %v, expecting %v", err, ErrMissingFingerprint)
+	}
+}
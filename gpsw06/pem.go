@@ -0,0 +1,203 @@
+package gpsw06
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/pem"
+	"strconv"
+)
+
+// PEM block types of keys and ciphertexts, whose bytes are the binary encoding.
+const (
+	PEMPublicKey  = "ABE GPSW06 PUBLIC KEY"
+	PEMMasterKey  = "ABE GPSW06 MASTER KEY"
+	PEMDecryptKey = "ABE GPSW06 DECRYPT KEY"
+	PEMCiphertext = "ABE GPSW06 CIPHERTEXT"
+	PEMEnvelope   = "ABE GPSW06 ENVELOPE"
+)
+
+// encodePEM armors the binary encoding data in a block of type typ, with
+// headers naming the scheme, the version of the encoding and the parameter
+// fingerprint fp, if any.
+func encodePEM(typ string, data, fp []byte) []byte {
+	headers := map[string]string{
+		"Scheme":  "gpsw06",
+		"Version": strconv.Itoa(int(data[0])),
+	}
+	if len(fp) != 0 {
+		headers["Fingerprint"] = hex.EncodeToString(fp)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Headers: headers, Bytes: data})
+}
+
+// decodePEM returns the block armored in data, which fails with wrongType
+// unless the block is of type typ. Headers, which are optional, must agree with
+// the binary encoding.
+func decodePEM(data []byte, typ string, wrongType error) (*pem.Block, error) {
+	block, rest := pem.Decode(data)
+	if block == nil || len(bytes.TrimSpace(rest)) != 0 {
+		return nil, ErrBadPEM
+	} else if block.Type != typ {
+		return nil, wrongType
+	}
+
+	if scheme, ok := block.Headers["Scheme"]; ok && scheme != "gpsw06" {
+		return nil, ErrBadPEM
+	}
+	if version, ok := block.Headers["Version"]; ok && (len(block.Bytes) == 0 || version != strconv.Itoa(int(block.Bytes[0]))) {
+		return nil, ErrBadPEM
+	}
+
+	return block, nil
+}
+
+// checkFingerprint checks the fingerprint header of block, if any, against the
+// fingerprint fp of the binary encoding.
+func checkFingerprint(block *pem.Block, fp []byte) error {
+	if header, ok := block.Headers["Fingerprint"]; ok && header != hex.EncodeToString(fp) {
+		return ErrBadPEM
+	}
+	return nil
+}
+
+// MarshalPEM encodes pk in a PEM block of type PEMPublicKey.
+func (pk *PublicKey) MarshalPEM() ([]byte, error) {
+	data, err := pk.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMPublicKey, data, pk.fingerprint()), nil
+}
+
+// UnmarshalPEM sets pk to the key encoded by MarshalPEM. Blocks of other types
+// are rejected with ErrExpectingPublicKey.
+func (pk *PublicKey) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMPublicKey, ErrExpectingPublicKey)
+	if err != nil {
+		return err
+	}
+
+	key := &PublicKey{}
+	if err := key.UnmarshalBinary(block.Bytes); err != nil {
+		return err
+	} else if err := checkFingerprint(block, key.raw.Fingerprint); err != nil {
+		return err
+	}
+
+	*pk = *key
+	return nil
+}
+
+// MarshalPEM encodes msk in a PEM block of type PEMMasterKey.
+func (msk *MasterKey) MarshalPEM() ([]byte, error) {
+	data, err := msk.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMMasterKey, data, msk.fingerprint()), nil
+}
+
+// UnmarshalPEM sets msk to the key encoded by MarshalPEM. Blocks of other types
+// are rejected with ErrExpectingMasterKey.
+func (msk *MasterKey) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMMasterKey, ErrExpectingMasterKey)
+	if err != nil {
+		return err
+	}
+
+	key := &MasterKey{}
+	if err := key.UnmarshalBinary(block.Bytes); err != nil {
+		return err
+	} else if err := checkFingerprint(block, key.raw.Fingerprint); err != nil {
+		return err
+	}
+
+	*msk = *key
+	return nil
+}
+
+// MarshalPEM encodes dk in a PEM block of type PEMDecryptKey.
+func (dk *DecryptKey) MarshalPEM() ([]byte, error) {
+	data, err := dk.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMDecryptKey, data, dk.fingerprint()), nil
+}
+
+// UnmarshalPEM sets dk to the key encoded by MarshalPEM. Blocks of other types
+// are rejected with ErrExpectingPrivateKey.
+func (dk *DecryptKey) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMDecryptKey, ErrExpectingPrivateKey)
+	if err != nil {
+		return err
+	}
+
+	key := &DecryptKey{}
+	if err := key.UnmarshalBinary(block.Bytes); err != nil {
+		return err
+	} else if err := checkFingerprint(block, key.raw.Fingerprint); err != nil {
+		return err
+	}
+
+	*dk = *key
+	return nil
+}
+
+// MarshalPEM encodes ct in a PEM block of type PEMCiphertext.
+func (ct *Ciphertext) MarshalPEM() ([]byte, error) {
+	data, err := ct.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMCiphertext, data, ct.fingerprint()), nil
+}
+
+// UnmarshalPEM sets ct to the ciphertext encoded by MarshalPEM. Blocks of other
+// types are rejected with ErrExpectingCiphertext.
+func (ct *Ciphertext) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMCiphertext, ErrExpectingCiphertext)
+	if err != nil {
+		return err
+	}
+
+	c := &Ciphertext{}
+	if err := c.UnmarshalBinary(block.Bytes); err != nil {
+		return err
+	} else if err := checkFingerprint(block, c.raw.Fingerprint); err != nil {
+		return err
+	}
+
+	*ct = *c
+	return nil
+}
+
+// MarshalPEM encodes env in a PEM block of type PEMEnvelope, with the
+// fingerprint of its key.
+func (env *Envelope) MarshalPEM() ([]byte, error) {
+	data, err := env.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodePEM(PEMEnvelope, data, env.key.fingerprint()), nil
+}
+
+// UnmarshalPEM sets env to the envelope encoded by MarshalPEM. Blocks of other
+// types are rejected with ErrExpectingEnvelope.
+func (env *Envelope) UnmarshalPEM(data []byte) error {
+	block, err := decodePEM(data, PEMEnvelope, ErrExpectingEnvelope)
+	if err != nil {
+		return err
+	}
+
+	e := &Envelope{}
+	if err := e.UnmarshalBinary(block.Bytes); err != nil {
+		return err
+	} else if err := checkFingerprint(block, e.key.raw.Fingerprint); err != nil {
+		return err
+	}
+
+	*env = *e
+	return nil
+}
@@ -0,0 +1,89 @@
+package gpsw06
+
+import (
+	"bytes"
+	"testing"
+)
+
+// pemMarshaler is implemented by the keys and ciphertexts of the package.
+type pemMarshaler interface {
+	marshaler
+	MarshalPEM() ([]byte, error)
+	UnmarshalPEM([]byte) error
+}
+
+func TestPEM(t *testing.T) {
+	algo := wideInstance(4, false)
+	pk, msk, _ := algo.Setup()
+
+	tree, _ := ParsePolicy("1 AND (2 OR 3)")
+	dk, err := algo.KeyGen(tree, msk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attrs := map[int]struct{}{1: {}, 2: {}}
+	ct, err := algo.Encrypt(algo.NewMessage().Rand(), attrs, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := algo.EncryptBytes([]byte("plaintext"), attrs, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoded values must encode to the same base64 encoding
+	for _, v := range []struct {
+		in, out pemMarshaler
+		typ     string
+	}{
+		{pk, &PublicKey{}, PEMPublicKey},
+		{msk, &MasterKey{}, PEMMasterKey},
+		{dk, &DecryptKey{}, PEMDecryptKey},
+		{ct, &Ciphertext{}, PEMCiphertext},
+		{env, &Envelope{}, PEMEnvelope},
+	} {
+		data, err := v.in.MarshalPEM()
+		if err != nil {
+			t.Errorf("Error (%v) during marshaling %T.", err, v.in)
+			continue
+		}
+
+		if !bytes.HasPrefix(data, []byte("-----BEGIN "+v.typ+"-----\n")) || !bytes.Contains(data, []byte("Scheme: gpsw06\n")) || !bytes.Contains(data, []byte("Fingerprint: ")) {
+			t.Errorf("%T armored wrongly:\n%s", v.in, data)
+		}
+
+		if err := v.out.UnmarshalPEM(data); err != nil {
+			t.Errorf("Error (%v) during unmarshaling %T.", err, v.in)
+			continue
+		}
+
+		expected, _ := v.in.Marshal()
+		actual, _ := v.out.Marshal()
+		if string(expected) != string(actual) {
+			t.Errorf("%T unmarshaled wrongly", v.in)
+		}
+	}
+
+	data, _ := msk.MarshalPEM()
+	if err := (&PublicKey{}).UnmarshalPEM(data); err != ErrExpectingPublicKey {
+		t.Errorf("Master key accepted as public key: %v", err)
+	}
+
+	data, _ = pk.MarshalPEM()
+	for _, v := range []struct {
+		name string
+		data []byte
+	}{
+		{"garbage", []byte("not a PEM block")},
+		{"trailing data", append(append([]byte{}, data...), "trailing"...)},
+		{"wrong scheme", bytes.Replace(data, []byte("Scheme: gpsw06"), []byte("Scheme: bsw07"), 1)},
+		{"wrong version", bytes.Replace(data, []byte("Version: "), []byte("Version: 9"), 1)},
+		{"wrong fingerprint", bytes.Replace(data, []byte("Fingerprint: "), []byte("Fingerprint: 00"), 1)},
+	} {
+		if err := (&PublicKey{}).UnmarshalPEM(v.data); err != ErrBadPEM {
+			t.Errorf("Public key with %s unmarshaled with %v, expecting %v", v.name, err, ErrBadPEM)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package gpsw06
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind uint
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokComma
+	tokAnd
+	tokOr
+	tokOf
+	tokAttr
+	tokQuoted
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lexer splits a policy string into tokens. Keywords are case insensitive;
+// attributes are either bare words or double quoted Go string literals.
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		l.pos += size
+	}
+
+	start := l.pos
+	if start >= len(l.src) {
+		return token{tokEOF, "", start}, nil
+	}
+
+	switch l.src[start] {
+	case '(':
+		l.pos++
+		return token{tokLParen, "(", start}, nil
+	case ')':
+		l.pos++
+		return token{tokRParen, ")", start}, nil
+	case ',':
+		l.pos++
+		return token{tokComma, ",", start}, nil
+	case '"':
+		for end := start + 1; end < len(l.src); end++ {
+			if l.src[end] == '\\' {
+				end++
+			} else if l.src[end] == '"' {
+				attr, err := strconv.Unquote(l.src[start : end+1])
+				if err != nil {
+					return token{}, &PolicySyntaxError{start, "malformed quoted attribute"}
+				}
+				l.pos = end + 1
+				return token{tokQuoted, attr, start}, nil
+			}
+		}
+		return token{}, &PolicySyntaxError{start, "unterminated quoted attribute"}
+	}
+
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if unicode.IsSpace(r) || strings.ContainsRune(`(),"`, r) {
+			break
+		}
+		l.pos += size
+	}
+
+	word := l.src[start:l.pos]
+	switch strings.ToLower(word) {
+	case "and":
+		return token{tokAnd, word, start}, nil
+	case "or":
+		return token{tokOr, word, start}, nil
+	case "of":
+		return token{tokOf, word, start}, nil
+	default:
+		return token{tokAttr, word, start}, nil
+	}
+}
+
+// parser is a recursive descent parser for the grammar
+//
+//	expr    = term { "OR" term }
+//	term    = operand { "AND" operand }
+//	operand = "(" expr ")" | number "of" "(" expr { "," expr } ")" | attribute
+type parser struct {
+	lex   *lexer
+	tok   token
+	peek  *token
+	depth int // parentheses open at tok
+	leaf  func(token) (*leafNode, error)
+}
+
+func (p *parser) advance() error {
+	if p.peek != nil {
+		p.tok, p.peek = *p.peek, nil
+		return nil
+	}
+
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) lookahead() (token, error) {
+	if p.peek == nil {
+		tok, err := p.lex.next()
+		if err != nil {
+			return token{}, err
+		}
+		p.peek = &tok
+	}
+	return *p.peek, nil
+}
+
+// open enters the parenthesis at tok, which fails for policies nested deeper than
+// maxNodeDepth so that parsing cannot exhaust the stack.
+func (p *parser) open() error {
+	if p.depth >= maxNodeDepth {
+		return &PolicySyntaxError{p.tok.pos, fmt.Sprintf("policy nested deeper than %d levels", maxNodeDepth)}
+	}
+	p.depth++
+	return p.advance()
+}
+
+// close leaves the parenthesis entered by open.
+func (p *parser) close(what string) error {
+	p.depth--
+	return p.expect(tokRParen, what)
+}
+
+func (p *parser) expect(kind tokenKind, what string) error {
+	if p.tok.kind != kind {
+		return p.unexpected(what)
+	}
+	return p.advance()
+}
+
+func (p *parser) unexpected(what string) error {
+	if p.tok.kind == tokEOF {
+		return &PolicySyntaxError{p.tok.pos, fmt.Sprintf("expected %s, found end of policy", what)}
+	}
+	return &PolicySyntaxError{p.tok.pos, fmt.Sprintf("expected %s, found %q", what, p.tok.text)}
+}
+
+func (p *parser) parseExpr() (Node, error) {
+	return p.parseChain(tokOr, or, p.parseTerm)
+}
+
+func (p *parser) parseTerm() (Node, error) {
+	return p.parseChain(tokAnd, and, p.parseOperand)
+}
+
+// parseChain parses operands separated by sep and joins them under a single gate,
+// so that "a AND b AND c" becomes one gate with three children.
+func (p *parser) parseChain(sep tokenKind, gate operator, operand func() (Node, error)) (Node, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []Node{first}
+	for p.tok.kind == sep {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		child, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	if len(children) == 1 {
+		return first, nil
+	}
+	return newGate(gate, children), nil
+}
+
+func (p *parser) parseOperand() (Node, error) {
+	switch p.tok.kind {
+	case tokLParen:
+		if err := p.open(); err != nil {
+			return nil, err
+		}
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.close("')'")
+	case tokAttr, tokQuoted:
+		if p.tok.kind == tokAttr {
+			next, err := p.lookahead()
+			if err != nil {
+				return nil, err
+			}
+			if next.kind == tokOf {
+				return p.parseThreshold()
+			}
+		}
+
+		leaf, err := p.leaf(p.tok)
+		if err != nil {
+			return nil, err
+		}
+		return leaf, p.advance()
+	default:
+		return nil, p.unexpected("attribute or '('")
+	}
+}
+
+func (p *parser) parseThreshold() (Node, error) {
+	kTok := p.tok
+	k, err := strconv.Atoi(kTok.text)
+	if err != nil {
+		return nil, &PolicySyntaxError{kTok.pos, fmt.Sprintf("threshold %q is not a number", kTok.text)}
+	}
+
+	// Skip the number and "of"
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokLParen {
+		return nil, p.unexpected("'('")
+	}
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+
+	var children []Node
+	for {
+		child, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+
+		if p.tok.kind != tokComma {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.close("',' or ')'"); err != nil {
+		return nil, err
+	}
+
+	if k < 1 || k > len(children) {
+		return nil, &PolicySyntaxError{kTok.pos, fmt.Sprintf("threshold %d out of range for %d children", k, len(children))}
+	}
+
+	n := newGate(threshold, children)
+	n.K = k
+	return n, nil
+}
+
+// newGate creates a non-leaf node with children and points their parent to it.
+func newGate(gate operator, children []Node) *nonLeafNode {
+	n := &nonLeafNode{gate, 0, nil, children}
+	for _, child := range children {
+		switch node := child.(type) {
+		case *leafNode:
+			node.parent = n
+		case *nonLeafNode:
+			node.parent = n
+		}
+	}
+	return n
+}
+
+// ParsePolicy builds an access structure tree from a boolean policy over attribute
+// indices such as `(0 AND 1) OR (2 of (2, 3, 4))`. AND binds tighter than OR.
+func ParsePolicy(policy string) (Node, error) {
+	return parsePolicy(policy, func(tok token) (*leafNode, error) {
+		attr, err := strconv.Atoi(tok.text)
+		if err != nil || attr < 0 || tok.kind != tokAttr {
+			return nil, &PolicySyntaxError{tok.pos, fmt.Sprintf("attribute %q is not a non-negative index", tok.text)}
+		}
+		return &leafNode{attr, "", nil}, nil
+	})
+}
+
+// ParsePolicy builds an access structure tree from a boolean policy over attribute
+// labels of the universe of algo, such as `(a AND b) OR (1 of (c, d, e))`. Any
+// non-empty label is accepted by a large universe instance, whose leaves hold
+// the labels themselves.
+func (algo *GPSW06) ParsePolicy(policy string) (Node, error) {
+	return parsePolicy(policy, func(tok token) (*leafNode, error) {
+		if algo.large {
+			if tok.text == "" {
+				return nil, &PolicySyntaxError{tok.pos, "empty attribute label"}
+			}
+			return &leafNode{0, tok.text, nil}, nil
+		}
+
+		attr, err := algo.attributeID(tok.text)
+		if err != nil {
+			return nil, &PolicySyntaxError{tok.pos, fmt.Sprintf("unknown attribute %q", tok.text)}
+		}
+		return &leafNode{attr, "", nil}, nil
+	})
+}
+
+func parsePolicy(policy string, leaf func(token) (*leafNode, error)) (Node, error) {
+	p := &parser{lex: &lexer{src: policy}, leaf: leaf}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected("AND, OR or end of policy")
+	}
+
+	return node, nil
+}
+
+// formatLabel quotes label when it would not be read back as a single bare word.
+func formatLabel(label string) string {
+	switch strings.ToLower(label) {
+	case "", "and", "or", "of":
+		return strconv.Quote(label)
+	}
+
+	for _, r := range label {
+		if unicode.IsSpace(r) || strings.ContainsRune(`(),"`, r) || !unicode.IsPrint(r) {
+			return strconv.Quote(label)
+		}
+	}
+
+	return label
+}
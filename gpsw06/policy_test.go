@@ -0,0 +1,90 @@
+package gpsw06
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tree, err := ParsePolicy("1 OR 2 OR (3 AND 4)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	if !tree.Equal(buildTree()) {
+		t.Errorf("Parsed policy does not match with tree: %s", tree)
+	}
+
+	for _, policy := range []string{
+		"0",
+		"(0 AND 5) OR (2 of (2, 3, 4))",
+		"0 OR (1 AND 2) OR (3 AND (4 OR 5))",
+		"2 of (3, 4)",
+	} {
+		tree, err := ParsePolicy(policy)
+		if err != nil {
+			t.Errorf("Error (%v) during parsing policy %q.", err, policy)
+			continue
+		}
+
+		tree2, err := ParsePolicy(tree.String())
+		if err != nil {
+			t.Errorf("Error (%v) during parsing formatted policy %q.", err, tree.String())
+			continue
+		}
+
+		if !tree.Equal(tree2) {
+			t.Errorf("Policy %q does not match with formatted policy %q", policy, tree.String())
+		}
+	}
+}
+
+func TestGPSW06_ParsePolicy(t *testing.T) {
+	algo, _ := NewGPSW06(NewAttributes(labels))
+
+	tree, err := algo.ParsePolicy("b OR c OR (d AND e)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	if !tree.Equal(buildTree()) {
+		t.Errorf("Parsed policy does not match with tree: %s", tree)
+	}
+
+	_, err = algo.ParsePolicy("b OR unknown")
+	if serr, ok := err.(*PolicySyntaxError); !ok || serr.Offset != 5 {
+		t.Errorf("Unknown attribute reported wrongly: %v", err)
+	}
+}
+
+func TestParsePolicy_Error(t *testing.T) {
+	for policy, offset := range map[string]int{
+		"":               0,
+		"1 AND":          5,
+		"1 OR a":         5,
+		"-1":             0,
+		`1 AND "2"`:      6,
+		"(1 OR 2":        7,
+		"4 of (1, 2, 3)": 0,
+		strings.Repeat("(", maxNodeDepth+1) + "1" + strings.Repeat(")", maxNodeDepth+1): maxNodeDepth,
+	} {
+		_, err := ParsePolicy(policy)
+		serr, ok := err.(*PolicySyntaxError)
+		if !ok {
+			t.Errorf("Policy %q parsed without syntax error: %v", policy, err)
+			continue
+		}
+
+		if serr.Offset != offset {
+			t.Errorf("Policy %q reported error at offset %d, expected %d: %v", policy, serr.Offset, offset, serr)
+		}
+	}
+
+	// Parentheses nested up to maxNodeDepth are accepted
+	policy := strings.Repeat("(", maxNodeDepth) + "1" + strings.Repeat(")", maxNodeDepth)
+	if _, err := ParsePolicy(policy); err != nil {
+		t.Errorf("Error (%v) during parsing policy nested %d deep.", err, maxNodeDepth)
+	}
+}
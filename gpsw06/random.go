@@ -0,0 +1,40 @@
+package gpsw06
+
+import (
+	cryptorand "crypto/rand"
+	"io"
+)
+
+// WithRandom sets the source of randomness of GPSW06 to r, which defaults to
+// crypto/rand.Reader. Every secret of Setup, KeyGen, Delegate, Encrypt and
+// EncryptBytes is read from r, so that a deterministic r reproduces keys and
+// ciphertexts, which is only meant for tests.
+func WithRandom(r io.Reader) Option {
+	return func(algo *GPSW06) error {
+		if r == nil {
+			return ErrNilRandom
+		}
+		algo.random = r
+		return nil
+	}
+}
+
+// randZr returns a uniformly random element of Zr read from the source of
+// randomness of algo.
+func (algo *GPSW06) randZr() (*Zr, error) {
+	x, err := cryptorand.Int(algo.random, algo.params.order)
+	if err != nil {
+		return nil, err
+	}
+	return algo.params.pairing.NewZr().SetBig(x), nil
+}
+
+// randMessage returns a random message e(g1,g2)^x read from the source of
+// randomness of algo.
+func (algo *GPSW06) randMessage() (*Message, error) {
+	x, err := algo.randZr()
+	if err != nil {
+		return nil, err
+	}
+	return &Message{algo.params.pairing.NewGT().PowerZn(algo.params.ePower, x), algo.params}, nil
+}
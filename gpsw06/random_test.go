@@ -0,0 +1,225 @@
+package gpsw06
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+var update = flag.Bool("update", false, "rewrite the known-answer vectors in testdata")
+
+// seededReader is a deterministic source of randomness, SHA-256 of the seed and
+// a counter.
+type seededReader struct {
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+func (r *seededReader) Read(p []byte) (int, error) {
+	for n := 0; n < len(p); {
+		if len(r.buf) == 0 {
+			block := make([]byte, len(r.seed)+8)
+			copy(block, r.seed)
+			binary.BigEndian.PutUint64(block[len(r.seed):], r.counter)
+			r.counter++
+			sum := sha256.Sum256(block)
+			r.buf = sum[:]
+		}
+		c := copy(p[n:], r.buf)
+		r.buf = r.buf[c:]
+		n += c
+	}
+	return len(p), nil
+}
+
+// knownAnswers holds the keys and ciphertext derived from a seed, in the
+// encoding of Marshal.
+type knownAnswers struct {
+	Seed       string   `json:"seed"`
+	Policy     string   `json:"policy"`
+	Narrower   string   `json:"narrower"`
+	Attributes []string `json:"attributes"`
+	PublicKey  string   `json:"pk"`
+	MasterKey  string   `json:"msk"`
+	DecryptKey string   `json:"dk"`
+	Delegated  string   `json:"delegated"`
+	Message    []byte   `json:"msg"`
+	Ciphertext string   `json:"ct"`
+}
+
+func deriveKnownAnswers(t *testing.T, seed string) *knownAnswers {
+	algo, err := NewLargeUniverseGPSW06(WithRandom(&seededReader{seed: []byte(seed)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kat := &knownAnswers{
+		Seed:       seed,
+		Policy:     "a OR (b AND c)",
+		Narrower:   "(a OR (b AND c)) AND d",
+		Attributes: []string{"a", "d"},
+	}
+
+	pk, msk, err := algo.Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := algo.ParsePolicy(kat.Policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dk, err := algo.KeyGen(tree, msk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	narrower, err := algo.ParsePolicy(kat.Narrower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegated, err := algo.Delegate(dk, narrower)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := make(map[string]struct{})
+	for _, label := range kat.Attributes {
+		labels[label] = struct{}{}
+	}
+	msg, err := algo.randMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := algo.EncryptLabels(msg, labels, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plain, err := algo.Decrypt(ct, delegated); err != nil || !plain.m.Equals(msg.m) {
+		t.Fatalf("Known-answer ciphertext does not decrypt: %v", err)
+	}
+
+	kat.Message = msg.Marshal()
+	for _, field := range []struct {
+		dst *string
+		v   interface{ Marshal() ([]byte, error) }
+	}{
+		{&kat.PublicKey, pk},
+		{&kat.MasterKey, msk},
+		{&kat.DecryptKey, dk},
+		{&kat.Delegated, delegated},
+		{&kat.Ciphertext, ct},
+	} {
+		data, err := field.v.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		*field.dst = string(data)
+	}
+
+	return kat
+}
+
+func TestWithRandom(t *testing.T) {
+	if _, err := NewLargeUniverseGPSW06(WithRandom(nil)); err != ErrNilRandom {
+		t.Errorf("Nil source of randomness accepted: %v", err)
+	}
+
+	a, _ := json.Marshal(deriveKnownAnswers(t, "seed"))
+	b, _ := json.Marshal(deriveKnownAnswers(t, "seed"))
+	c, _ := json.Marshal(deriveKnownAnswers(t, "other seed"))
+
+	if string(a) != string(b) {
+		t.Errorf("Same seed derived different keys or ciphertexts.")
+	}
+	if string(a) == string(c) {
+		t.Errorf("Different seeds derived the same keys and ciphertexts.")
+	}
+}
+
+func TestWithRandom_Failing(t *testing.T) {
+	algo, _ := NewLargeUniverseGPSW06()
+	pk, msk, _ := algo.Setup()
+	tree, _ := algo.ParsePolicy("a OR b")
+	dk, _ := algo.KeyGen(tree, msk)
+	narrower, _ := algo.ParsePolicy("a AND b")
+	labels := map[string]struct{}{"a": {}}
+
+	// Failures of the source of randomness are returned rather than panicking
+	errRead := errors.New("read failed")
+	failing, _ := NewLargeUniverseGPSW06(WithRandom(iotest.ErrReader(errRead)))
+
+	if _, _, err := failing.Setup(); err != errRead {
+		t.Errorf("Setup returned %v, expecting %v", err, errRead)
+	}
+	if _, err := failing.KeyGen(tree, msk); err != errRead {
+		t.Errorf("KeyGen returned %v, expecting %v", err, errRead)
+	}
+	if _, err := failing.Delegate(dk, narrower); err != errRead {
+		t.Errorf("Delegate returned %v, expecting %v", err, errRead)
+	}
+	if _, err := failing.EncryptLabels(algo.NewMessage().Rand(), labels, pk); err != errRead {
+		t.Errorf("EncryptLabels returned %v, expecting %v", err, errRead)
+	}
+	if _, err := failing.EncryptBytesLabels([]byte("plaintext"), labels, pk); err != errRead {
+		t.Errorf("EncryptBytesLabels returned %v, expecting %v", err, errRead)
+	}
+}
+
+// TestKnownAnswers compares against the vectors in testdata, which depend on the
+// curve arithmetic of the PBC library and are written by running the test with
+// -update.
+func TestKnownAnswers(t *testing.T) {
+	path := filepath.Join("testdata", "kat.json")
+	kat := deriveKnownAnswers(t, "gpsw06 known answers")
+
+	if *update {
+		data, err := json.MarshalIndent(kat, "", "\t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("No known-answer vectors in %s, write them with -update against an installed PBC.", path)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &knownAnswers{}
+	if err := json.Unmarshal(data, expected); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []struct {
+		name     string
+		got, exp string
+	}{
+		{"public key", kat.PublicKey, expected.PublicKey},
+		{"master key", kat.MasterKey, expected.MasterKey},
+		{"decryption key", kat.DecryptKey, expected.DecryptKey},
+		{"delegated key", kat.Delegated, expected.Delegated},
+		{"message", string(kat.Message), string(expected.Message)},
+		{"ciphertext", kat.Ciphertext, expected.Ciphertext},
+	} {
+		if field.got != field.exp {
+			t.Errorf("Known-answer %s differs.", field.name)
+		}
+	}
+}
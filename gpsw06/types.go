@@ -3,6 +3,8 @@ package gpsw06
 import (
 	"encoding/base64"
 	"encoding/json"
+	"io"
+	"sync"
 
 	"github.com/Nik-U/pbc"
 )
@@ -17,73 +19,116 @@ type PublicKey struct {
 	// contains filtered or unexported fields
 	t []*G2
 	y *GT
+
+	params *Params    // parameters the elements belong to, if known
+	raw    *publicKey // encoding the key was decoded from, if any
 }
 
 type publicKey struct {
-	KeyType string   `json:"type"`
-	T       [][]byte `json:"t"`
-	Y       []byte   `json:"y"`
+	KeyType     string   `json:"type"`
+	T           [][]byte `json:"t"`
+	Y           []byte   `json:"y"`
+	Fingerprint []byte   `json:"fingerprint,omitempty"`
 }
 
 type DecryptKey struct {
 	// contains filtered or unexported fields
-	d    map[int]*G1
+	d    map[attrKey]*G1
 	tree []byte
+	r    map[attrKey]*G2 // only in large universe
+
+	params *Params
+	raw    *decryptKey
 }
 
+// decryptKey holds the points of attributes keyed by attrKey.String, which are
+// labels exactly for keys of the large universe, those holding R.
 type decryptKey struct {
-	KeyType string         `json:"type"`
-	D       map[int][]byte `json:"d"`
-	Tree    []byte         `json:"tree"`
+	KeyType     string            `json:"type"`
+	D           map[string][]byte `json:"d"`
+	Tree        []byte            `json:"tree"`
+	R           map[string][]byte `json:"r,omitempty"`
+	Fingerprint []byte            `json:"fingerprint,omitempty"`
 }
 
 type MasterKey struct {
 	// contains filtered or unexported fields
 	t []*Zr
 	y *Zr
+
+	params *Params
+	raw    *masterKey
 }
 
 type masterKey struct {
-	KeyType string   `json:"type"`
-	T       [][]byte `json:"t"`
-	Y       []byte   `json:"y"`
+	KeyType     string   `json:"type"`
+	T           [][]byte `json:"t"`
+	Y           []byte   `json:"y"`
+	Fingerprint []byte   `json:"fingerprint,omitempty"`
 }
 
 type Message struct {
 	// contains filtered or unexported fields
 	m *GT
+
+	params *Params
 }
 
 type Ciphertext struct {
 	// contains filtered or unexported fields
-	attrs    map[int]struct{}
+	attrs    map[attrKey]struct{}
 	encMsg   *GT
-	encAttrs map[int]*G2
+	encAttrs map[attrKey]*G2 // in G1 for large universe
+	e2       *G2
+
+	params *Params
+	raw    *ciphertext
 }
 
+// ciphertext holds the points of attributes keyed by attrKey.String, which are
+// labels exactly for ciphertexts of the large universe, those holding E2.
 type ciphertext struct {
-	Msg   []byte         `json:"msg"`
-	Attrs map[int][]byte `json:"attrs"`
+	Msg         []byte            `json:"msg"`
+	Attrs       map[string][]byte `json:"attrs"`
+	E2          []byte            `json:"e2,omitempty"`
+	Fingerprint []byte            `json:"fingerprint,omitempty"`
 }
 
 type GPSW06 struct {
 	universe []Attribute
+	large    bool
+	random   io.Reader
+	params   *Params
+
+	mu     sync.Mutex
+	yPower *pbc.Power // table of Y of the public key last used by Encrypt
 }
 
+// bindMu serializes the bind methods, which decode keys and ciphertexts shared
+// between goroutines, such as the master key of an authority, again. As binding
+// to the same parameters again does not write, a key may be read once bound.
+var bindMu sync.Mutex
+
 type polynomial struct {
 	c []*Zr
 }
 
-// Marshal converts pk into a byte slice.
+// Marshal converts pk into a byte slice. Keys which were not decoded are
+// written as they were read.
 func (pk *PublicKey) Marshal() ([]byte, error) {
-	t := make([][]byte, 0)
-	for i := range pk.t {
-		t = append(t, pk.t[i].Bytes())
-	}
+	instance := pk.raw
+	if pk.params != nil || instance == nil {
+		t := make([][]byte, 0)
+		for i := range pk.t {
+			t = append(t, pk.t[i].Bytes())
+		}
+
+		var y = pk.y.Bytes()
 
-	var y = pk.y.Bytes()
+		instance = &publicKey{"public", t, y, pk.fingerprint()}
+	}
 
-	str, err := json.Marshal(publicKey{"public", t, y})
+	str, err := json.Marshal(instance)
 	if err != nil {
 		return nil, err
 	}
@@ -104,31 +149,94 @@ func (pk *PublicKey) Unmarshal(b []byte) ([]byte, error) {
 		return nil, err
 	} else if instance.KeyType != "public" {
 		return nil, ErrExpectingPublicKey
+	} else if len(instance.Fingerprint) == 0 {
+		return nil, ErrMissingFingerprint
+	}
+
+	pk.t, pk.y, pk.params, pk.raw = nil, nil, nil, &instance
+	if err := decodeLoaded(instance.Fingerprint, pk.decode); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// decode sets pk to the key of params encoded in pk.raw.
+func (pk *PublicKey) decode(params *Params) error {
+	if err := params.checkFingerprint(pk.raw.Fingerprint); err != nil {
+		return err
 	}
 
 	var y *GT
 
 	t := make([]*G2, 0)
 
-	for i := range instance.T {
-		t = append(t, pairing.NewG2().SetBytes(instance.T[i]))
+	for i := range pk.raw.T {
+		el, err := setPoint(params, params.pairing.NewG2(), pk.raw.T[i])
+		if err != nil {
+			return err
+		}
+		t = append(t, el)
+	}
+	y, err := setElement(params, params.pairing.NewGT(), pk.raw.Y)
+	if err != nil {
+		return err
 	}
-	y = pairing.NewGT().SetBytes(instance.Y)
 
 	pk.t = t
 	pk.y = y
+	pk.params = params
 
-	return b, nil
+	return nil
+}
+
+// fingerprint returns the fingerprint of the parameters of pk, or the one it
+// was decoded with if they are unknown.
+func (pk *PublicKey) fingerprint() []byte {
+	if pk.params != nil {
+		return pk.params.fingerprint
+	} else if pk.raw != nil {
+		return pk.raw.Fingerprint
+	}
+	return nil
+}
+
+// bind makes pk a key of params, decoding it again if it was decoded with other
+// parameters.
+func (pk *PublicKey) bind(params *Params) error {
+	bindMu.Lock()
+	defer bindMu.Unlock()
+
+	if pk.params == params || pk.params == nil && pk.raw == nil {
+		return nil
+	} else if pk.raw == nil {
+		return ErrParamsMismatch
+	}
+	return pk.decode(params)
 }
 
-// Marshal converts dk into a byte slice.
+// Marshal converts dk into a byte slice. Keys which were not decoded are
+// written as they were read.
 func (dk *DecryptKey) Marshal() ([]byte, error) {
-	d := make(map[int][]byte)
-	for k, v := range dk.d {
-		d[k] = v.Bytes()
+	instance := dk.raw
+	if dk.params != nil || instance == nil {
+		d := make(map[string][]byte)
+		for k, v := range dk.d {
+			d[k.String()] = v.Bytes()
+		}
+
+		var r map[string][]byte
+		if dk.r != nil {
+			r = make(map[string][]byte)
+			for k, v := range dk.r {
+				r[k.String()] = v.Bytes()
+			}
+		}
+
+		instance = &decryptKey{"private", d, dk.tree, r, dk.fingerprint()}
 	}
 
-	str, err := json.Marshal(decryptKey{"private", d, dk.tree})
+	str, err := json.Marshal(instance)
 	if err != nil {
 		return nil, err
 	}
@@ -149,30 +257,103 @@ func (dk *DecryptKey) Unmarshal(b []byte) ([]byte, error) {
 		return nil, err
 	} else if instance.KeyType != "private" {
 		return nil, ErrExpectingPrivateKey
+	} else if len(instance.Fingerprint) == 0 {
+		return nil, ErrMissingFingerprint
+	}
+
+	dk.d, dk.tree, dk.r, dk.params, dk.raw = nil, instance.Tree, nil, nil, &instance
+	if err := decodeLoaded(instance.Fingerprint, dk.decode); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// decode sets dk to the key of params encoded in dk.raw.
+func (dk *DecryptKey) decode(params *Params) error {
+	if err := params.checkFingerprint(dk.raw.Fingerprint); err != nil {
+		return err
 	}
 
-	d := make(map[int]*G1)
+	large := dk.raw.R != nil
+	d := make(map[attrKey]*G1)
+
+	for k, v := range dk.raw.D {
+		key, err := parseKey(k, large)
+		if err != nil {
+			return err
+		}
+		el, err := setPoint(params, params.pairing.NewG1(), v)
+		if err != nil {
+			return err
+		}
+		d[key] = el
+	}
 
-	for k, v := range instance.D {
-		d[k] = pairing.NewG1().SetBytes(v)
+	var r map[attrKey]*G2
+	if large {
+		r = make(map[attrKey]*G2)
+		for k, v := range dk.raw.R {
+			key, err := parseKey(k, large)
+			if err != nil {
+				return err
+			}
+			el, err := setPoint(params, params.pairing.NewG2(), v)
+			if err != nil {
+				return err
+			}
+			r[key] = el
+		}
 	}
 
 	dk.d = d
-	dk.tree = instance.Tree
+	dk.r = r
+	dk.params = params
 
-	return b, nil
+	return nil
 }
 
-// Marshal converts msk into a byte slice.
-func (msk *MasterKey) Marshal() ([]byte, error) {
-	t := make([][]byte, 0)
-	for i := range msk.t {
-		t = append(t, msk.t[i].Bytes())
+// fingerprint returns the fingerprint of the parameters of dk, or the one it
+// was decoded with if they are unknown.
+func (dk *DecryptKey) fingerprint() []byte {
+	if dk.params != nil {
+		return dk.params.fingerprint
+	} else if dk.raw != nil {
+		return dk.raw.Fingerprint
+	}
+	return nil
+}
+
+// bind makes dk a key of params, decoding it again if it was decoded with other
+// parameters.
+func (dk *DecryptKey) bind(params *Params) error {
+	bindMu.Lock()
+	defer bindMu.Unlock()
+
+	if dk.params == params || dk.params == nil && dk.raw == nil {
+		return nil
+	} else if dk.raw == nil {
+		return ErrParamsMismatch
 	}
+	return dk.decode(params)
+}
 
-	var y = msk.y.Bytes()
+// Marshal converts msk into a byte slice. Keys which were not decoded are
+// written as they were read.
+func (msk *MasterKey) Marshal() ([]byte, error) {
+	instance := msk.raw
+	if msk.params != nil || instance == nil {
+		t := make([][]byte, 0)
+		for i := range msk.t {
+			t = append(t, msk.t[i].Bytes())
+		}
 
-	str, err := json.Marshal(publicKey{"master", t, y})
+		var y = msk.y.Bytes()
+
+		instance = &masterKey{"master", t, y, msk.fingerprint()}
+	}
+
+	str, err := json.Marshal(instance)
 	if err != nil {
 		return nil, err
 	}
@@ -193,26 +374,84 @@ func (msk *MasterKey) Unmarshal(b []byte) ([]byte, error) {
 		return nil, err
 	} else if instance.KeyType != "master" {
 		return nil, ErrExpectingMasterKey
+	} else if len(instance.Fingerprint) == 0 {
+		return nil, ErrMissingFingerprint
+	}
+
+	msk.t, msk.y, msk.params, msk.raw = nil, nil, nil, &instance
+	if err := decodeLoaded(instance.Fingerprint, msk.decode); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// decode sets msk to the key of params encoded in msk.raw.
+func (msk *MasterKey) decode(params *Params) error {
+	if err := params.checkFingerprint(msk.raw.Fingerprint); err != nil {
+		return err
 	}
 
 	var y *Zr
 	t := make([]*Zr, 0)
 
-	for i := range instance.T {
-		t = append(t, pairing.NewZr().SetBytes(instance.T[i]))
+	for i := range msk.raw.T {
+		el, err := setScalar(params.pairing.NewZr(), msk.raw.T[i])
+		if err != nil {
+			return err
+		}
+		t = append(t, el)
+	}
+	y, err := setScalar(params.pairing.NewZr(), msk.raw.Y)
+	if err != nil {
+		return err
 	}
-	y = pairing.NewZr().SetBytes(instance.Y)
 
 	msk.t = t
 	msk.y = y
+	msk.params = params
 
-	return b, nil
+	return nil
 }
 
-// NewMessage creates an empty Message.
+// fingerprint returns the fingerprint of the parameters of msk, or the one it
+// was decoded with if they are unknown.
+func (msk *MasterKey) fingerprint() []byte {
+	if msk.params != nil {
+		return msk.params.fingerprint
+	} else if msk.raw != nil {
+		return msk.raw.Fingerprint
+	}
+	return nil
+}
+
+// bind makes msk a key of params, decoding it again if it was decoded with other
+// parameters.
+func (msk *MasterKey) bind(params *Params) error {
+	bindMu.Lock()
+	defer bindMu.Unlock()
+
+	if msk.params == params || msk.params == nil && msk.raw == nil {
+		return nil
+	} else if msk.raw == nil {
+		return ErrParamsMismatch
+	}
+	return msk.decode(params)
+}
+
+// NewMessage creates an empty Message of the default parameters.
 func NewMessage() *Message {
 	return &Message{
-		pairing.NewGT(),
+		defaultParams.pairing.NewGT(),
+		defaultParams,
+	}
+}
+
+// NewMessage creates an empty Message of the parameters of algo.
+func (algo *GPSW06) NewMessage() *Message {
+	return &Message{
+		algo.params.pairing.NewGT(),
+		algo.params,
 	}
 }
 
@@ -228,20 +467,40 @@ func (msg *Message) Marshal() []byte {
 }
 
 // Unmarshal sets msg to the result of converting the output of Marshal back into
-// a group element and then returns msg.
+// a group element and then returns msg. Encodings of the wrong length or not in
+// canonical form are rejected, and so are elements outside the subgroup of order
+// r, leaving msg unchanged.
 func (msg *Message) Unmarshal(b []byte) ([]byte, error) {
-	return msg.m.SetBytes(b).Bytes(), nil
+	el, err := setBytes(msg.m.NewFieldElement(), b)
+	if err != nil {
+		return nil, err
+	} else if !el.NewFieldElement().PowBig(el, order(el.Pairing())).Is1() {
+		return nil, ErrNotInSubgroup
+	}
+
+	return msg.m.Set(el).Bytes(), nil
 }
 
-// Marshal converts ct into a byte slice.
+// Marshal converts ct into a byte slice. Ciphertexts which were not decoded are
+// written as they were read.
 func (ct *Ciphertext) Marshal() ([]byte, error) {
-	m := ct.encMsg.Bytes()
-	a := make(map[int][]byte)
-	for k, v := range ct.encAttrs {
-		a[k] = v.Bytes()
+	instance := ct.raw
+	if ct.params != nil || instance == nil {
+		m := ct.encMsg.Bytes()
+		a := make(map[string][]byte)
+		for k, v := range ct.encAttrs {
+			a[k.String()] = v.Bytes()
+		}
+
+		var e2 []byte
+		if ct.e2 != nil {
+			e2 = ct.e2.Bytes()
+		}
+
+		instance = &ciphertext{m, a, e2, ct.fingerprint()}
 	}
 
-	str, err := json.Marshal(ciphertext{m, a})
+	str, err := json.Marshal(instance)
 	if err != nil {
 		return nil, err
 	}
@@ -260,22 +519,110 @@ func (ct *Ciphertext) Unmarshal(b []byte) ([]byte, error) {
 	var instance = ciphertext{}
 	if err := json.Unmarshal([]byte(str), &instance); err != nil {
 		return nil, err
+	} else if len(instance.Fingerprint) == 0 {
+		return nil, ErrMissingFingerprint
 	}
 
-	m := pairing.NewGT().SetBytes(instance.Msg)
-	a := make(map[int]struct{})
-	encAttrs := make(map[int]*G2)
+	a, err := instance.attrs()
+	if err != nil {
+		return nil, err
+	}
 
-	for k, v := range instance.Attrs {
-		a[k] = struct{}{}
-		encAttrs[k] = pairing.NewG2().SetBytes(v)
+	ct.attrs, ct.encMsg, ct.encAttrs, ct.e2, ct.params, ct.raw = a, nil, nil, nil, nil, &instance
+	if err := decodeLoaded(instance.Fingerprint, ct.decode); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// decode sets ct to the ciphertext of params encoded in ct.raw.
+func (ct *Ciphertext) decode(params *Params) error {
+	if err := params.checkFingerprint(ct.raw.Fingerprint); err != nil {
+		return err
+	}
+
+	m, err := setElement(params, params.pairing.NewGT(), ct.raw.Msg)
+	if err != nil {
+		return err
+	}
+
+	encAttrs := make(map[attrKey]*G2)
+
+	var e2 *G2
+	if ct.raw.E2 != nil {
+		if e2, err = setPoint(params, params.pairing.NewG2(), ct.raw.E2); err != nil {
+			return err
+		}
+	}
+
+	for k, v := range ct.raw.Attrs {
+		key, err := parseKey(k, e2 != nil)
+		if err != nil {
+			return err
+		}
+		el := params.pairing.NewG2()
+		if e2 != nil {
+			el = params.pairing.NewG1()
+		}
+		if encAttrs[key], err = setPoint(params, el, v); err != nil {
+			return err
+		}
 	}
 
-	ct.attrs = a
 	ct.encMsg = m
 	ct.encAttrs = encAttrs
+	ct.e2 = e2
+	ct.params = params
 
-	return b, nil
+	return nil
+}
+
+// attrs returns the attributes of c, which are labels of the large universe if
+// c holds E2.
+func (c *ciphertext) attrs() (map[attrKey]struct{}, error) {
+	a := make(map[attrKey]struct{})
+	for k := range c.Attrs {
+		key, err := parseKey(k, c.E2 != nil)
+		if err != nil {
+			return nil, err
+		}
+		a[key] = struct{}{}
+	}
+	return a, nil
+}
+
+// fingerprint returns the fingerprint of the parameters of ct, or the one it
+// was decoded with if they are unknown.
+func (ct *Ciphertext) fingerprint() []byte {
+	if ct.params != nil {
+		return ct.params.fingerprint
+	} else if ct.raw != nil {
+		return ct.raw.Fingerprint
+	}
+	return nil
+}
+
+// bind makes ct a ciphertext of params, decoding it again if it was decoded with
+// other parameters.
+func (ct *Ciphertext) bind(params *Params) error {
+	bindMu.Lock()
+	defer bindMu.Unlock()
+
+	if ct.params == params || ct.params == nil && ct.raw == nil {
+		return nil
+	} else if ct.raw == nil {
+		return ErrParamsMismatch
+	}
+	return ct.decode(params)
+}
+
+// bind checks that msg is a message of params.
+func (msg *Message) bind(params *Params) error {
+	if msg.params != nil && msg.params != params {
+		return ErrParamsMismatch
+	}
+	return nil
 }
 
 func newPolynomial(deg int) *polynomial {
@@ -283,12 +630,12 @@ func newPolynomial(deg int) *polynomial {
 }
 
 func (p *polynomial) evaluate(x *Zr) *Zr {
-	output := pairing.NewZr()
-	temp := pairing.NewZr().Set1()
+	output := x.NewFieldElement()
+	temp := x.NewFieldElement().Set1()
 	for i, c := range p.c {
 		temp.Set1()
 		if i != 0 {
-			temp.PowZn(x, pairing.NewZr().SetInt32(int32(i)))
+			temp.PowZn(x, x.NewFieldElement().SetInt32(int32(i)))
 		}
 		temp.Mul(temp, c)
 		output.Add(output, temp)
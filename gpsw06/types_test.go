@@ -64,7 +64,7 @@ func TestPublicKey_Marshal(t *testing.T) {
 		pairing.NewG2().Rand(),
 		pairing.NewG2().Rand(),
 		pairing.NewG2().Rand(),
-	}, pairing.NewGT().Rand()}
+	}, pairing.NewGT().Rand(), defaultParams, nil}
 
 	pkStr, err := pk.Marshal()
 	if err != nil {
@@ -110,7 +110,7 @@ func TestMasterKey_Marshal(t *testing.T) {
 		pairing.NewZr().Rand(),
 		pairing.NewZr().Rand(),
 		pairing.NewZr().Rand(),
-	}, pairing.NewZr().Rand()}
+	}, pairing.NewZr().Rand(), defaultParams, nil}
 
 	mskStr, err := msk.Marshal()
 	if err != nil {
@@ -142,18 +142,18 @@ func TestMasterKey_Marshal(t *testing.T) {
 }
 
 func TestDecryptKey_Marshal(t *testing.T) {
-	d := make(map[int]*G1)
-	d[1] = pairing.NewG1().Rand()
-	d[3] = pairing.NewG1().Rand()
-	d[30] = pairing.NewG1().Rand()
-	d[302] = pairing.NewG1().Rand()
-	d[61303] = pairing.NewG1().Rand()
-	d[94613] = pairing.NewG1().Rand()
-	d[111113] = pairing.NewG1().Rand()
-	d[42584] = pairing.NewG1().Rand()
-	d[354] = pairing.NewG1().Rand()
-
-	dk := DecryptKey{d, []byte("tree")}
+	d := make(map[attrKey]*G1)
+	d[attrKey{1, ""}] = pairing.NewG1().Rand()
+	d[attrKey{3, ""}] = pairing.NewG1().Rand()
+	d[attrKey{30, ""}] = pairing.NewG1().Rand()
+	d[attrKey{302, ""}] = pairing.NewG1().Rand()
+	d[attrKey{61303, ""}] = pairing.NewG1().Rand()
+	d[attrKey{94613, ""}] = pairing.NewG1().Rand()
+	d[attrKey{111113, ""}] = pairing.NewG1().Rand()
+	d[attrKey{42584, ""}] = pairing.NewG1().Rand()
+	d[attrKey{354, ""}] = pairing.NewG1().Rand()
+
+	dk := DecryptKey{d, []byte("tree"), nil, defaultParams, nil}
 
 	dkStr, err := dk.Marshal()
 	if err != nil {
@@ -185,15 +185,15 @@ func TestDecryptKey_Marshal(t *testing.T) {
 }
 
 func TestCiphertext_Marshal(t *testing.T) {
-	a := make(map[int]struct{})
-	ea := make(map[int]*G2)
+	a := make(map[attrKey]struct{})
+	ea := make(map[attrKey]*G2)
 
 	for i := range []int{1, 2, 6, 9, 11, 99, 1654, 889416, 654981} {
-		a[i] = struct{}{}
-		ea[i] = pairing.NewG2().Rand()
+		a[attrKey{i, ""}] = struct{}{}
+		ea[attrKey{i, ""}] = pairing.NewG2().Rand()
 	}
 
-	ct := Ciphertext{a, pairing.NewGT().Rand(), ea}
+	ct := Ciphertext{a, pairing.NewGT().Rand(), ea, nil, defaultParams, nil}
 
 	ctStr, err := ct.Marshal()
 	if err != nil {
@@ -236,3 +236,38 @@ func TestCiphertext_Marshal(t *testing.T) {
 		t.Errorf("Ciphertext encAttrs value not match")
 	}
 }
+
+func TestMessage_Unmarshal(t *testing.T) {
+	msg := NewMessage().Rand()
+	data := msg.Marshal()
+
+	msg2 := NewMessage()
+	if _, err := msg2.Unmarshal(data); err != nil {
+		t.Errorf("Error occurred during unmarshalling message: %v", err)
+	} else if !msg.m.Equals(msg2.m) {
+		t.Errorf("Message value not match")
+	}
+
+	overflow := make([]byte, len(data))
+	for i := range overflow {
+		overflow[i] = 0xff
+	}
+
+	for _, v := range []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"short", data[1:], ErrElementLength},
+		{"long", append(append([]byte(nil), data...), 0), ErrElementLength},
+		{"empty", nil, ErrElementLength},
+		{"overflowing", overflow, ErrNonCanonical},
+	} {
+		if _, err := msg2.Unmarshal(v.data); err != v.err {
+			t.Errorf("Message %s unmarshaled with %v, expecting %v", v.name, err, v.err)
+		}
+	}
+	if !msg.m.Equals(msg2.m) {
+		t.Errorf("Malformed message changed the message")
+	}
+}
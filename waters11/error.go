@@ -0,0 +1,9 @@
+package waters11
+
+import "errors"
+
+var (
+	ErrBadCiphertext    = errors.New("ciphertext components do not match its policy")
+	ErrUnknownNodeType  = errors.New("unknown node type")
+	ErrTreeNotSatisfied = errors.New("ciphertext does not Satisfy decryption key policy")
+)
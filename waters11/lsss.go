@@ -0,0 +1,188 @@
+package waters11
+
+import (
+	"math/big"
+
+	"ABE/bsw07"
+	"github.com/Nik-U/pbc"
+)
+
+// LSSS is a linear secret sharing scheme realizing an access structure. Row i of
+// Matrix is labelled by attribute Rho[i], and a secret s is shared as
+// lambda = Matrix * (s, y_2, ..., y_n).
+type LSSS struct {
+	Matrix [][]*big.Int `json:"m"`
+	Rho    []string     `json:"rho"`
+}
+
+// NewLSSS converts an access structure tree into an LSSS matrix. Each gate with
+// threshold k shares the value of its row with a polynomial of degree k-1 and
+// gets one new column for each of the random coefficients, so that the rows are
+// the polynomial shares of BSW07 written as vectors.
+func NewLSSS(tree bsw07.Node) (*LSSS, error) {
+	lsss := &LSSS{}
+	columns := 1
+
+	var convert func(x bsw07.Node, v []*big.Int) error
+	convert = func(x bsw07.Node, v []*big.Int) error {
+		if attr, ok := bsw07.AttributeOf(x); ok {
+			lsss.Matrix = append(lsss.Matrix, v)
+			lsss.Rho = append(lsss.Rho, string(attr))
+			return nil
+		}
+
+		children := bsw07.ChildrenOf(x)
+		if len(children) == 0 {
+			return ErrUnknownNodeType
+		}
+
+		// Columns [first, last) belong to the coefficients of this gate
+		first := columns
+		columns += x.Threshold() - 1
+		last := columns
+
+		for _, child := range children {
+			w := make([]*big.Int, last)
+			copy(w, v)
+			for j := len(v); j < first; j++ {
+				w[j] = new(big.Int)
+			}
+
+			// Row of child is v + sum_j index^j e_{first+j}
+			index := big.NewInt(int64(child.Index()))
+			power := big.NewInt(1)
+			for j := first; j < last; j++ {
+				power = new(big.Int).Mul(power, index)
+				w[j] = power
+			}
+
+			if err := convert(child, w); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := convert(tree, []*big.Int{big.NewInt(1)}); err != nil {
+		return nil, err
+	}
+
+	// Pad rows created before the last columns were added
+	for i, row := range lsss.Matrix {
+		for len(row) < columns {
+			row = append(row, new(big.Int))
+		}
+		lsss.Matrix[i] = row
+	}
+
+	return lsss, nil
+}
+
+// wellFormed reports whether the rows of lsss are labelled, of equal nonzero
+// length and free of missing entries, as a decoded policy may be anything.
+func (lsss *LSSS) wellFormed() bool {
+	if len(lsss.Matrix) == 0 || len(lsss.Matrix) != len(lsss.Rho) || len(lsss.Matrix[0]) == 0 {
+		return false
+	}
+	for _, row := range lsss.Matrix {
+		if len(row) != len(lsss.Matrix[0]) {
+			return false
+		}
+		for _, m := range row {
+			if m == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// shares computes lambda_i = M_i * v for each row i.
+func (lsss *LSSS) shares(v []*pbc.Element) []*pbc.Element {
+	lambda := make([]*pbc.Element, len(lsss.Matrix))
+	temp := v[0].NewFieldElement()
+	for i, row := range lsss.Matrix {
+		lambda[i] = v[0].NewFieldElement().Set0()
+		for j, m := range row {
+			temp.MulBig(v[j], m)
+			lambda[i].Add(lambda[i], temp)
+		}
+	}
+	return lambda
+}
+
+// coefficients finds constants omega_i such that sum omega_i M_i = (1, 0, ..., 0)
+// over the rows i whose attribute is in attrs, by Gaussian elimination over Zr.
+// The returned map is keyed by row.
+func (lsss *LSSS) coefficients(pairing *pbc.Pairing, attrs map[string]struct{}) (map[int]*pbc.Element, error) {
+	var rows []int
+	for i, attr := range lsss.Rho {
+		if _, ok := attrs[attr]; ok {
+			rows = append(rows, i)
+		}
+	}
+
+	if len(rows) == 0 || len(lsss.Matrix) == 0 {
+		return nil, ErrTreeNotSatisfied
+	}
+
+	// Augmented system (M_rows)^T omega = e_1, one equation per column
+	columns := len(lsss.Matrix[0])
+	a := make([][]*pbc.Element, columns)
+	for c := range a {
+		a[c] = make([]*pbc.Element, len(rows)+1)
+		for j, row := range rows {
+			a[c][j] = pairing.NewZr().SetBig(lsss.Matrix[row][c])
+		}
+		a[c][len(rows)] = pairing.NewZr().Set0()
+	}
+	a[0][len(rows)].Set1()
+
+	var pivots []int
+	temp := pairing.NewZr()
+	for col, r := 0, 0; col < len(rows) && r < columns; col++ {
+		p := r
+		for p < columns && a[p][col].Is0() {
+			p++
+		}
+		if p == columns {
+			continue
+		}
+		a[r], a[p] = a[p], a[r]
+
+		// Normalize pivot row and eliminate col from every other row
+		inv := pairing.NewZr().Invert(a[r][col])
+		for j := col; j <= len(rows); j++ {
+			a[r][j].Mul(a[r][j], inv)
+		}
+		for i := range a {
+			if i == r || a[i][col].Is0() {
+				continue
+			}
+			factor := pairing.NewZr().Set(a[i][col])
+			for j := col; j <= len(rows); j++ {
+				a[i][j].Sub(a[i][j], temp.Mul(factor, a[r][j]))
+			}
+		}
+
+		pivots = append(pivots, col)
+		r++
+	}
+
+	// The system is consistent only if every remaining equation reads 0 = 0
+	for i := len(pivots); i < columns; i++ {
+		if !a[i][len(rows)].Is0() {
+			return nil, ErrTreeNotSatisfied
+		}
+	}
+
+	omega := make(map[int]*pbc.Element)
+	for i, col := range pivots {
+		if !a[i][len(rows)].Is0() {
+			omega[rows[col]] = a[i][len(rows)]
+		}
+	}
+
+	return omega, nil
+}
@@ -0,0 +1,95 @@
+package waters11
+
+import (
+	"testing"
+
+	"ABE/bsw07"
+)
+
+func TestNewLSSS(t *testing.T) {
+	tree, err := bsw07.ParsePolicy("a AND (b OR c)")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	lsss, err := NewLSSS(tree)
+	if err != nil {
+		t.Errorf("Error (%v) during converting tree.", err)
+		return
+	}
+
+	expected := [][]int64{{1, 1}, {1, 2}, {1, 2}}
+	if len(lsss.Matrix) != len(expected) {
+		t.Errorf("LSSS has wrong number of rows: %v", lsss.Matrix)
+		return
+	}
+	for i, row := range expected {
+		for j, v := range row {
+			if lsss.Matrix[i][j].Int64() != v {
+				t.Errorf("LSSS has wrong row %d: %v", i, lsss.Matrix[i])
+			}
+		}
+	}
+
+	for i, attr := range []string{"a", "b", "c"} {
+		if lsss.Rho[i] != attr {
+			t.Errorf("LSSS has wrong labels: %v", lsss.Rho)
+		}
+	}
+}
+
+func TestLSSS_Coefficients(t *testing.T) {
+	params, _ := bsw07.DefaultParams()
+	pairing := params.Pairing()
+
+	for _, policy := range []string{
+		"a",
+		"a OR (b AND 2 of (c, d, e))",
+		"3 of (a, b, c, d) AND (e OR a)",
+		"a AND a AND b",
+	} {
+		tree, err := bsw07.ParsePolicy(policy)
+		if err != nil {
+			t.Errorf("Error (%v) during parsing policy %q.", err, policy)
+			continue
+		}
+		lsss, err := NewLSSS(tree)
+		if err != nil {
+			t.Errorf("Error (%v) during converting policy %q.", err, policy)
+			continue
+		}
+
+		for _, attrs := range []map[string]struct{}{
+			{"a": {}},
+			{"a": {}, "b": {}},
+			{"b": {}, "c": {}, "d": {}},
+			{"a": {}, "c": {}, "d": {}},
+			{"b": {}, "c": {}, "d": {}, "e": {}},
+		} {
+			omega, err := lsss.coefficients(pairing.P, attrs)
+			if tree.Satisfy(attrs) != (err == nil) {
+				t.Errorf("Policy %q evaluated wrongly for %v: %v", policy, attrs, err)
+				continue
+			} else if err != nil {
+				continue
+			}
+
+			// sum omega_i M_i must equal (1, 0, ..., 0)
+			for j := range lsss.Matrix[0] {
+				sum := pairing.P.NewZr().Set0()
+				for i, w := range omega {
+					sum.Add(sum, pairing.P.NewZr().MulBig(w, lsss.Matrix[i][j]))
+				}
+
+				expected := pairing.P.NewZr().Set0()
+				if j == 0 {
+					expected.Set1()
+				}
+				if !sum.Equals(expected) {
+					t.Errorf("Coefficients of policy %q for %v do not reconstruct the secret", policy, attrs)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package waters11
+
+import (
+	cryptorand "crypto/rand"
+	"io"
+
+	"ABE/bsw07"
+)
+
+// WithRandom sets the source of randomness of Waters11 to r, which defaults to
+// crypto/rand.Reader. Every secret of Setup, KeyGen and Encrypt is read from r,
+// so that a deterministic r reproduces keys and ciphertexts, which is only meant
+// for tests.
+func WithRandom(r io.Reader) Option {
+	return func(algo *Waters11) error {
+		if r == nil {
+			return bsw07.ErrNilRandom
+		}
+		algo.random = r
+		return nil
+	}
+}
+
+// randZr sets z to a uniformly random element of Zr read from the source of
+// randomness of algo.
+func (algo *Waters11) randZr(z *Zr) error {
+	x, err := cryptorand.Int(algo.random, algo.params.Order())
+	if err != nil {
+		return err
+	}
+	z.E.SetBig(x)
+	return nil
+}
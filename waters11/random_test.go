@@ -0,0 +1,71 @@
+package waters11
+
+import (
+	"encoding/json"
+	"errors"
+	mathrand "math/rand"
+	"testing"
+	"testing/iotest"
+
+	"ABE/bsw07"
+)
+
+func TestWithRandom(t *testing.T) {
+	if _, err := NewWaters11(WithRandom(nil)); err != bsw07.ErrNilRandom {
+		t.Errorf("Nil source of randomness accepted: %v", err)
+	}
+
+	tree, _ := bsw07.ParsePolicy("a AND (b OR c)")
+	derive := func(seed int64) []byte {
+		algo, err := NewWaters11(WithRandom(mathrand.New(mathrand.NewSource(seed))))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pk, msk, err := algo.Setup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dk, err := algo.KeyGen(msk, map[string]struct{}{"a": {}, "b": {}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := algo.NewMessage()
+		msg.M.E.Set1()
+		ct, err := algo.Encrypt(pk, msg, tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := json.Marshal([]interface{}{pk, msk, dk, ct})
+		return data
+	}
+
+	a, b, c := derive(1), derive(1), derive(2)
+	if string(a) != string(b) {
+		t.Errorf("Same seed derived different keys or ciphertexts.")
+	}
+	if string(a) == string(c) {
+		t.Errorf("Different seeds derived the same keys and ciphertexts.")
+	}
+}
+
+func TestWithRandom_Failing(t *testing.T) {
+	algo, _ := NewWaters11()
+	pk, msk, _ := algo.Setup()
+	tree, _ := bsw07.ParsePolicy("a")
+
+	// Failures of the source of randomness are returned rather than panicking
+	errRead := errors.New("read failed")
+	failing, _ := NewWaters11(WithRandom(iotest.ErrReader(errRead)))
+
+	if _, _, err := failing.Setup(); err != errRead {
+		t.Errorf("Setup returned %v, expecting %v", err, errRead)
+	}
+	if _, err := failing.KeyGen(msk, map[string]struct{}{"a": {}}); err != errRead {
+		t.Errorf("KeyGen returned %v, expecting %v", err, errRead)
+	}
+	if _, err := failing.Encrypt(pk, NewMessage().Rand(), tree); err != errRead {
+		t.Errorf("Encrypt returned %v, expecting %v", err, errRead)
+	}
+}
@@ -0,0 +1,110 @@
+package waters11
+
+import (
+	"io"
+
+	"ABE/bsw07"
+	"github.com/Nik-U/pbc"
+)
+
+type G = bsw07.G
+type GT = bsw07.GT
+type Zr = bsw07.Zr
+type Message = bsw07.Message
+
+type PublicKey struct {
+	KeyType string `json:"type"`
+	GA      *G     `json:"ga"`
+	E       *GT    `json:"e"`
+}
+
+func NewPublicKey(ga *G, e *GT) *PublicKey {
+	return &PublicKey{
+		KeyType: "public",
+		GA:      ga,
+		E:       e,
+	}
+}
+
+type DecryptKey struct {
+	KeyType string              `json:"type"`
+	S       map[string]struct{} `json:"s"`
+	K       *G                  `json:"k"`
+	L       *G                  `json:"l"`
+	KX      map[string]*G       `json:"kx"`
+}
+
+func NewDecryptKey(s map[string]struct{}, k, l *G, kx map[string]*G) *DecryptKey {
+	return &DecryptKey{
+		KeyType: "private",
+		S:       s,
+		K:       k,
+		L:       l,
+		KX:      kx,
+	}
+}
+
+type MasterKey struct {
+	KeyType string `json:"type"`
+	GAlpha  *G     `json:"g_alpha"`
+	GA      *G     `json:"ga"`
+}
+
+func NewMasterKey(gAlpha, ga *G) *MasterKey {
+	return &MasterKey{
+		KeyType: "master",
+		GAlpha:  gAlpha,
+		GA:      ga,
+	}
+}
+
+// Ciphertext holds the components C_i, D_i of each row i of the policy.
+type Ciphertext struct {
+	Policy *LSSS `json:"policy"`
+	Msg    *GT   `json:"msg"`
+	C      *G    `json:"c"`
+	Ci     []*G  `json:"ci"`
+	Di     []*G  `json:"di"`
+}
+
+func NewCiphertext(policy *LSSS, msg *GT, c *G, ci, di []*G) *Ciphertext {
+	return &Ciphertext{
+		Policy: policy,
+		Msg:    msg,
+		C:      c,
+		Ci:     ci,
+		Di:     di,
+	}
+}
+
+type Waters11 struct {
+	params *bsw07.Params
+	e      *pbc.Element // e(g, g) to reduce redundant calculation
+	random io.Reader    // source of randomness of all secrets
+}
+
+// NewMessage creates an empty Message of the default parameters.
+func NewMessage() *Message {
+	return bsw07.NewMessage()
+}
+
+// NewMessage creates an empty Message of the parameters of algo.
+func (algo *Waters11) NewMessage() *Message {
+	return &Message{M: algo.params.Pairing().NewGT()}
+}
+
+// elements lists the elements of G of dk.
+func (dk *DecryptKey) elements() []*bsw07.Element {
+	els := []*bsw07.Element{dk.K, dk.L}
+	for _, el := range dk.KX {
+		els = append(els, el)
+	}
+	return els
+}
+
+// elements lists the elements of G of ct, which leaves out Msg.
+func (ct *Ciphertext) elements() []*bsw07.Element {
+	els := []*bsw07.Element{ct.C}
+	els = append(els, ct.Ci...)
+	return append(els, ct.Di...)
+}
@@ -0,0 +1,241 @@
+package waters11
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+
+	"ABE/bsw07"
+	"github.com/Nik-U/pbc"
+)
+
+func hash(data []byte) []byte {
+	h := sha256.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Option configures a Waters11 instantiated by NewWaters11.
+type Option func(*Waters11) error
+
+// WithParams sets the pairing parameters of Waters11 to params.
+func WithParams(params *bsw07.Params) Option {
+	return func(algo *Waters11) error {
+		if params == nil {
+			return bsw07.ErrNilParams
+		}
+		algo.params = params
+		return nil
+	}
+}
+
+// NewWaters11 instantiates a Waters11 configured by opts
+func NewWaters11(opts ...Option) (*Waters11, error) {
+	pbc.SetCryptoRandom()
+
+	algo := &Waters11{random: cryptorand.Reader}
+	for _, opt := range opts {
+		if err := opt(algo); err != nil {
+			return nil, err
+		}
+	}
+
+	if algo.params == nil {
+		params, err := bsw07.DefaultParams()
+		if err != nil {
+			return nil, err
+		}
+		algo.params = params
+	}
+
+	g := algo.params.Generator()
+	algo.e = algo.params.Pairing().P.NewGT().Pair(g.E, g.E)
+
+	return algo, nil
+}
+
+// Setup outputs a public key and a master key.
+func (algo *Waters11) Setup() (*PublicKey, *MasterKey, error) {
+	pairing, g := algo.params.Pairing(), algo.params.Generator()
+
+	var (
+		alpha  *Zr = pairing.NewZr()
+		a      *Zr = pairing.NewZr()
+		gAlpha *G  = pairing.NewG()
+		ga     *G  = pairing.NewG()
+		eg     *GT = pairing.NewGT()
+	)
+
+	// Choose random alpha, a from Zr as secret key
+	if err := algo.randZr(alpha); err != nil {
+		return nil, nil, err
+	} else if err := algo.randZr(a); err != nil {
+		return nil, nil, err
+	}
+
+	// Calculate g^alpha, g^a, e(g,g)^alpha
+	gAlpha.E.PowZn(g.E, alpha.E)
+	ga.E.PowZn(g.E, a.E)
+	eg.E.PowZn(algo.e, alpha.E)
+
+	return NewPublicKey(ga, eg), NewMasterKey(gAlpha, ga), nil
+}
+
+// Encrypt takes as input the public key, message and the access structure tree, and output
+// the ciphertext. The tree is converted into an LSSS matrix embedded in the ciphertext.
+func (algo *Waters11) Encrypt(key *PublicKey, msg *Message, tree bsw07.Node) (*Ciphertext, error) {
+	pairing, g := algo.params.Pairing(), algo.params.Generator()
+
+	if err := algo.params.BindAs("G", key.GA); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("GT", key.E, msg.M); err != nil {
+		return nil, err
+	}
+
+	policy, err := NewLSSS(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	// Randomly choose v = (s, y_2, ..., y_n), with s being the secret to share
+	v := make([]*pbc.Element, len(policy.Matrix[0]))
+	for i := range v {
+		z := pairing.NewZr()
+		if err := algo.randZr(z); err != nil {
+			return nil, err
+		}
+		v[i] = z.E
+	}
+	s := v[0]
+	lambda := policy.shares(v)
+
+	// Compute msg = M * e(g,g)^(alpha*s)
+	encMsg := pairing.NewGT()
+	encMsg.E.PowZn(key.E.E, s)
+	encMsg.E.Mul(msg.M.E, encMsg.E)
+
+	// Compute c = g^s
+	c := pairing.NewG()
+	c.E.PowZn(g.E, s)
+
+	ci := make([]*G, len(lambda))
+	di := make([]*G, len(lambda))
+	for i, l := range lambda {
+		// randomly choose r_i
+		r := pairing.NewZr()
+		if err := algo.randZr(r); err != nil {
+			return nil, err
+		}
+
+		// Compute C_i = g^(a*lambda_i) * H(rho(i))^-r_i
+		cI := pairing.NewG()
+		cI.E.PowZn(key.GA.E, l)
+		h := pairing.NewG()
+		h.E.SetFromHash(hash([]byte(policy.Rho[i])))
+		h.E.PowZn(h.E, r.E)
+		cI.E.Div(cI.E, h.E)
+
+		// Compute D_i = g^r_i
+		dI := pairing.NewG()
+		dI.E.PowZn(g.E, r.E)
+
+		ci[i] = cI
+		di[i] = dI
+	}
+
+	return NewCiphertext(policy, encMsg, c, ci, di), nil
+}
+
+// KeyGen takes as input a set of attributes and the master key, and generate
+// the corresponding decryption key.
+func (algo *Waters11) KeyGen(msk *MasterKey, attrs map[string]struct{}) (*DecryptKey, error) {
+	pairing, g := algo.params.Pairing(), algo.params.Generator()
+
+	if err := algo.params.BindAs("G", msk.GAlpha, msk.GA); err != nil {
+		return nil, err
+	}
+
+	// randomly choose t
+	t := pairing.NewZr()
+	if err := algo.randZr(t); err != nil {
+		return nil, err
+	}
+
+	// Compute K = g^alpha * g^(a*t)
+	k := pairing.NewG()
+	k.E.PowZn(msk.GA.E, t.E)
+	k.E.Mul(msk.GAlpha.E, k.E)
+
+	// Compute L = g^t
+	l := pairing.NewG()
+	l.E.PowZn(g.E, t.E)
+
+	kx := make(map[string]*G)
+	s := make(map[string]struct{})
+	for attr := range attrs {
+		// Compute K_x = H(x)^t
+		kX := pairing.NewG()
+		kX.E.SetFromHash(hash([]byte(attr)))
+		kX.E.PowZn(kX.E, t.E)
+
+		kx[attr] = kX
+		s[attr] = struct{}{}
+	}
+
+	return NewDecryptKey(s, k, l, kx), nil
+}
+
+// Decrypt takes ciphertext c and decryption key dk as input and returns the
+// decrypted message if attributes in dk Satisfy policy in ct.
+func (algo *Waters11) Decrypt(ct *Ciphertext, key *DecryptKey) (*Message, error) {
+	pairing := algo.params.Pairing()
+
+	if ct.Policy == nil || !ct.Policy.wellFormed() ||
+		len(ct.Ci) != len(ct.Policy.Rho) || len(ct.Di) != len(ct.Policy.Rho) {
+		return nil, ErrBadCiphertext
+	}
+
+	if err := algo.params.BindAs("GT", ct.Msg); err != nil {
+		return nil, err
+	} else if err := algo.params.BindAs("G", append(ct.elements(), key.elements()...)...); err != nil {
+		return nil, err
+	}
+
+	omega, err := ct.Policy.coefficients(pairing.P, key.S)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compute A = prod (e(C_i, L) * e(D_i, K_rho(i)))^omega_i
+	//           = e(prod C_i^omega_i, L) * prod e(D_i^omega_i, K_rho(i))
+	cw := pairing.NewG()
+	cw.E.Set1()
+	a := pairing.NewGT()
+	a.E.Set1()
+	for i, w := range omega {
+		kx, ok := key.KX[ct.Policy.Rho[i]]
+		if !ok {
+			return nil, ErrTreeNotSatisfied
+		}
+
+		temp := pairing.NewG()
+		temp.E.PowZn(ct.Ci[i].E, w)
+		cw.E.Mul(cw.E, temp.E)
+
+		temp.E.PowZn(ct.Di[i].E, w)
+		p := pairing.NewGT()
+		p.E.Pair(temp.E, kx.E)
+		a.E.Mul(a.E, p.E)
+	}
+	p := pairing.NewGT()
+	p.E.Pair(cw.E, key.L.E)
+	a.E.Mul(a.E, p.E)
+
+	// Compute e(C, K) / A = e(g,g)^(alpha*s)
+	m := pairing.NewGT()
+	m.E.Pair(ct.C.E, key.K.E)
+	m.E.Div(m.E, a.E)
+	// encMsg / e(g,g)^(alpha*s)
+	m.E.Div(ct.Msg.E, m.E)
+
+	return &Message{M: m}, nil
+}
@@ -0,0 +1,136 @@
+package waters11
+
+import (
+	"encoding/json"
+	"testing"
+
+	"ABE/bsw07"
+)
+
+var (
+	algo   *Waters11
+	cipher *Ciphertext
+	msg    *Message
+	msk    *MasterKey
+	pk     *PublicKey
+	dk     *DecryptKey
+)
+
+func TestWaters11_Encrypt(t *testing.T) {
+	var err error
+	algo, err = NewWaters11()
+	if err != nil {
+		t.Errorf("Error (%v) during initializing Waters11.", err)
+		return
+	}
+	pk, msk, _ = algo.Setup()
+
+	tree, err := bsw07.ParsePolicy("a OR (b AND 2 of (c, d, e))")
+	if err != nil {
+		t.Errorf("Error (%v) during parsing policy.", err)
+		return
+	}
+
+	msg = NewMessage().Rand()
+
+	cipher, err = algo.Encrypt(pk, msg, tree)
+	if err != nil {
+		t.Errorf("Error (%v) during encrypting. Msg: %v.", err, msg)
+	}
+}
+
+func TestWaters11_KeyGen(t *testing.T) {
+	var err error
+	dk, err = algo.KeyGen(msk, map[string]struct{}{"b": {}, "d": {}, "e": {}})
+	if err != nil {
+		t.Errorf("Error (%v) during decryption key generation.", err)
+	}
+}
+
+func TestWaters11_Decrypt(t *testing.T) {
+	plain, err := algo.Decrypt(cipher, dk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+		return
+	}
+
+	if !plain.M.E.Equals(msg.M.E) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+
+	for _, attrs := range []map[string]struct{}{
+		{"b": {}, "c": {}},
+		{"c": {}, "d": {}, "e": {}},
+		{},
+	} {
+		key, _ := algo.KeyGen(msk, attrs)
+		if _, err := algo.Decrypt(cipher, key); err != ErrTreeNotSatisfied {
+			t.Errorf("Ciphertext decrypted by key with attributes %v: %v", attrs, err)
+		}
+	}
+
+	key, _ := algo.KeyGen(msk, map[string]struct{}{"a": {}})
+	plain, err = algo.Decrypt(cipher, key)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+	} else if !plain.M.E.Equals(msg.M.E) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+}
+
+func TestCiphertext_JSON(t *testing.T) {
+	data, err := json.Marshal(cipher)
+	if err != nil {
+		t.Errorf("Error (%v) during marshaling.", err)
+		return
+	}
+
+	cipher2 := &Ciphertext{}
+	if err := json.Unmarshal(data, cipher2); err != nil {
+		t.Errorf("Error (%v) during unmarshaling.", err)
+		return
+	}
+
+	plain, err := algo.Decrypt(cipher2, dk)
+	if err != nil {
+		t.Errorf("Error (%v) during decryption.", err)
+	} else if !plain.M.E.Equals(msg.M.E) {
+		t.Errorf("Message before encryption and after decryption differs.")
+	}
+
+	cipher2.Ci = cipher2.Ci[1:]
+	if _, err := algo.Decrypt(cipher2, dk); err != ErrBadCiphertext {
+		t.Errorf("Ciphertext with missing component accepted: %v", err)
+	}
+
+	// Ragged or incomplete matrices are rejected rather than crashing Decrypt
+	for _, v := range []struct {
+		name string
+		json string
+	}{
+		{"ragged matrix", `{"m":[[1,1],[1],[1,2]]}`},
+		{"missing entry", `{"m":[[1,1],[1,null],[1,2]]}`},
+		{"empty rows", `{"m":[[],[],[]]}`},
+	} {
+		cipher2 := &Ciphertext{}
+		json.Unmarshal(data, cipher2)
+		if err := json.Unmarshal([]byte(v.json), cipher2.Policy); err != nil {
+			t.Errorf("Error (%v) during unmarshaling %s.", err, v.name)
+			continue
+		}
+
+		if _, err := algo.Decrypt(cipher2, dk); err != ErrBadCiphertext {
+			t.Errorf("Ciphertext with %s decrypted with %v, expecting %v", v.name, err, ErrBadCiphertext)
+		}
+	}
+
+	// Elements of the wrong field are rejected rather than reaching the pairing
+	params, _ := bsw07.DefaultParams()
+	cipher2 = &Ciphertext{}
+	json.Unmarshal(data, cipher2)
+	cipher2.C = params.Pairing().NewZr()
+	cipher2.C.E.Rand()
+	if _, err := algo.Decrypt(cipher2, dk); err != bsw07.ErrWrongField {
+		t.Errorf("Ciphertext with C in Zr decrypted with %v, expecting %v", err, bsw07.ErrWrongField)
+	}
+}